@@ -0,0 +1,330 @@
+package astibundler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/asticode/go-astikit"
+)
+
+// resourcesAdapterData represents the data args and environment variables of resources adapters are
+// executed with
+type resourcesAdapterData struct {
+	AppName            string
+	Arch               string
+	OS                 string
+	ResourcesPath      string
+	VersionAstilectron string
+	VersionElectron    string
+}
+
+// validate validates the resources adapter
+func (a ConfigurationResourcesAdapter) validate() (err error) {
+	// Timeout
+	if a.Timeout != "" {
+		if _, err = time.ParseDuration(a.Timeout); err != nil {
+			err = fmt.Errorf("parsing timeout %s failed: %w", a.Timeout, err)
+			return
+		}
+	}
+
+	// Builtin
+	if isBuiltinResourcesAdapter(a.Name) {
+		if err = a.validateBuiltin(); err != nil {
+			err = fmt.Errorf("validating builtin adapter failed: %w", err)
+			return
+		}
+	} else if len(a.Files) > 0 {
+		err = fmt.Errorf("files are only supported by builtin adapters")
+		return
+	}
+
+	// Templates
+	if _, err = a.expand(resourcesAdapterData{}); err != nil {
+		err = fmt.Errorf("expanding templates failed: %w", err)
+		return
+	}
+	return
+}
+
+// matches checks whether the resources adapter runs for an environment
+func (a ConfigurationResourcesAdapter) matches(oS, arch string) bool {
+	return (len(a.OS) == 0 || stringsContain(a.OS, oS)) && (len(a.Arch) == 0 || stringsContain(a.Arch, arch))
+}
+
+// isEnvironmentSpecific checks whether the resources adapter has to run for each environment, which is
+// also the case when its args or environment variables use the {{.OS}} or {{.Arch}} templates
+func (a ConfigurationResourcesAdapter) isEnvironmentSpecific() bool {
+	if a.EnvironmentSpecific || len(a.OS) > 0 || len(a.Arch) > 0 {
+		return true
+	}
+	for _, arg := range a.Args {
+		if usesEnvironmentTemplates(arg) {
+			return true
+		}
+	}
+	for _, v := range a.EnvironmentVariables {
+		if usesEnvironmentTemplates(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// usesEnvironmentTemplates checks whether a string uses the {{.OS}} or {{.Arch}} templates. Invalid
+// templates are reported when validating the adapter.
+func usesEnvironmentTemplates(s string) bool {
+	// No template
+	if !strings.Contains(s, "{{") {
+		return false
+	}
+
+	// Parse
+	t, err := template.New("").Parse(s)
+	if err != nil {
+		return false
+	}
+	return templateUsesEnvironment(t)
+}
+
+// templateUsesEnvironment checks whether a parsed template, or a template it defines, uses the {{.OS}}
+// or {{.Arch}} templates
+func templateUsesEnvironment(t *template.Template) bool {
+	for _, t := range t.Templates() {
+		if t.Tree != nil && nodeUsesEnvironment(t.Tree.Root) {
+			return true
+		}
+	}
+	return false
+}
+
+func nodeUsesEnvironment(n parse.Node) bool {
+	switch n := n.(type) {
+	case *parse.ActionNode:
+		return nodeUsesEnvironment(n.Pipe)
+	case *parse.ChainNode:
+		return nodeUsesEnvironment(n.Node)
+	case *parse.CommandNode:
+		for _, a := range n.Args {
+			if nodeUsesEnvironment(a) {
+				return true
+			}
+		}
+	case *parse.FieldNode:
+		return isEnvironmentField(n.Ident[0])
+	case *parse.IfNode:
+		return nodeUsesEnvironment(n.Pipe) || nodeUsesEnvironment(n.List) || nodeUsesEnvironment(n.ElseList)
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, c := range n.Nodes {
+			if nodeUsesEnvironment(c) {
+				return true
+			}
+		}
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, c := range n.Cmds {
+			if nodeUsesEnvironment(c) {
+				return true
+			}
+		}
+	case *parse.RangeNode:
+		return nodeUsesEnvironment(n.Pipe) || nodeUsesEnvironment(n.List) || nodeUsesEnvironment(n.ElseList)
+	case *parse.TemplateNode:
+		return nodeUsesEnvironment(n.Pipe)
+	case *parse.VariableNode:
+		return len(n.Ident) > 1 && n.Ident[0] == "$" && isEnvironmentField(n.Ident[1])
+	case *parse.WithNode:
+		return nodeUsesEnvironment(n.Pipe) || nodeUsesEnvironment(n.List) || nodeUsesEnvironment(n.ElseList)
+	}
+	return false
+}
+
+func isEnvironmentField(n string) bool {
+	return n == "Arch" || n == "OS"
+}
+
+func stringsContain(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// expand returns the resources adapter whose args and environment variables have been expanded
+func (a ConfigurationResourcesAdapter) expand(d resourcesAdapterData) (o ConfigurationResourcesAdapter, err error) {
+	o = a
+	o.Args = make([]string, len(a.Args))
+	for idx, arg := range a.Args {
+		if o.Args[idx], err = executeResourcesAdapterTemplate(arg, d); err != nil {
+			err = fmt.Errorf("expanding arg #%d failed: %w", idx, err)
+			return
+		}
+	}
+	if len(a.EnvironmentVariables) > 0 {
+		o.EnvironmentVariables = make(map[string]string)
+		for k, v := range a.EnvironmentVariables {
+			if o.EnvironmentVariables[k], err = executeResourcesAdapterTemplate(v, d); err != nil {
+				err = fmt.Errorf("expanding environment variable %s failed: %w", k, err)
+				return
+			}
+		}
+	}
+	return
+}
+
+func executeResourcesAdapterTemplate(s string, d resourcesAdapterData) (o string, err error) {
+	// No template
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+
+	// Parse
+	var t *template.Template
+	if t, err = template.New("").Option("missingkey=error").Parse(s); err != nil {
+		err = fmt.Errorf("parsing %s failed: %w", s, err)
+		return
+	}
+
+	// Execute
+	buf := &bytes.Buffer{}
+	if err = t.Execute(buf, d); err != nil {
+		err = fmt.Errorf("executing %s failed: %w", s, err)
+		return
+	}
+	o = buf.String()
+	return
+}
+
+// resourcesAdapterEnv returns the sorted environment variables added to the resources adapter command
+func resourcesAdapterEnv(a ConfigurationResourcesAdapter) (env []string) {
+	var ks []string
+	for k := range a.EnvironmentVariables {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	for _, k := range ks {
+		env = append(env, k+"="+a.EnvironmentVariables[k])
+	}
+	return
+}
+
+// resourcesAdapterData returns the data resources adapters are executed with for an environment
+func (b *Bundler) resourcesAdapterData(oS, arch, resourcesPath string) resourcesAdapterData {
+	return resourcesAdapterData{
+		AppName:            b.appName,
+		Arch:               arch,
+		OS:                 oS,
+		ResourcesPath:      resourcesPath,
+		VersionAstilectron: b.versionAstilectron,
+		VersionElectron:    b.versionElectron,
+	}
+}
+
+// runResourcesAdapter runs a resources adapter in the resources dir and streams its output to the logger
+func (b *Bundler) runResourcesAdapter(a ConfigurationResourcesAdapter, oS, arch, resourcesPath string) (err error) {
+	// Expand
+	d := b.resourcesAdapterData(oS, arch, resourcesPath)
+	if a, err = a.expand(d); err != nil {
+		err = fmt.Errorf("expanding failed: %w", err)
+		return
+	}
+
+	// Timeout
+	ctx := b.ctx
+	if a.Timeout != "" {
+		var t time.Duration
+		if t, err = time.ParseDuration(a.Timeout); err != nil {
+			err = fmt.Errorf("parsing timeout %s failed: %w", a.Timeout, err)
+			return
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t)
+		defer cancel()
+	}
+
+	// Builtin
+	if isBuiltinResourcesAdapter(a.Name) {
+		if err = b.runBuiltinResourcesAdapter(ctx, a, d); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				err = fmt.Errorf("running %s timed out after %s", a.Name, a.Timeout)
+			} else {
+				err = fmt.Errorf("running %s failed: %w", a.Name, err)
+			}
+		}
+		return
+	}
+
+	// Create cmd
+	cmd := exec.CommandContext(ctx, a.Name, a.Args...)
+	cmd.Dir = resourcesPath
+	if a.Dir != "" {
+		cmd.Dir = filepath.Join(resourcesPath, a.Dir)
+	}
+	cmd.Env = append(os.Environ(), resourcesAdapterEnv(a)...)
+	startProcessGroup(cmd)
+
+	// Stream output
+	prefix := filepath.Base(a.Name)
+	stdout := astikit.NewWriterAdapter(astikit.WriterAdapterOptions{
+		Callback: func(i []byte) { b.l.Infof("%s: %s", prefix, i) },
+		Split:    []byte("\n"),
+	})
+	stderr := astikit.NewWriterAdapter(astikit.WriterAdapterOptions{
+		Callback: func(i []byte) { b.l.Errorf("%s: %s", prefix, i) },
+		Split:    []byte("\n"),
+	})
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	// Start
+	b.l.Debugf("Running %s in directory %s", strings.Join(cmd.Args, " "), cmd.Dir)
+	if err = cmd.Start(); err != nil {
+		err = fmt.Errorf("starting %s failed: %w", strings.Join(cmd.Args, " "), err)
+		return
+	}
+
+	// Wait
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// Processes the command has started may keep its output open, therefore they're killed as well
+		// before waiting for the command
+		killProcessGroup(cmd)
+		err = <-done
+	}
+	if err != nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	stdout.Close()
+	stderr.Close()
+
+	// Process error
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("running %s timed out after %s", strings.Join(cmd.Args, " "), a.Timeout)
+		} else {
+			err = fmt.Errorf("running %s failed: %w", strings.Join(cmd.Args, " "), err)
+		}
+		return
+	}
+	return
+}
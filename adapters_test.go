@@ -0,0 +1,211 @@
+package astibundler
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigurationResourcesAdapter(t *testing.T) {
+	a := ConfigurationResourcesAdapter{
+		Args:                 []string{"--target={{.OS}}-{{.Arch}}", "{{.ResourcesPath}}/app", "plain"},
+		EnvironmentVariables: map[string]string{"TARGET": "{{.OS}}"},
+		OS:                   []string{"windows"},
+	}
+	assert.NoError(t, a.validate())
+	assert.True(t, a.matches("windows", "386"))
+	assert.False(t, a.matches("linux", "amd64"))
+	a.Arch = []string{"amd64"}
+	assert.False(t, a.matches("windows", "386"))
+	assert.True(t, a.matches("windows", "amd64"))
+
+	e, err := a.expand(resourcesAdapterData{Arch: "amd64", OS: "windows", ResourcesPath: "/tmp/resources"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"--target=windows-amd64", "/tmp/resources/app", "plain"}, e.Args)
+	assert.Equal(t, map[string]string{"TARGET": "windows"}, e.EnvironmentVariables)
+	assert.Equal(t, []string{"--target={{.OS}}-{{.Arch}}", "{{.ResourcesPath}}/app", "plain"}, a.Args)
+
+	for _, a := range []ConfigurationResourcesAdapter{
+		{Args: []string{"{{.Invalid}}"}},
+		{Args: []string{"{{.OS"}},
+		{Timeout: "10"},
+	} {
+		assert.Error(t, a.validate())
+	}
+}
+
+func TestResourcesAdapterIsEnvironmentSpecific(t *testing.T) {
+	for _, v := range []struct {
+		a        ConfigurationResourcesAdapter
+		expected bool
+	}{
+		{a: ConfigurationResourcesAdapter{Args: []string{"{{.AppName}}", "{{.ResourcesPath}}/app"}}},
+		{a: ConfigurationResourcesAdapter{Args: []string{"{{.Invalid"}}},
+		{a: ConfigurationResourcesAdapter{Args: []string{"--target={{.OS}}"}}, expected: true},
+		{a: ConfigurationResourcesAdapter{Args: []string{`{{if eq .Arch "arm64"}}--arm{{end}}`}}, expected: true},
+		{a: ConfigurationResourcesAdapter{Args: []string{"{{with .AppName}}{{$.OS}}{{end}}"}}, expected: true},
+		{a: ConfigurationResourcesAdapter{EnvironmentVariables: map[string]string{"GOARCH": "{{.Arch}}"}}, expected: true},
+		{a: ConfigurationResourcesAdapter{EnvironmentSpecific: true}, expected: true},
+		{a: ConfigurationResourcesAdapter{Arch: []string{"amd64"}}, expected: true},
+		{a: ConfigurationResourcesAdapter{OS: []string{"linux"}}, expected: true},
+	} {
+		assert.Equal(t, v.expected, v.a.isEnvironmentSpecific(), v.a)
+	}
+}
+
+func TestRunResourcesAdapter(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	buf := &bytes.Buffer{}
+	b, err := New(&Configuration{}, log.New(buf, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = b.runResourcesAdapter(ConfigurationResourcesAdapter{
+		Args:                 []string{"-c", "echo hello; echo $TARGET > {{.ResourcesPath}}/out.txt"},
+		EnvironmentVariables: map[string]string{"TARGET": "{{.OS}}/{{.Arch}}"},
+		Name:                 "sh",
+		Timeout:              "1m",
+	}, "linux", "arm64", dir)
+	assert.NoError(t, err)
+	o, err := ioutil.ReadFile(filepath.Join(dir, "out.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "linux/arm64\n", string(o))
+	assert.Contains(t, buf.String(), "sh: hello\n")
+
+	err = b.runResourcesAdapter(ConfigurationResourcesAdapter{
+		Args:    []string{"-c", "sleep 5"},
+		Name:    "sh",
+		Timeout: "100ms",
+	}, "linux", "arm64", dir)
+	assert.EqualError(t, err, "running sh -c sleep 5 timed out after 100ms")
+
+	err = b.runResourcesAdapter(ConfigurationResourcesAdapter{
+		Args:    []string{"-c", "(sleep 1; touch {{.ResourcesPath}}/late.txt) & wait"},
+		Name:    "sh",
+		Timeout: "100ms",
+	}, "linux", "arm64", dir)
+	assert.Error(t, err)
+	time.Sleep(1500 * time.Millisecond)
+	_, err = os.Stat(filepath.Join(dir, "late.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRunBuiltinResourcesAdapters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	buf := &bytes.Buffer{}
+	b, err := New(&Configuration{AppName: "App"}, log.New(buf, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, "app/main.js", "function add(first, second) {\n  return first + second;\n}\n")
+	writeTestFile(t, dir, "app/lib.min.js", "var a = 1;\n")
+	writeTestFile(t, dir, "app/style.css", "body {\n  color: #ff0000;\n}\n")
+	writeTestFile(t, dir, "app/index.html", "<html>\n  <body>\n    <p class=\"a\">Hello</p>\n  </body>\n</html>\n")
+	writeTestFile(t, dir, "config.json.tmpl", `{"name":"{{.AppName}}","os":"{{.OS}}","home":"{{env "ASTIBUNDLER_TEST"}}"}`)
+	os.Setenv("ASTIBUNDLER_TEST", "value")
+	defer os.Unsetenv("ASTIBUNDLER_TEST")
+
+	for _, a := range []ConfigurationResourcesAdapter{
+		{Name: ResourcesAdapterMinifyJS},
+		{Dir: "app", Name: ResourcesAdapterMinifyCSS},
+		{Name: ResourcesAdapterMinifyHTML},
+		{Name: ResourcesAdapterTemplate},
+	} {
+		assert.NoError(t, a.validate())
+		assert.NoError(t, b.runResourcesAdapter(a, "linux", "amd64", dir))
+	}
+	for p, e := range map[string]string{
+		"app/main.js":    "function add(e,t){return e+t}",
+		"app/lib.min.js": "var a = 1;\n",
+		"app/style.css":  "body{color:red}",
+		"app/index.html": "<html><body><p class=\"a\">Hello</p></body></html>",
+		"config.json":    `{"name":"App","os":"linux","home":"value"}`,
+	} {
+		o, err := ioutil.ReadFile(filepath.Join(dir, p))
+		assert.NoError(t, err)
+		assert.Equal(t, e, string(o), p)
+	}
+	_, err = os.Stat(filepath.Join(dir, "config.json.tmpl"))
+	assert.True(t, os.IsNotExist(err))
+
+	// Shared template adapters can't use the environment
+	writeTestFile(t, dir, "config.json.tmpl", `{"os":"{{.OS}}"}`)
+	err = b.runResourcesAdapter(ConfigurationResourcesAdapter{Name: ResourcesAdapterTemplate}, "", "", dir)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "set environment_specific to true")
+	}
+	assert.Contains(t, buf.String(), "builtin:minify-js: 1 files adapted")
+
+	for _, a := range []ConfigurationResourcesAdapter{
+		{Name: "builtin:invalid"},
+		{Args: []string{"arg"}, Name: ResourcesAdapterMinifyJS},
+		{Files: []string{"*.js"}, Name: "uglifyjs"},
+		{Files: []string{"[invalid"}, Name: ResourcesAdapterMinifyJS},
+	} {
+		assert.Error(t, a.validate())
+	}
+}
+
+func TestStageResources(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	runs := filepath.Join(dir, "runs.log")
+	b, err := New(&Configuration{
+		InputPath: filepath.Join(dir, "app"),
+		ResourcesAdapters: []ConfigurationResourcesAdapter{
+			{Args: []string{"-c", "echo shared >> " + runs + "; echo shared > shared.txt"}, Name: "sh"},
+			{Args: []string{"-c", "echo {{.OS}} >> " + runs + "; echo {{.OS}} > {{.OS}}.txt"}, Name: "sh"},
+			{Args: []string{"-c", "echo darwin only >> " + runs}, Name: "sh", OS: []string{"darwin"}},
+		},
+		WorkingDirectoryPath: filepath.Join(dir, "wd"),
+	}, log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, "app/resources/index.html", "<html>")
+
+	assert.NoError(t, b.stageResources())
+	for _, e := range []ConfigurationEnvironment{{Arch: "amd64", OS: "linux"}, {Arch: "386", OS: "windows"}} {
+		assert.NoError(t, b.resetDir(b.pathBindInput))
+		assert.NoError(t, b.adaptResources(e.OS, e.Arch, true))
+	}
+	o, err := ioutil.ReadFile(runs)
+	assert.NoError(t, err)
+	assert.Equal(t, "shared\nlinux\nwindows\n", string(o))
+	ps, err := listFiles(filepath.Join(b.pathBindInput, "resources"), func(p string, fi os.FileInfo) bool { return true })
+	assert.NoError(t, err)
+	for idx := range ps {
+		ps[idx], _ = filepath.Rel(b.pathBindInput, ps[idx])
+	}
+	assert.Equal(t, []string{"resources/index.html", "resources/shared.txt", "resources/windows.txt"}, ps)
+}
@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package astibundler
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// startProcessGroup makes the command start its own process group so that processes it starts can be
+// killed with it
+func startProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills the command and the processes it has started
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+		cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,22 @@
+//go:build windows
+// +build windows
+
+package astibundler
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// startProcessGroup does nothing since the command and the processes it has started are killed as a tree
+func startProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the command and the processes it has started
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run(); err != nil {
+		cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,322 @@
+package astibundler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/template"
+
+	"github.com/ulikunitz/xz"
+)
+
+// Archive formats
+const (
+	ArchiveFormatTarGz = "tar.gz"
+	ArchiveFormatTarXz = "tar.xz"
+	ArchiveFormatZip   = "zip"
+)
+
+// Archive names
+const (
+	archiveNameDefault               = "{{.AppName}}-{{.Version}}-{{.OS}}-{{.Arch}}"
+	archiveNameDefaultWithoutVersion = "{{.AppName}}-{{.OS}}-{{.Arch}}"
+)
+
+// archiveNameData represents the data the archive name is executed with
+type archiveNameData struct {
+	AppName string
+	Arch    string
+	OS      string
+	Version string
+}
+
+// newArchiveNameTemplate parses the archive name, which defaults to a name including the version if
+// there's one
+func newArchiveNameTemplate(c *Configuration) (t *template.Template, err error) {
+	n := c.Archive.Name
+	if len(n) == 0 {
+		if n = archiveNameDefault; len(releaseVersion(c)) == 0 {
+			n = archiveNameDefaultWithoutVersion
+		}
+	}
+	if t, err = template.New("name").Option("missingkey=error").Parse(n); err != nil {
+		err = fmt.Errorf("parsing template %s failed: %w", n, err)
+		return
+	}
+	return
+}
+
+// validateArchiveFormats validates archive formats indexed by OS or by "<os>/<arch>"
+func validateArchiveFormats(fs map[string]string) error {
+	var ks []string
+	for k := range fs {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	for _, k := range ks {
+		switch fs[k] {
+		case ArchiveFormatTarGz, ArchiveFormatTarXz, ArchiveFormatZip:
+		default:
+			return fmt.Errorf("format %s of %s is not %s, %s or %s", fs[k], k, ArchiveFormatZip, ArchiveFormatTarGz, ArchiveFormatTarXz)
+		}
+	}
+	return nil
+}
+
+// archiveFormat returns the archive format of an environment
+func (b *Bundler) archiveFormat(e ConfigurationEnvironment) string {
+	if f, ok := b.archiveFormats[e.OS+"/"+e.Arch]; ok {
+		return f
+	}
+	if f, ok := b.archiveFormats[e.OS]; ok {
+		return f
+	}
+	if e.OS == "linux" {
+		return ArchiveFormatTarGz
+	}
+	return ArchiveFormatZip
+}
+
+// archivePath returns the path of the archive of an environment output directory
+func (b *Bundler) archivePath(e ConfigurationEnvironment) (p string, err error) {
+	buf := &bytes.Buffer{}
+	if err = b.archiveName.Execute(buf, archiveNameData{
+		AppName: b.appName,
+		Arch:    e.Arch,
+		OS:      e.OS,
+		Version: b.releaseVersion,
+	}); err != nil {
+		err = fmt.Errorf("executing name template failed: %w", err)
+		return
+	}
+	p = filepath.Join(b.pathOutput, buf.String()+"."+b.archiveFormat(e))
+	return
+}
+
+// archiveEnvironment archives an environment output directory in the output path
+func (b *Bundler) archiveEnvironment(e ConfigurationEnvironment) (err error) {
+	// Get path
+	var p string
+	if p, err = b.archivePath(e); err != nil {
+		err = fmt.Errorf("getting archive path failed: %w", err)
+		return
+	}
+
+	// Archive
+	environmentPath := b.environmentPath(e)
+	b.l.Debugf("Archiving %s into %s", environmentPath, p)
+	switch b.archiveFormat(e) {
+	case ArchiveFormatTarGz, ArchiveFormatTarXz:
+		err = writeTar(p, environmentPath)
+	default:
+		err = writeZip(p, environmentPath, false)
+	}
+	if err != nil {
+		err = fmt.Errorf("archiving %s into %s failed: %w", environmentPath, p, err)
+		return
+	}
+	return
+}
+
+// archiveEntry represents a file, a dir or a symlink of a dir being archived
+type archiveEntry struct {
+	fi   os.FileInfo
+	link string
+	name string
+	path string
+}
+
+// archiveEntries returns the entries of a dir sorted by name, prefixed with the dir name if asked to.
+// Symlinks are not followed.
+func archiveEntries(dir string, withDir bool) (es []archiveEntry, err error) {
+	if err = filepath.Walk(dir, func(p string, fi os.FileInfo, e error) (err error) {
+		// Process error
+		if e != nil {
+			err = e
+			return
+		}
+
+		// Root dir
+		if p == dir && !withDir {
+			return
+		}
+
+		// Create entry
+		rp, _ := filepath.Rel(dir, p)
+		if withDir {
+			rp, _ = filepath.Rel(filepath.Dir(dir), p)
+		}
+		en := archiveEntry{fi: fi, name: filepath.ToSlash(rp), path: p}
+		if fi.IsDir() {
+			en.name += "/"
+		} else if fi.Mode()&os.ModeSymlink != 0 {
+			if en.link, err = os.Readlink(p); err != nil {
+				err = fmt.Errorf("reading link %s failed: %w", p, err)
+				return
+			}
+		} else if !fi.Mode().IsRegular() {
+			return
+		}
+		es = append(es, en)
+		return
+	}); err != nil {
+		err = fmt.Errorf("walking through %s failed: %w", dir, err)
+		return
+	}
+	return
+}
+
+// writeZip writes the content of a dir, or the dir itself if asked to, in a zip archive, preserving unix
+// modes and symlinks
+func writeZip(dst, src string, withDir bool) (err error) {
+	// Get entries
+	var es []archiveEntry
+	if es, err = archiveEntries(src, withDir); err != nil {
+		err = fmt.Errorf("getting entries failed: %w", err)
+		return
+	}
+
+	// Create file
+	var f *os.File
+	if f, err = os.Create(dst); err != nil {
+		err = fmt.Errorf("creating %s failed: %w", dst, err)
+		return
+	}
+	defer f.Close()
+
+	// Loop through entries
+	w := zip.NewWriter(f)
+	for _, e := range es {
+		// Create header
+		var h *zip.FileHeader
+		if h, err = zip.FileInfoHeader(e.fi); err != nil {
+			err = fmt.Errorf("creating header of %s failed: %w", e.path, err)
+			return
+		}
+		h.Name = e.name
+		if e.fi.Mode().IsRegular() {
+			h.Method = zip.Deflate
+		}
+
+		// Create writer
+		var fw io.Writer
+		if fw, err = w.CreateHeader(h); err != nil {
+			err = fmt.Errorf("creating writer of %s failed: %w", e.path, err)
+			return
+		}
+
+		// Write content, which is the target of symlinks
+		if len(e.link) > 0 {
+			if _, err = io.WriteString(fw, e.link); err != nil {
+				err = fmt.Errorf("writing link of %s failed: %w", e.path, err)
+				return
+			}
+		} else if e.fi.Mode().IsRegular() {
+			if err = copyFileContent(fw, e.path); err != nil {
+				err = fmt.Errorf("copying %s failed: %w", e.path, err)
+				return
+			}
+		}
+	}
+
+	// Close
+	if err = w.Close(); err != nil {
+		err = fmt.Errorf("closing zip writer failed: %w", err)
+		return
+	}
+	if err = f.Close(); err != nil {
+		err = fmt.Errorf("closing %s failed: %w", dst, err)
+		return
+	}
+	return
+}
+
+// writeTar writes the content of a dir in a gzip or xz compressed tar archive, based on the extension of
+// the destination, preserving unix modes and symlinks. Owners are not preserved.
+func writeTar(dst, src string) (err error) {
+	// Get entries
+	var es []archiveEntry
+	if es, err = archiveEntries(src, false); err != nil {
+		err = fmt.Errorf("getting entries failed: %w", err)
+		return
+	}
+
+	// Create file
+	var f *os.File
+	if f, err = os.Create(dst); err != nil {
+		err = fmt.Errorf("creating %s failed: %w", dst, err)
+		return
+	}
+	defer f.Close()
+
+	// Create compression writer
+	var cw io.WriteCloser
+	if filepath.Ext(dst) == ".xz" {
+		if cw, err = xz.NewWriter(f); err != nil {
+			err = fmt.Errorf("creating xz writer failed: %w", err)
+			return
+		}
+	} else if cw, err = gzip.NewWriterLevel(f, gzip.BestCompression); err != nil {
+		err = fmt.Errorf("creating gzip writer failed: %w", err)
+		return
+	}
+
+	// Loop through entries
+	w := tar.NewWriter(cw)
+	for _, e := range es {
+		// Create header
+		var h *tar.Header
+		if h, err = tar.FileInfoHeader(e.fi, e.link); err != nil {
+			err = fmt.Errorf("creating header of %s failed: %w", e.path, err)
+			return
+		}
+		h.Name = e.name
+		h.Uid, h.Gid, h.Uname, h.Gname = 0, 0, "", ""
+
+		// Write header
+		if err = w.WriteHeader(h); err != nil {
+			err = fmt.Errorf("writing header of %s failed: %w", e.path, err)
+			return
+		}
+
+		// Write content
+		if e.fi.Mode().IsRegular() {
+			if err = copyFileContent(w, e.path); err != nil {
+				err = fmt.Errorf("copying %s failed: %w", e.path, err)
+				return
+			}
+		}
+	}
+
+	// Close
+	if err = w.Close(); err != nil {
+		err = fmt.Errorf("closing tar writer failed: %w", err)
+		return
+	}
+	if err = cw.Close(); err != nil {
+		err = fmt.Errorf("closing compression writer failed: %w", err)
+		return
+	}
+	if err = f.Close(); err != nil {
+		err = fmt.Errorf("closing %s failed: %w", dst, err)
+		return
+	}
+	return
+}
+
+// copyFileContent copies the content of a file to a writer
+func copyFileContent(w io.Writer, p string) (err error) {
+	var f *os.File
+	if f, err = os.Open(p); err != nil {
+		return
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return
+}
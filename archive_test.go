@@ -0,0 +1,177 @@
+package astibundler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ulikunitz/xz"
+)
+
+func TestArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Environment output directories
+	envs := []ConfigurationEnvironment{
+		{Arch: "amd64", OS: "darwin"},
+		{Arch: "amd64", OS: "linux"},
+		{Arch: "arm64", OS: "linux"},
+		{Arch: "amd64", OS: "windows"},
+	}
+	for _, e := range envs {
+		p := filepath.Join(dir, "output", e.OS+"-"+e.Arch)
+		writeTestFile(t, p, "App", e.OS)
+		if err = os.Chmod(filepath.Join(p, "App"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeTestFile(t, p, "lib/lib.so.1", "lib")
+		if err = os.Symlink("lib.so.1", filepath.Join(p, "lib", "lib.so")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := New(&Configuration{
+		AppName: "App",
+		Archive: ConfigurationArchive{
+			Enabled: true,
+			Formats: map[string]string{"linux/arm64": ArchiveFormatTarXz},
+		},
+		Environments:         envs,
+		OutputPath:           filepath.Join(dir, "output"),
+		Release:              ConfigurationRelease{Version: "1.2.0"},
+		WorkingDirectoryPath: filepath.Join(dir, "wd"),
+	}, log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range envs {
+		assert.NoError(t, b.archiveEnvironment(e))
+	}
+
+	// Zip
+	for _, n := range []string{"App-1.2.0-darwin-amd64.zip", "App-1.2.0-windows-amd64.zip"} {
+		r, err := zip.OpenReader(filepath.Join(dir, "output", n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs := make(map[string]*zip.File)
+		var names []string
+		for _, f := range r.File {
+			fs[f.Name] = f
+			names = append(names, f.Name)
+		}
+		assert.Equal(t, []string{"App", "lib/", "lib/lib.so", "lib/lib.so.1"}, names, n)
+		assert.Equal(t, os.FileMode(0755), fs["App"].Mode(), n)
+		assert.True(t, fs["lib/"].Mode().IsDir(), n)
+		assert.Equal(t, os.ModeSymlink, fs["lib/lib.so"].Mode()&os.ModeSymlink, n)
+		assert.Equal(t, "lib.so.1", readTestZipFile(t, fs["lib/lib.so"]), n)
+		assert.Equal(t, "lib", readTestZipFile(t, fs["lib/lib.so.1"]), n)
+		r.Close()
+	}
+
+	// Tar
+	for n, fn := range map[string]func(r io.Reader) (io.Reader, error){
+		"App-1.2.0-linux-amd64.tar.gz": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"App-1.2.0-linux-arm64.tar.xz": func(r io.Reader) (io.Reader, error) { return xz.NewReader(r) },
+	} {
+		f, err := os.Open(filepath.Join(dir, "output", n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cr, err := fn(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := tar.NewReader(cr)
+		hs := make(map[string]*tar.Header)
+		var names []string
+		for {
+			h, err := r.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			hs[h.Name] = h
+			names = append(names, h.Name)
+		}
+		f.Close()
+		assert.Equal(t, []string{"App", "lib/", "lib/lib.so", "lib/lib.so.1"}, names, n)
+		assert.Equal(t, int64(0755), hs["App"].Mode, n)
+		assert.Equal(t, byte(tar.TypeSymlink), hs["lib/lib.so"].Typeflag, n)
+		assert.Equal(t, "lib.so.1", hs["lib/lib.so"].Linkname, n)
+		assert.Equal(t, 0, hs["App"].Uid, n)
+		assert.Empty(t, hs["App"].Uname, n)
+	}
+
+	// Artifacts
+	assert.Contains(t, b.artifacts(envs[2]), filepath.Join(dir, "output", "App-1.2.0-linux-arm64.tar.xz"))
+
+	// Checksums
+	assert.NoError(t, b.writeChecksums())
+	sums, err := ioutil.ReadFile(filepath.Join(dir, "output", ChecksumsFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(sums), "  App-1.2.0-windows-amd64.zip\n")
+	assert.NoError(t, VerifyChecksums(filepath.Join(dir, "output"), ""))
+
+	// Result
+	r, err := b.Result()
+	assert.NoError(t, err)
+	for _, a := range r.Artifacts {
+		if strings.HasPrefix(a.Path, "App-1.2.0-linux-amd64") {
+			assert.Equal(t, &ConfigurationEnvironment{Arch: "amd64", OS: "linux"}, a.Environment)
+		}
+	}
+}
+
+func TestArchiveName(t *testing.T) {
+	for _, v := range []struct {
+		c        Configuration
+		expected string
+	}{
+		{c: Configuration{AppName: "App"}, expected: "App-linux-amd64.tar.gz"},
+		{c: Configuration{AppName: "App", InfoPlist: map[string]interface{}{"CFBundleShortVersionString": "1.0.0"}}, expected: "App-1.0.0-linux-amd64.tar.gz"},
+		{c: Configuration{AppName: "App", Archive: ConfigurationArchive{Formats: map[string]string{"linux": ArchiveFormatZip}, Name: "{{.OS}}/{{.AppName}}_{{.Version}}"}, Release: ConfigurationRelease{Version: "2.0.0"}}, expected: "linux/App_2.0.0.zip"},
+	} {
+		v.c.Archive.Enabled = true
+		v.c.OutputPath = "/output"
+		b, err := New(&v.c, log.New(ioutil.Discard, "", 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := b.archivePath(ConfigurationEnvironment{Arch: "amd64", OS: "linux"})
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Join("/output", v.expected), p)
+	}
+
+	// Invalid format
+	_, err := New(&Configuration{AppName: "App", Archive: ConfigurationArchive{Enabled: true, Formats: map[string]string{"linux": "rar"}}}, log.New(ioutil.Discard, "", 0))
+	assert.Error(t, err)
+}
+
+func readTestZipFile(t *testing.T, f *zip.File) string {
+	r, err := f.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
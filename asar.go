@@ -0,0 +1,375 @@
+package astibundler
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/asticode/go-astikit"
+)
+
+// asarBlockSize is the size of the blocks whose hashes are stored in the integrity of ASAR files
+const asarBlockSize = 4 * 1024 * 1024
+
+// asarEntry represents an entry of an ASAR header
+type asarEntry struct {
+	Executable bool                  `json:"executable,omitempty"`
+	Files      map[string]*asarEntry `json:"files,omitempty"`
+	Integrity  *asarIntegrity        `json:"integrity,omitempty"`
+	Offset     string                `json:"offset,omitempty"`
+	Size       *int64                `json:"size,omitempty"`
+	Unpacked   bool                  `json:"unpacked,omitempty"`
+}
+
+type asarIntegrity struct {
+	Algorithm string   `json:"algorithm"`
+	BlockSize int      `json:"blockSize"`
+	Blocks    []string `json:"blocks"`
+	Hash      string   `json:"hash"`
+}
+
+// packASAR packs a dir into an ASAR archive. Files matching the unpacked patterns are moved to the
+// "<archive>.unpacked" dir instead, as expected by Electron.
+func packASAR(ctx context.Context, dir, dst string, unpacked pathPatterns) (err error) {
+	// List files
+	var ps []string
+	if ps, err = listFiles(dir, func(p string, fi os.FileInfo) bool { return true }); err != nil {
+		err = fmt.Errorf("listing files of %s failed: %w", dir, err)
+		return
+	}
+
+	// Build header
+	root := &asarEntry{Files: make(map[string]*asarEntry)}
+	var packed []string
+	var offset int64
+	for _, p := range ps {
+		// Stat
+		var fi os.FileInfo
+		if fi, err = os.Stat(p); err != nil {
+			err = fmt.Errorf("stating %s failed: %w", p, err)
+			return
+		}
+
+		// Get parent entry
+		rp, _ := filepath.Rel(dir, p)
+		ss := strings.Split(filepath.ToSlash(rp), "/")
+		parent := root
+		for _, s := range ss[:len(ss)-1] {
+			if _, ok := parent.Files[s]; !ok {
+				parent.Files[s] = &asarEntry{Files: make(map[string]*asarEntry)}
+			}
+			parent = parent.Files[s]
+		}
+
+		// Create entry
+		size := fi.Size()
+		e := &asarEntry{
+			Executable: fi.Mode()&0111 != 0,
+			Size:       &size,
+		}
+		parent.Files[ss[len(ss)-1]] = e
+
+		// Unpacked
+		if unpacked.match(filepath.ToSlash(rp), false) {
+			e.Unpacked = true
+			u := filepath.Join(dst+".unpacked", rp)
+			if err = astikit.CopyFile(ctx, u, p, astikit.LocalCopyFileFunc); err != nil {
+				err = fmt.Errorf("copying %s to %s failed: %w", p, u, err)
+				return
+			}
+			continue
+		}
+
+		// Integrity
+		if e.Integrity, err = asarFileIntegrity(p); err != nil {
+			err = fmt.Errorf("computing integrity of %s failed: %w", p, err)
+			return
+		}
+
+		// Offset
+		e.Offset = strconv.FormatInt(offset, 10)
+		offset += size
+		packed = append(packed, p)
+	}
+
+	// Marshal header
+	var h []byte
+	if h, err = json.Marshal(root); err != nil {
+		err = fmt.Errorf("marshaling header failed: %w", err)
+		return
+	}
+
+	// Create file
+	var f *os.File
+	if f, err = os.Create(dst); err != nil {
+		err = fmt.Errorf("creating %s failed: %w", dst, err)
+		return
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	// Write header: a pickle containing the size of a pickle containing the header string, which is
+	// padded to 4 bytes
+	padding := (4 - len(h)%4) % 4
+	headerSize := 8 + len(h) + padding
+	bs := make([]byte, 16)
+	binary.LittleEndian.PutUint32(bs[0:], 4)
+	binary.LittleEndian.PutUint32(bs[4:], uint32(headerSize))
+	binary.LittleEndian.PutUint32(bs[8:], uint32(headerSize-4))
+	binary.LittleEndian.PutUint32(bs[12:], uint32(len(h)))
+	w.Write(bs)
+	w.Write(h)
+	w.Write(make([]byte, padding))
+
+	// Write files
+	for _, p := range packed {
+		if err = hashFile(w, p); err != nil {
+			err = fmt.Errorf("writing %s failed: %w", p, err)
+			return
+		}
+	}
+
+	// Flush
+	if err = w.Flush(); err != nil {
+		err = fmt.Errorf("flushing failed: %w", err)
+		return
+	}
+	return
+}
+
+func asarFileIntegrity(p string) (i *asarIntegrity, err error) {
+	// Open
+	var f *os.File
+	if f, err = os.Open(p); err != nil {
+		err = fmt.Errorf("opening %s failed: %w", p, err)
+		return
+	}
+	defer f.Close()
+
+	// Hash
+	i = &asarIntegrity{Algorithm: "SHA256", BlockSize: asarBlockSize, Blocks: []string{}}
+	h := sha256.New()
+	buf := make([]byte, asarBlockSize)
+	for {
+		n, errRead := io.ReadFull(f, buf)
+		if n > 0 {
+			bh := sha256.Sum256(buf[:n])
+			i.Blocks = append(i.Blocks, hex.EncodeToString(bh[:]))
+			h.Write(buf[:n])
+		}
+		if errRead == io.EOF || errRead == io.ErrUnexpectedEOF {
+			break
+		} else if errRead != nil {
+			err = fmt.Errorf("reading %s failed: %w", p, errRead)
+			return
+		}
+	}
+	if len(i.Blocks) == 0 {
+		bh := sha256.Sum256(nil)
+		i.Blocks = append(i.Blocks, hex.EncodeToString(bh[:]))
+	}
+	i.Hash = hex.EncodeToString(h.Sum(nil))
+	return
+}
+
+// asarName returns the name of the ASAR archive of resources
+func (b *Bundler) asarName() string {
+	return filepath.Clean(b.pathResources) + ".asar"
+}
+
+// packResources replaces the resources of the bind dir with an ASAR archive
+func (b *Bundler) packResources() (err error) {
+	// Pack
+	i := filepath.Join(b.pathBindInput, b.pathResources)
+	o := filepath.Join(b.pathBindInput, b.asarName())
+	b.l.Debugf("Packing %s into %s", i, o)
+	if err = packASAR(b.ctx, i, o, b.resourcesASARUnpacked); err != nil {
+		err = fmt.Errorf("packing %s into %s failed: %w", i, o, err)
+		return
+	}
+
+	// Remove resources
+	b.l.Debugf("Removing %s", i)
+	if err = os.RemoveAll(i); err != nil {
+		err = fmt.Errorf("removing %s failed: %w", i, err)
+		return
+	}
+
+	// Write reader
+	p := b.asarReaderPath()
+	b.l.Debugf("Generating %s", p)
+	var f *os.File
+	if f, err = os.Create(p); err != nil {
+		err = fmt.Errorf("creating %s failed: %w", p, err)
+		return
+	}
+	defer f.Close()
+	if err = asarReaderTemplate.Execute(f, b.bindPackage); err != nil {
+		err = fmt.Errorf("executing template failed: %w", err)
+		return
+	}
+	return
+}
+
+// asarReaderPath returns the path of the ASAR reader generated next to the bind files
+func (b *Bundler) asarReaderPath() string {
+	return filepath.Join(b.pathBindOutput, "bind_asar.go")
+}
+
+var asarReaderTemplate = template.Must(template.New("asar").Parse(`// Code generated by astilectron-bundler.
+// DO NOT EDIT!
+
+package {{ . }}
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Asar represents an ASAR archive bound in the package
+type Asar struct {
+	data []byte
+	name string
+	root *bindAsarEntry
+}
+
+type bindAsarEntry struct {
+	Executable bool                      ` + "`json:\"executable\"`" + `
+	Files      map[string]*bindAsarEntry ` + "`json:\"files\"`" + `
+	Offset     string                    ` + "`json:\"offset\"`" + `
+	Size       int64                     ` + "`json:\"size\"`" + `
+	Unpacked   bool                      ` + "`json:\"unpacked\"`" + `
+}
+
+// OpenAsar opens the ASAR archive bound under the given name, eg "resources.asar"
+func OpenAsar(name string) (*Asar, error) {
+	data, err := Asset(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 16 {
+		return nil, fmt.Errorf("Asar %s is too short", name)
+	}
+	headerSize := int(binary.LittleEndian.Uint32(data[4:8]))
+	jsonSize := int(binary.LittleEndian.Uint32(data[12:16]))
+	if 16+jsonSize > len(data) || 8+headerSize > len(data) {
+		return nil, fmt.Errorf("Asar %s header is invalid", name)
+	}
+	a := &Asar{data: data[8+headerSize:], name: name}
+	if err = json.Unmarshal(data[16:16+jsonSize], &a.root); err != nil {
+		return nil, fmt.Errorf("Asar %s header is invalid: %v", name, err)
+	}
+	return a, nil
+}
+
+func (a *Asar) entry(name string) (*bindAsarEntry, error) {
+	e := a.root
+	for _, s := range strings.Split(strings.Trim(strings.Replace(name, "\\", "/", -1), "/"), "/") {
+		if s == "" {
+			continue
+		}
+		if e.Files == nil || e.Files[s] == nil {
+			return nil, fmt.Errorf("%s not found in %s", name, a.name)
+		}
+		e = e.Files[s]
+	}
+	return e, nil
+}
+
+// ReadFile returns the content of a file of the archive
+func (a *Asar) ReadFile(name string) ([]byte, error) {
+	e, err := a.entry(name)
+	if err != nil {
+		return nil, err
+	}
+	if e.Files != nil {
+		return nil, fmt.Errorf("%s is a dir in %s", name, a.name)
+	}
+	if e.Unpacked {
+		return Asset(a.name + ".unpacked/" + strings.Trim(strings.Replace(name, "\\", "/", -1), "/"))
+	}
+	offset, err := strconv.ParseInt(e.Offset, 10, 64)
+	if err != nil || offset < 0 || offset+e.Size > int64(len(a.data)) {
+		return nil, fmt.Errorf("%s offset is invalid in %s", name, a.name)
+	}
+	return a.data[offset : offset+e.Size], nil
+}
+
+// ReadDir returns the sorted names of the entries of a dir of the archive
+func (a *Asar) ReadDir(name string) ([]string, error) {
+	e, err := a.entry(name)
+	if err != nil {
+		return nil, err
+	}
+	if e.Files == nil {
+		return nil, fmt.Errorf("%s is not a dir in %s", name, a.name)
+	}
+	var names []string
+	for n := range e.Files {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Names returns the sorted names of the files of the archive
+func (a *Asar) Names() []string {
+	var names []string
+	var walk func(prefix string, e *bindAsarEntry)
+	walk = func(prefix string, e *bindAsarEntry) {
+		for n, c := range e.Files {
+			if c.Files != nil {
+				walk(prefix+n+"/", c)
+			} else {
+				names = append(names, prefix+n)
+			}
+		}
+	}
+	walk("", a.root)
+	sort.Strings(names)
+	return names
+}
+
+// Extract writes the files of the archive as regular files in the given directory
+func (a *Asar) Extract(dir string) error {
+	for _, n := range a.Names() {
+		e, err := a.entry(n)
+		if err != nil {
+			return err
+		}
+		b, err := a.ReadFile(n)
+		if err != nil {
+			return err
+		}
+		p := filepath.Join(append([]string{dir}, strings.Split(n, "/")...)...)
+		if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return err
+		}
+		mode := os.FileMode(0644)
+		if e.Executable {
+			mode = 0755
+		}
+		if err = ioutil.WriteFile(p, b, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+`))
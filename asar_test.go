@@ -0,0 +1,94 @@
+package astibundler
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackResources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	b, err := New(&Configuration{
+		InputPath:             filepath.Join(dir, "app"),
+		ResourcesASAR:         true,
+		ResourcesASARUnpacked: []string{"*.node"},
+		WorkingDirectoryPath:  filepath.Join(dir, "wd"),
+	}, log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, "wd/bind/resources/app/index.html", "<html>")
+	writeTestFile(t, dir, "wd/bind/resources/app/lib/main.js", "console.log(1)")
+	writeTestFile(t, dir, "wd/bind/resources/app/lib/addon.node", "native")
+	writeTestFile(t, dir, "wd/bind/resources/empty.txt", "")
+	writeTestFile(t, dir, "app/go.mod", "module app\n\ngo 1.13\n")
+	writeTestFile(t, dir, "app/main.go", `package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	fmt.Println(AssetNames())
+	a, err := OpenAsar("resources.asar")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(a.Names())
+	fmt.Println(a.ReadDir("app/lib"))
+	for _, n := range []string{"app/index.html", "app\\lib\\main.js", "app/lib/addon.node", "empty.txt"} {
+		b, err := a.ReadFile(n)
+		fmt.Printf("%s %q %v\n", n, b, err)
+	}
+	_, err = a.ReadFile("app/missing.js")
+	fmt.Println(err)
+	d, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(d)
+	fmt.Println(a.Extract(d))
+	b, _ := ioutil.ReadFile(filepath.Join(d, "app", "lib", "main.js"))
+	fmt.Println(string(b))
+}
+`)
+
+	err = b.packResources()
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "wd", "bind", "resources"))
+	assert.True(t, os.IsNotExist(err))
+	err = b.writeBindFile(runtime.GOOS, runtime.GOARCH)
+	assert.NoError(t, err)
+
+	o, err := exec.Command("gofmt", "-l", filepath.Join(dir, "app")).CombinedOutput()
+	assert.NoError(t, err)
+	assert.Empty(t, string(o))
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = filepath.Join(dir, "app")
+	o, err = cmd.CombinedOutput()
+	assert.NoError(t, err, string(o))
+	assert.Equal(t, `[resources.asar resources.asar.unpacked/app/lib/addon.node]
+[app/index.html app/lib/addon.node app/lib/main.js empty.txt]
+[addon.node main.js] <nil>
+app/index.html "<html>" <nil>
+app\lib\main.js "console.log(1)" <nil>
+app/lib/addon.node "native" <nil>
+empty.txt "" <nil>
+app/missing.js not found in resources.asar
+<nil>
+console.log(1)
+`, string(o))
+}
@@ -13,33 +13,68 @@ import (
 	astibundler "github.com/asticode/go-astilectron-bundler"
 )
 
-var ldflags = LDFlags{}
+var (
+	environments = astikit.NewFlagStrings()
+	ldflags      = LDFlags{}
+)
 
 // Flags
 var (
+	adapters          = flag.Bool("adapters", false, "if set, watch will execute resources adapters when binding data")
+	arch              = flag.String("arch", runtime.GOARCH, "the arch of environments added with -d, -l and -w")
 	astilectronPath   = flag.String("a", "", "the astilectron path")
 	configurationPath = flag.String("c", "", "the configuration path")
-	darwin            = flag.Bool("d", false, "if set, will add darwin/amd64 to the environments")
-	linux             = flag.Bool("l", false, "if set, will add linux/amd64 to the environments")
+	darwin            = flag.Bool("d", false, "if set, will add darwin/<arch> to the environments")
+	dev               = flag.Bool("dev", false, "if set, bd and watch will bind data in dev mode: resources are read from disk at runtime")
+	dryRun            = flag.Bool("dry-run", false, "if set, will print what bundling would do instead of bundling")
+	force             = flag.Bool("force", false, "if set, will bundle environments even if they are up to date")
+	fromVersion       = flag.String("from-version", "", "the version of the previous release diff produces patches from, defaults to the version of its update feeds")
+	format            = flag.String("format", "", "the configuration format used by init (json, toml or yaml)")
+	linux             = flag.Bool("l", false, "if set, will add linux/<arch> to the environments")
 	outputPath        = flag.String("o", "", "the output path")
-	windows           = flag.Bool("w", false, "if set, will add windows/amd64 to the environments")
+	overwrite         = flag.Bool("overwrite", false, "if set, init will overwrite the existing configuration file")
+	profile           = flag.String("profile", "", "the configuration profile")
+	publicKey         = flag.String("public-key", "", "the public key verify checks the SHA256SUMS.minisig signature with")
+	toVersion         = flag.String("to-version", "", "the version of the current release diff produces patches to, defaults to the version of its update feeds")
+	windows           = flag.Bool("w", false, "if set, will add windows/<arch> to the environments")
 )
 
 func init() {
+	flag.Var(environments, "env", "only keeps configured environments matching this os/arch pattern, eg linux/*. can be set several times")
 	flag.Var(ldflags, "ldflags", "extra values to concatenate onto -ldflags, eg X:main.Version=1.0.7")
 }
 
 func main() {
 	// Parse flags
 	cmd := astikit.FlagCmd()
+	var subCmd string
+	if cmd == "config" {
+		subCmd = astikit.FlagCmd()
+	}
 	flag.Parse()
 
 	// Create logger
 	l := log.New(log.Writer(), log.Prefix(), log.Flags())
 
+	// Init doesn't need a configuration
+	var err error
+	if cmd == "init" {
+		var p string
+		if p, err = astibundler.Scaffold(astibundler.ScaffoldOptions{
+			Format:    *format,
+			Overwrite: *overwrite,
+		}); err != nil {
+			l.Fatal(fmt.Errorf("scaffolding configuration failed: %w", err))
+		}
+		l.Printf("Configuration written to %s", p)
+		if *format == astibundler.ConfigurationFormatJSON {
+			l.Println("JSON doesn't support comments: run \"astilectron-bundler schema > bundler.schema.json\" and point your editor to it to get the documentation of every key, or use -format yaml or toml")
+		}
+		return
+	}
+
 	// Get configuration path
 	var cp = *configurationPath
-	var err error
 	if len(cp) == 0 {
 		// Get working directory path
 		var wd string
@@ -47,21 +82,16 @@ func main() {
 			l.Fatal(fmt.Errorf("os.Getwd failed: %w", err))
 		}
 
-		// Set configuration path
-		cp = filepath.Join(wd, "bundler.json")
-	}
-
-	// Open file
-	var f *os.File
-	if f, err = os.Open(cp); err != nil {
-		l.Fatal(fmt.Errorf("opening file %s failed: %w", cp, err))
+		// Find configuration path
+		if cp, err = astibundler.FindConfigurationPath(wd); err != nil {
+			l.Fatal(fmt.Errorf("finding configuration path failed: %w", err))
+		}
 	}
-	defer f.Close()
 
-	// Unmarshal
+	// Load configuration
 	var c *astibundler.Configuration
-	if err = json.NewDecoder(f).Decode(&c); err != nil {
-		l.Fatal(fmt.Errorf("unmarshaling configuration failed: %w", err))
+	if c, err = astibundler.LoadConfiguration(cp, *profile); err != nil {
+		l.Fatal(fmt.Errorf("loading configuration failed: %w", err))
 	}
 
 	// Astilectron path
@@ -69,20 +99,26 @@ func main() {
 		c.AstilectronPath = *astilectronPath
 	}
 
+	// Force
+	c.Force = *force
+
 	// Output path
 	if len(*outputPath) > 0 {
 		c.OutputPath = *outputPath
 	}
 
 	// Environments
+	if err = c.FilterEnvironments(*environments.Slice); err != nil {
+		l.Fatal(fmt.Errorf("filtering environments failed: %w", err))
+	}
 	if *darwin {
-		c.Environments = append(c.Environments, astibundler.ConfigurationEnvironment{Arch: runtime.GOARCH, OS: "darwin"})
+		c.Environments = append(c.Environments, astibundler.ConfigurationEnvironment{Arch: *arch, OS: "darwin"})
 	}
 	if *linux {
-		c.Environments = append(c.Environments, astibundler.ConfigurationEnvironment{Arch: runtime.GOARCH, OS: "linux"})
+		c.Environments = append(c.Environments, astibundler.ConfigurationEnvironment{Arch: *arch, OS: "linux"})
 	}
 	if *windows {
-		c.Environments = append(c.Environments, astibundler.ConfigurationEnvironment{Arch: runtime.GOARCH, OS: "windows"})
+		c.Environments = append(c.Environments, astibundler.ConfigurationEnvironment{Arch: *arch, OS: "windows"})
 	}
 	if len(c.Environments) == 0 {
 		c.Environments = []astibundler.ConfigurationEnvironment{{Arch: runtime.GOARCH, OS: runtime.GOOS}}
@@ -94,6 +130,69 @@ func main() {
 	}
 	c.LDFlags.Merge(astibundler.LDFlags(ldflags))
 
+	// Configuration commands don't need a bundler
+	switch cmd {
+	case "config":
+		switch subCmd {
+		case "print":
+			// Print configuration
+			e := json.NewEncoder(os.Stdout)
+			e.SetIndent("", "  ")
+			if err = e.Encode(c); err != nil {
+				l.Fatal(fmt.Errorf("printing configuration failed: %w", err))
+			}
+		default:
+			l.Fatal(fmt.Errorf("config subcommand %s is invalid", subCmd))
+		}
+		return
+	case "schema":
+		// Print schema
+		if _, err = os.Stdout.Write(astibundler.Schema()); err != nil {
+			l.Fatal(fmt.Errorf("printing schema failed: %w", err))
+		}
+		return
+	case "diff":
+		// Get dirs
+		if len(flag.Arg(0)) == 0 {
+			l.Fatal("the output path of the previous release is missing")
+		}
+		dir := flag.Arg(1)
+		if len(dir) == 0 {
+			dir = defaultOutputPath(c, l)
+		}
+
+		// Diff
+		if _, err = astibundler.Diff(astibundler.DiffOptions{
+			FromPath:    flag.Arg(0),
+			FromVersion: *fromVersion,
+			ToPath:      dir,
+			ToVersion:   *toVersion,
+		}, l); err != nil {
+			l.Fatal(fmt.Errorf("diffing %s and %s failed: %w", flag.Arg(0), dir, err))
+		}
+		return
+	case "verify":
+		// Get dir
+		dir := flag.Arg(0)
+		if len(dir) == 0 {
+			dir = defaultOutputPath(c, l)
+		}
+
+		// Verify checksums
+		if err = astibundler.VerifyChecksums(dir, *publicKey); err != nil {
+			l.Fatal(fmt.Errorf("verifying checksums of %s failed: %w", dir, err))
+		}
+		l.Printf("Checksums of %s are valid", dir)
+		return
+	case "validate":
+		// Validate configuration
+		if err = c.Validate(); err != nil {
+			l.Fatal(fmt.Errorf("validating configuration failed: %w", err))
+		}
+		l.Printf("Configuration %s is valid", cp)
+		return
+	}
+
 	// Build bundler
 	var b *astibundler.Bundler
 	if b, err = astibundler.New(c, l); err != nil {
@@ -108,7 +207,12 @@ func main() {
 	case "bd":
 		// Bind Data
 		for _, env := range c.Environments {
-			if err = b.BindData(env.OS, env.Arch); err != nil {
+			if *dev {
+				err = b.BindDevData(env.OS, env.Arch)
+			} else {
+				err = b.BindData(env.OS, env.Arch)
+			}
+			if err != nil {
 				l.Fatal(fmt.Errorf("binding data failed for %s/%s: %w", env.OS, env.Arch, err))
 			}
 		}
@@ -117,10 +221,68 @@ func main() {
 		if err = b.ClearCache(); err != nil {
 			l.Fatal(fmt.Errorf("clearing cache failed: %w", err))
 		}
+	case "watch":
+		// Watch
+		if err = b.Watch(astibundler.WatchOptions{
+			Args:              flag.Args(),
+			Dev:               *dev,
+			ResourcesAdapters: *adapters,
+		}); err != nil {
+			l.Fatal(fmt.Errorf("watching failed: %w", err))
+		}
+	case "plan":
+		// Plan
+		printPlan(b, l)
+	case "publish":
+		// Get result
+		var r astibundler.BundleResult
+		if r, err = b.Result(); err != nil {
+			l.Fatal(fmt.Errorf("getting result failed: %w", err))
+		}
+
+		// Publish
+		if err = b.Publish(r); err != nil {
+			l.Fatal(fmt.Errorf("publishing failed: %w", err))
+		}
+	case "release":
+		// Release
+		if err = b.Release(); err != nil {
+			l.Fatal(fmt.Errorf("releasing failed: %w", err))
+		}
 	default:
+		// Dry run
+		if *dryRun {
+			printPlan(b, l)
+			return
+		}
+
 		// Bundle
 		if err = b.Bundle(); err != nil {
 			l.Fatal(fmt.Errorf("bundling failed: %w", err))
 		}
 	}
 }
+
+// defaultOutputPath returns the output path of the configuration
+func defaultOutputPath(c *astibundler.Configuration, l *log.Logger) string {
+	if len(c.OutputPath) > 0 {
+		return c.OutputPath
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		l.Fatal(fmt.Errorf("os.Getwd failed: %w", err))
+	}
+	return filepath.Join(wd, "output")
+}
+
+func printPlan(b *astibundler.Bundler, l *log.Logger) {
+	ps, err := b.Plan()
+	if err != nil {
+		l.Fatal(fmt.Errorf("planning failed: %w", err))
+	}
+	for _, p := range ps {
+		if err = p.Write(os.Stdout); err != nil {
+			l.Fatal(fmt.Errorf("writing plan failed: %w", err))
+		}
+	}
+}
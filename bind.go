@@ -0,0 +1,492 @@
+package astibundler
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression algorithms
+const (
+	CompressionAlgorithmGzip = "gzip"
+	CompressionAlgorithmNone = "none"
+	CompressionAlgorithmZstd = "zstd"
+)
+
+// compressedExtensions are the extensions of files that are already compressed and are therefore not
+// compressed again unless a compression rule matches them
+var compressedExtensions = map[string]bool{
+	".7z":    true,
+	".avif":  true,
+	".br":    true,
+	".bz2":   true,
+	".gif":   true,
+	".gz":    true,
+	".jpeg":  true,
+	".jpg":   true,
+	".mp3":   true,
+	".mp4":   true,
+	".ogg":   true,
+	".png":   true,
+	".webm":  true,
+	".webp":  true,
+	".woff":  true,
+	".woff2": true,
+	".xz":    true,
+	".zip":   true,
+	".zst":   true,
+}
+
+// compressionRule represents a parsed bind compression configuration
+type compressionRule struct {
+	algorithm string
+	level     int
+	pattern   pathPatterns
+}
+
+func newCompressionRule(c ConfigurationBindCompression) (r compressionRule, err error) {
+	// Algorithm and level
+	switch c.Algorithm {
+	case CompressionAlgorithmGzip:
+		if c.Level != 0 && (c.Level < gzip.BestSpeed || c.Level > gzip.BestCompression) {
+			err = fmt.Errorf("gzip level %d is not between %d and %d", c.Level, gzip.BestSpeed, gzip.BestCompression)
+			return
+		}
+	case CompressionAlgorithmNone:
+		if c.Level != 0 {
+			err = fmt.Errorf("level can't be set without compression")
+			return
+		}
+	case CompressionAlgorithmZstd:
+		if c.Level < 0 || c.Level > 22 {
+			err = fmt.Errorf("zstd level %d is not between 1 and 22", c.Level)
+			return
+		}
+	default:
+		err = fmt.Errorf("algorithm %s is invalid", c.Algorithm)
+		return
+	}
+
+	// Pattern
+	var p pathPattern
+	if p, err = newPathPattern(c.Pattern); err != nil {
+		err = fmt.Errorf("pattern %s is invalid: %w", c.Pattern, err)
+		return
+	} else if p.negated {
+		err = fmt.Errorf("pattern %s can't be negated", c.Pattern)
+		return
+	}
+	r = compressionRule{
+		algorithm: c.Algorithm,
+		level:     c.Level,
+		pattern:   pathPatterns{p},
+	}
+	return
+}
+
+// compressionRule returns the first compression rule matching a slash separated path relative to the
+// bind dir. If none matches, files are gzipped unless they're already compressed.
+func (b *Bundler) compressionRule(name string) compressionRule {
+	for _, r := range b.bindCompression {
+		if r.pattern.match(name, false) {
+			return r
+		}
+	}
+	if compressedExtensions[strings.ToLower(filepath.Ext(name))] {
+		return compressionRule{algorithm: CompressionAlgorithmNone}
+	}
+	return compressionRule{algorithm: CompressionAlgorithmGzip}
+}
+
+// compress compresses data following a rule
+func compress(data []byte, r compressionRule) (o []byte, err error) {
+	switch r.algorithm {
+	case CompressionAlgorithmGzip:
+		// Create writer
+		level := r.level
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		buf := &bytes.Buffer{}
+		var w *gzip.Writer
+		if w, err = gzip.NewWriterLevel(buf, level); err != nil {
+			err = fmt.Errorf("creating gzip writer failed: %w", err)
+			return
+		}
+
+		// Write
+		if _, err = w.Write(data); err != nil {
+			err = fmt.Errorf("writing failed: %w", err)
+			return
+		}
+		if err = w.Close(); err != nil {
+			err = fmt.Errorf("closing gzip writer failed: %w", err)
+			return
+		}
+		o = buf.Bytes()
+	case CompressionAlgorithmZstd:
+		// Create encoder
+		level := zstd.SpeedDefault
+		if r.level > 0 {
+			level = zstd.EncoderLevelFromZstd(r.level)
+		}
+		var e *zstd.Encoder
+		if e, err = zstd.NewWriter(nil, zstd.WithEncoderLevel(level)); err != nil {
+			err = fmt.Errorf("creating zstd encoder failed: %w", err)
+			return
+		}
+		defer e.Close()
+
+		// Encode
+		o = e.EncodeAll(data, nil)
+	default:
+		o = data
+	}
+	return
+}
+
+// writeBindFile writes a bind file embedding every file of the bind dir, each of them being compressed
+// following the compression rules. The generated API is the same as go-bindata's.
+func (b *Bundler) writeBindFile(oS, arch string) (err error) {
+	// List files
+	var ps []string
+	if ps, err = listFiles(b.pathBindInput, func(p string, fi os.FileInfo) bool { return true }); err != nil {
+		err = fmt.Errorf("listing files of %s failed: %w", b.pathBindInput, err)
+		return
+	}
+
+	// Get rules
+	var names []string
+	var rules []compressionRule
+	algorithms := make(map[string]bool)
+	for _, p := range ps {
+		rp, _ := filepath.Rel(b.pathBindInput, p)
+		n := filepath.ToSlash(rp)
+		r := b.compressionRule(n)
+		algorithms[r.algorithm] = true
+		names = append(names, n)
+		rules = append(rules, r)
+	}
+
+	// Create file
+	p := b.bindOutputPath(oS, arch)
+	b.l.Debugf("Generating %s", p)
+	var f *os.File
+	if f, err = os.Create(p); err != nil {
+		err = fmt.Errorf("creating %s failed: %w", p, err)
+		return
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	// Write header
+	if err = bindHeaderTemplate.Execute(w, bindHeaderData{
+		Arch:    arch,
+		Gzip:    algorithms[CompressionAlgorithmGzip],
+		OS:      oS,
+		Package: b.bindPackage,
+		Zstd:    algorithms[CompressionAlgorithmZstd],
+	}); err != nil {
+		err = fmt.Errorf("executing header template failed: %w", err)
+		return
+	}
+
+	// Loop through files
+	var rawSize, boundSize int64
+	for idx, p := range ps {
+		// Check context error
+		if b.ctx.Err() != nil {
+			return b.ctx.Err()
+		}
+
+		// Stat
+		var fi os.FileInfo
+		if fi, err = os.Stat(p); err != nil {
+			err = fmt.Errorf("stating %s failed: %w", p, err)
+			return
+		}
+
+		// Read
+		var data []byte
+		if data, err = ioutil.ReadFile(p); err != nil {
+			err = fmt.Errorf("reading %s failed: %w", p, err)
+			return
+		}
+
+		// Compress
+		algorithm := rules[idx].algorithm
+		var compressed []byte
+		if compressed, err = compress(data, rules[idx]); err != nil {
+			err = fmt.Errorf("compressing %s with %s failed: %w", p, algorithm, err)
+			return
+		}
+
+		// Compression doesn't pay off
+		if len(compressed) >= len(data) {
+			algorithm = CompressionAlgorithmNone
+			compressed = data
+		}
+		rawSize += int64(len(data))
+		boundSize += int64(len(compressed))
+
+		// Write asset
+		fmt.Fprintf(w, "\t%q: {\n\t\tcompression: %q,\n\t\tdata:        \"", names[idx], algorithm)
+		writeEscapedString(w, compressed)
+		fmt.Fprintf(w, "\",\n\t\tmode:        %#o,\n\t\tmodTime:     %d,\n\t\tsize:        %d,\n\t},\n", uint32(fi.Mode()), fi.ModTime().Unix(), fi.Size())
+	}
+
+	// Write footer
+	if _, err = w.WriteString("}\n" + bindFooterSource + bindCommonSource); err != nil {
+		err = fmt.Errorf("writing footer failed: %w", err)
+		return
+	}
+
+	// Flush
+	if err = w.Flush(); err != nil {
+		err = fmt.Errorf("flushing failed: %w", err)
+		return
+	}
+	b.l.Debugf("Bound %d files: %d bytes compressed into %d bytes", len(ps), rawSize, boundSize)
+	return
+}
+
+// writeEscapedString writes data as the content of an interpreted string literal
+func writeEscapedString(w io.ByteWriter, data []byte) {
+	const hex = "0123456789abcdef"
+	for _, c := range data {
+		w.WriteByte('\\')
+		w.WriteByte('x')
+		w.WriteByte(hex[c>>4])
+		w.WriteByte(hex[c&0xf])
+	}
+}
+
+type bindHeaderData struct {
+	Arch    string
+	Gzip    bool
+	OS      string
+	Package string
+	Zstd    bool
+}
+
+var bindHeaderTemplate = template.Must(template.New("header").Parse(`// Code generated by astilectron-bundler.
+// DO NOT EDIT!
+
+//go:build {{ .OS }} && {{ .Arch }}
+// +build {{ .OS }},{{ .Arch }}
+
+package {{ .Package }}
+
+import (
+{{- if .Gzip }}
+	"compress/gzip"
+{{- end }}
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+{{- if .Zstd }}
+
+	"github.com/klauspost/compress/zstd"
+{{- end }}
+)
+
+type bindAsset struct {
+	compression string
+	data        string
+	mode        uint32
+	modTime     int64
+	size        int64
+}
+
+// bindDecode decodes the data of an asset
+func bindDecode(name string, a bindAsset) ([]byte, error) {
+	switch a.compression {
+{{- if .Gzip }}
+	case "gzip":
+		r, err := gzip.NewReader(strings.NewReader(a.data))
+		if err != nil {
+			return nil, fmt.Errorf("Read %q: %v", name, err)
+		}
+		defer r.Close()
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("Read %q: %v", name, err)
+		}
+		return b, nil
+{{- end }}
+{{- if .Zstd }}
+	case "zstd":
+		d, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("Read %q: %v", name, err)
+		}
+		defer d.Close()
+		b, err := d.DecodeAll([]byte(a.data), nil)
+		if err != nil {
+			return nil, fmt.Errorf("Read %q: %v", name, err)
+		}
+		return b, nil
+{{- end }}
+	}
+	return []byte(a.data), nil
+}
+
+// bindAssets are the assets indexed by name
+var bindAssets = map[string]bindAsset{
+`))
+
+const bindFooterSource = `
+type bindFileInfo struct {
+	name string
+	a    bindAsset
+}
+
+func (fi bindFileInfo) Name() string       { return fi.name }
+func (fi bindFileInfo) Size() int64        { return fi.a.size }
+func (fi bindFileInfo) Mode() os.FileMode  { return os.FileMode(fi.a.mode) }
+func (fi bindFileInfo) ModTime() time.Time { return time.Unix(fi.a.modTime, 0) }
+func (fi bindFileInfo) IsDir() bool        { return false }
+func (fi bindFileInfo) Sys() interface{}   { return nil }
+
+// Asset loads and returns the asset for the given name.
+// It returns an error if the asset could not be found or
+// could not be loaded.
+func Asset(name string) ([]byte, error) {
+	cannonicalName := strings.Replace(name, "\\", "/", -1)
+	a, ok := bindAssets[cannonicalName]
+	if !ok {
+		return nil, fmt.Errorf("Asset %s not found", name)
+	}
+	return bindDecode(name, a)
+}
+
+// AssetInfo loads and returns the asset info for the given name.
+// It returns an error if the asset could not be found or
+// could not be loaded.
+func AssetInfo(name string) (os.FileInfo, error) {
+	cannonicalName := strings.Replace(name, "\\", "/", -1)
+	a, ok := bindAssets[cannonicalName]
+	if !ok {
+		return nil, fmt.Errorf("AssetInfo %s not found", name)
+	}
+	return bindFileInfo{name: filepath.Base(cannonicalName), a: a}, nil
+}
+
+// AssetNames returns the names of the assets.
+func AssetNames() []string {
+	names := make([]string, 0, len(bindAssets))
+	for name := range bindAssets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+`
+
+// bindCommonSource is the source shared by every kind of bind file. It relies on Asset, AssetInfo and
+// AssetNames.
+const bindCommonSource = `
+// MustAsset is like Asset but panics when Asset would return an error.
+// It simplifies safe initialization of global variables.
+func MustAsset(name string) []byte {
+	a, err := Asset(name)
+	if err != nil {
+		panic("asset: Asset(" + name + "): " + err.Error())
+	}
+	return a
+}
+
+// AssetDir returns the file names below a certain
+// directory embedded in the file by go-bindata.
+// For example if you run go-bindata on data/... and data contains the
+// following hierarchy:
+//
+//	data/
+//	  foo.txt
+//	  img/
+//	    a.png
+//	    b.png
+//
+// then AssetDir("data") would return []string{"foo.txt", "img"}
+// AssetDir("data/img") would return []string{"a.png", "b.png"}
+// AssetDir("foo.txt") and AssetDir("notexist") would return an error
+// AssetDir("") will return []string{"data"}.
+func AssetDir(name string) ([]string, error) {
+	cannonicalName := strings.Trim(strings.Replace(name, "\\", "/", -1), "/")
+	var children []string
+	found := make(map[string]bool)
+	for _, n := range AssetNames() {
+		if cannonicalName != "" {
+			if !strings.HasPrefix(n, cannonicalName+"/") {
+				continue
+			}
+			n = strings.TrimPrefix(n, cannonicalName+"/")
+		}
+		child := strings.Split(n, "/")[0]
+		if !found[child] {
+			found[child] = true
+			children = append(children, child)
+		}
+	}
+	if len(children) == 0 {
+		return nil, fmt.Errorf("Asset %s not found", name)
+	}
+	return children, nil
+}
+
+// RestoreAsset restores an asset under the given directory
+func RestoreAsset(dir, name string) error {
+	data, err := Asset(name)
+	if err != nil {
+		return err
+	}
+	info, err := AssetInfo(name)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(bindFilePath(dir, filepath.Dir(name)), os.FileMode(0755))
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(bindFilePath(dir, name), data, info.Mode())
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(bindFilePath(dir, name), info.ModTime(), info.ModTime())
+}
+
+// RestoreAssets restores an asset under the given directory recursively
+func RestoreAssets(dir, name string) error {
+	children, err := AssetDir(name)
+	// File
+	if err != nil {
+		return RestoreAsset(dir, name)
+	}
+	// Dir
+	for _, child := range children {
+		err = RestoreAssets(dir, filepath.Join(name, child))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func bindFilePath(dir, name string) string {
+	cannonicalName := strings.Replace(name, "\\", "/", -1)
+	return filepath.Join(append([]string{dir}, strings.Split(cannonicalName, "/")...)...)
+}
+`
@@ -0,0 +1,128 @@
+package astibundler
+
+import (
+	"bufio"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteBindFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	b, err := New(&Configuration{
+		Bind: ConfigurationBind{Compression: []ConfigurationBindCompression{
+			{Algorithm: CompressionAlgorithmZstd, Level: 19, Pattern: "resources/**/*.js"},
+			{Algorithm: CompressionAlgorithmNone, Pattern: "*.txt"},
+		}},
+		InputPath:            filepath.Join(dir, "app"),
+		WorkingDirectoryPath: filepath.Join(dir, "wd"),
+	}, log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, "wd/bind/resources/app/index.html", strings.Repeat("<p>html</p>", 100))
+	writeTestFile(t, dir, "wd/bind/resources/app/lib/main.js", strings.Repeat("console.log(1);", 100))
+	writeTestFile(t, dir, "wd/bind/resources/app/notes.txt", strings.Repeat("notes", 100))
+	writeTestFile(t, dir, "wd/bind/vendor_astilectron_bundler/electron.zip", "zip")
+	writeTestFile(t, dir, "app/main.go", `package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	fmt.Println(AssetNames())
+	fmt.Println(AssetDir("resources/app"))
+	for _, n := range []string{"resources/app/index.html", "resources\\app\\lib\\main.js", "resources/app/notes.txt"} {
+		b := MustAsset(n)
+		fmt.Println(n, len(b), string(b[:4]))
+	}
+	fmt.Println(bindAssets["resources/app/index.html"].compression, bindAssets["resources/app/lib/main.js"].compression, bindAssets["resources/app/notes.txt"].compression, bindAssets["vendor_astilectron_bundler/electron.zip"].compression)
+	d, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(d)
+	fmt.Println(RestoreAssets(d, "resources"))
+	b, _ := ioutil.ReadFile(filepath.Join(d, "resources", "app", "lib", "main.js"))
+	fmt.Println(len(b))
+}
+`)
+
+	// Copy the zstd requirement
+	goMod := "module app\n\ngo 1.13\n\nrequire github.com/klauspost/compress v1.11.13\n"
+	writeTestFile(t, dir, "app/go.mod", goMod)
+	f, err := os.Open("go.sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var goSum []string
+	for s := bufio.NewScanner(f); s.Scan(); {
+		if strings.HasPrefix(s.Text(), "github.com/klauspost/compress v1.11.13") {
+			goSum = append(goSum, s.Text())
+		}
+	}
+	writeTestFile(t, dir, "app/go.sum", strings.Join(goSum, "\n")+"\n")
+
+	err = b.writeBindFile(runtime.GOOS, runtime.GOARCH)
+	assert.NoError(t, err)
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = filepath.Join(dir, "app")
+	o, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(o))
+	assert.Equal(t, `[resources/app/index.html resources/app/lib/main.js resources/app/notes.txt vendor_astilectron_bundler/electron.zip]
+[index.html lib notes.txt] <nil>
+resources/app/index.html 1100 <p>h
+resources\app\lib\main.js 1500 cons
+resources/app/notes.txt 500 note
+gzip zstd none none
+<nil>
+1500
+`, string(o))
+}
+
+func TestBindDataCompressedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	b, err := New(&Configuration{
+		InputPath:            filepath.Join(dir, "app"),
+		VersionAstilectron:   "0.49.0",
+		VersionElectron:      "11.4.3",
+		WorkingDirectoryPath: filepath.Join(dir, "wd"),
+	}, log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, "app/resources/index.html", strings.Repeat("<p>html</p>", 100))
+	writeTestFile(t, dir, "app/resources/logo.png", strings.Repeat("png", 100))
+	writeTestFile(t, dir, "wd/cache/astilectron-0.49.0.zip", "astilectron")
+	writeTestFile(t, dir, "wd/cache/electron-linux-amd64-11.4.3.zip", "electron")
+
+	// Without compression rules, already compressed files are stored as is even though gzip would
+	// make them smaller
+	assert.NoError(t, b.bindData("linux", "amd64", false))
+	c, err := ioutil.ReadFile(b.bindOutputPath("linux", "amd64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(c), "\"resources/index.html\": {\n\t\tcompression: \"gzip\",")
+	assert.Contains(t, string(c), "\"resources/logo.png\": {\n\t\tcompression: \"none\",")
+}
@@ -0,0 +1,261 @@
+package astibundler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/minify/v2/css"
+	"github.com/tdewolff/minify/v2/html"
+	"github.com/tdewolff/minify/v2/js"
+)
+
+// Builtin resources adapters
+const (
+	ResourcesAdapterMinifyCSS   = "builtin:minify-css"
+	ResourcesAdapterMinifyHTML  = "builtin:minify-html"
+	ResourcesAdapterMinifyJS    = "builtin:minify-js"
+	ResourcesAdapterOptimizePNG = "builtin:optimize-png"
+	ResourcesAdapterTemplate    = "builtin:template"
+)
+
+// builtinResourcesAdapterPrefix is the prefix of the names of builtin resources adapters
+const builtinResourcesAdapterPrefix = "builtin:"
+
+// builtinResourcesAdapter represents a resources adapter implemented in go and applied to files
+type builtinResourcesAdapter struct {
+	// Patterns of the files the adapter applies to by default
+	files []string
+	// Adapts a file and returns the number of bytes saved
+	fn func(p string, d resourcesAdapterData) (saved int64, err error)
+}
+
+// builtinResourcesAdapters are the builtin resources adapters indexed by name
+var builtinResourcesAdapters = map[string]builtinResourcesAdapter{
+	ResourcesAdapterMinifyCSS:   {files: []string{"*.css", "!*.min.css"}, fn: minifyFileFunc("text/css")},
+	ResourcesAdapterMinifyHTML:  {files: []string{"*.html", "*.htm"}, fn: minifyFileFunc("text/html")},
+	ResourcesAdapterMinifyJS:    {files: []string{"*.js", "!*.min.js"}, fn: minifyFileFunc("application/javascript")},
+	ResourcesAdapterOptimizePNG: {files: []string{"*.png"}, fn: optimizePNGFile},
+	ResourcesAdapterTemplate:    {files: []string{"*.tmpl"}, fn: templateFile},
+}
+
+// isBuiltinResourcesAdapter checks whether a resources adapter name refers to a builtin adapter
+func isBuiltinResourcesAdapter(name string) bool {
+	return strings.HasPrefix(name, builtinResourcesAdapterPrefix)
+}
+
+// validateBuiltin validates a builtin resources adapter
+func (a ConfigurationResourcesAdapter) validateBuiltin() (err error) {
+	// Name
+	if _, ok := builtinResourcesAdapters[a.Name]; !ok {
+		err = fmt.Errorf("builtin adapter %s doesn't exist", a.Name)
+		return
+	}
+
+	// Options
+	if len(a.Args) > 0 {
+		err = fmt.Errorf("args are not supported by builtin adapters")
+		return
+	} else if len(a.EnvironmentVariables) > 0 {
+		err = fmt.Errorf("env is not supported by builtin adapters")
+		return
+	}
+
+	// Files
+	if _, err = newPathPatterns(a.Files); err != nil {
+		err = fmt.Errorf("parsing files patterns failed: %w", err)
+		return
+	}
+	return
+}
+
+// runBuiltinResourcesAdapter applies a builtin resources adapter to the files of the resources dir it
+// matches
+func (b *Bundler) runBuiltinResourcesAdapter(ctx context.Context, a ConfigurationResourcesAdapter, d resourcesAdapterData) (err error) {
+	// Get adapter
+	ba, ok := builtinResourcesAdapters[a.Name]
+	if !ok {
+		err = fmt.Errorf("builtin adapter %s doesn't exist", a.Name)
+		return
+	}
+
+	// Get patterns
+	fs := a.Files
+	if len(fs) == 0 {
+		fs = ba.files
+	}
+	var ps pathPatterns
+	if ps, err = newPathPatterns(fs); err != nil {
+		err = fmt.Errorf("parsing files patterns failed: %w", err)
+		return
+	}
+
+	// List files
+	dir := d.ResourcesPath
+	if a.Dir != "" {
+		dir = filepath.Join(dir, a.Dir)
+	}
+	var paths []string
+	if paths, err = listFiles(dir, func(p string, fi os.FileInfo) bool { return true }); err != nil {
+		err = fmt.Errorf("listing files of %s failed: %w", dir, err)
+		return
+	}
+
+	// Loop through files
+	b.l.Debugf("Running %s in directory %s", a.Name, dir)
+	var count int
+	var saved int64
+	for _, p := range paths {
+		// Check context error
+		if ctx.Err() != nil {
+			err = ctx.Err()
+			return
+		}
+
+		// File doesn't match
+		rp, _ := filepath.Rel(dir, p)
+		if !ps.match(filepath.ToSlash(rp), false) {
+			continue
+		}
+
+		// Adapt
+		var s int64
+		if s, err = ba.fn(p, d); err != nil {
+			err = fmt.Errorf("adapting %s failed: %w", p, err)
+			return
+		}
+		count++
+		saved += s
+	}
+	b.l.Infof("%s: %d files adapted, %d bytes saved", a.Name, count, saved)
+	return
+}
+
+// minifyFileFunc returns a function minifying files of a media type
+func minifyFileFunc(mediaType string) func(p string, d resourcesAdapterData) (int64, error) {
+	return func(p string, d resourcesAdapterData) (saved int64, err error) {
+		// Read
+		var i []byte
+		if i, err = ioutil.ReadFile(p); err != nil {
+			err = fmt.Errorf("reading %s failed: %w", p, err)
+			return
+		}
+
+		// Minify
+		var o []byte
+		if o, err = newMinifier().Bytes(mediaType, i); err != nil {
+			err = fmt.Errorf("minifying %s failed: %w", p, err)
+			return
+		}
+
+		// Write
+		if err = ioutil.WriteFile(p, o, 0); err != nil {
+			err = fmt.Errorf("writing %s failed: %w", p, err)
+			return
+		}
+		saved = int64(len(i) - len(o))
+		return
+	}
+}
+
+// newMinifier creates a minifier handling js, css and html. Html is minified conservatively so that
+// it's still valid for tools parsing it afterwards
+func newMinifier() *minify.M {
+	m := minify.New()
+	m.AddFunc("application/javascript", js.Minify)
+	m.AddFunc("text/css", css.Minify)
+	m.Add("text/html", &html.Minifier{KeepDocumentTags: true, KeepEndTags: true, KeepQuotes: true})
+	return m
+}
+
+// optimizePNGFile encodes a png file with the best compression and keeps the result if it's smaller
+func optimizePNGFile(p string, d resourcesAdapterData) (saved int64, err error) {
+	// Read
+	var i []byte
+	if i, err = ioutil.ReadFile(p); err != nil {
+		err = fmt.Errorf("reading %s failed: %w", p, err)
+		return
+	}
+
+	// Decode
+	img, err := png.Decode(bytes.NewReader(i))
+	if err != nil {
+		err = fmt.Errorf("decoding %s failed: %w", p, err)
+		return
+	}
+
+	// Encode
+	buf := &bytes.Buffer{}
+	if err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(buf, img); err != nil {
+		err = fmt.Errorf("encoding %s failed: %w", p, err)
+		return
+	}
+
+	// Not smaller
+	if buf.Len() >= len(i) {
+		return
+	}
+
+	// Write
+	if err = ioutil.WriteFile(p, buf.Bytes(), 0); err != nil {
+		err = fmt.Errorf("writing %s failed: %w", p, err)
+		return
+	}
+	saved = int64(len(i) - buf.Len())
+	return
+}
+
+// templateFile executes a go template file and writes the result in the same file without its
+// ".tmpl" extension
+func templateFile(p string, d resourcesAdapterData) (saved int64, err error) {
+	// Stat
+	var fi os.FileInfo
+	if fi, err = os.Stat(p); err != nil {
+		err = fmt.Errorf("stating %s failed: %w", p, err)
+		return
+	}
+
+	// Parse
+	var t *template.Template
+	if t, err = template.New(filepath.Base(p)).Option("missingkey=error").Funcs(template.FuncMap{"env": os.Getenv}).ParseFiles(p); err != nil {
+		err = fmt.Errorf("parsing %s failed: %w", p, err)
+		return
+	}
+
+	// Shared adapters run once for every environment and therefore have no environment
+	if d.OS == "" && templateUsesEnvironment(t) {
+		err = fmt.Errorf("%s uses the {{.OS}} or {{.Arch}} templates but the adapter is not environment specific: set environment_specific to true", p)
+		return
+	}
+
+	// Execute
+	buf := &bytes.Buffer{}
+	if err = t.Execute(buf, d); err != nil {
+		err = fmt.Errorf("executing %s failed: %w", p, err)
+		return
+	}
+
+	// Write
+	o := strings.TrimSuffix(p, ".tmpl")
+	if err = ioutil.WriteFile(o, buf.Bytes(), fi.Mode()); err != nil {
+		err = fmt.Errorf("writing %s failed: %w", o, err)
+		return
+	}
+
+	// Remove template
+	if o != p {
+		if err = os.Remove(p); err != nil {
+			err = fmt.Errorf("removing %s failed: %w", p, err)
+			return
+		}
+	}
+	saved = fi.Size() - int64(buf.Len())
+	return
+}
@@ -2,6 +2,10 @@ package astibundler
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"go/build"
 	"io/ioutil"
@@ -9,14 +13,15 @@ import (
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
 	"syscall"
+	"text/template"
 	"time"
 
 	"github.com/akavel/rsrc/rsrc"
 	"github.com/asticode/go-astikit"
 	"github.com/asticode/go-astilectron"
-	"github.com/asticode/go-bindata"
 	"github.com/sam-kamerer/go-plister"
 )
 
@@ -26,24 +31,43 @@ type Configuration struct {
 	// It's also set as an ldflag and therefore accessible in a global var package_name.AppName
 	AppName string `json:"app_name"`
 
+	// The archive configuration
+	Archive ConfigurationArchive `json:"archive"`
+
 	// The bind configuration
 	Bind ConfigurationBind `json:"bind"`
 
+	// The path of the key signing the SHA256SUMS file written in the output path
+	// It's either an unencrypted minisign secret key or a PEM encoded ed25519 private key, and the
+	// signature is written in the minisign format in the SHA256SUMS.minisig file
+	ChecksumsSigningKeyPath string `json:"checksums_signing_key_path"`
+
 	// Whether the app is a darwin agent app
 	DarwinAgentApp bool `json:"darwin_agent_app"`
 
+	// Whether environments should be bundled even if they are up to date
+	// It can't be set in the configuration file
+	Force bool `json:"-"`
+
 	// List of environments the bundling should be done upon.
 	// An environment is a combination of OS and ARCH
 	Environments []ConfigurationEnvironment `json:"environments"`
 
+	// The path of a base configuration this configuration overrides. Objects are deep merged whereas
+	// other values, including lists, are replaced.
+	// This path is relative to the configuration file
+	Extends string `json:"extends,omitempty"`
+
 	// The path of the go binary
 	// Defaults to "go"
 	GoBinaryPath string `json:"go_binary_path"`
 
-	// Paths to icons
-	IconPathDarwin  string `json:"icon_path_darwin"` // .icns
-	IconPathLinux   string `json:"icon_path_linux"`
-	IconPathWindows string `json:"icon_path_windows"` // .ico
+	// The path of the darwin icon (.icns)
+	IconPathDarwin string `json:"icon_path_darwin"`
+	// The path of the linux icon (.png)
+	IconPathLinux string `json:"icon_path_linux"`
+	// The path of the windows icon (.ico)
+	IconPathWindows string `json:"icon_path_windows"`
 
 	// Info.plist property list
 	InfoPlist map[string]interface{} `json:"info_plist"`
@@ -69,15 +93,55 @@ type Configuration struct {
 	// Defaults to "output"
 	OutputPath string `json:"output_path"`
 
+	// Named partial configurations that can be deep merged on top of the configuration
+	Profiles map[string]map[string]interface{} `json:"profiles,omitempty"`
+
+	// The publish configuration
+	// When a bucket is set, artifacts are uploaded to S3-compatible storage once bundling is done
+	Publish ConfigurationPublish `json:"publish"`
+
+	// The release configuration
+	// When a base URL is set, update feeds are written in the output path once bundling is done
+	Release ConfigurationRelease `json:"release"`
+
 	// List of commands executed on resources
 	// Paths inside commands must be relative to the resources folder
 	ResourcesAdapters []ConfigurationResourcesAdapter `json:"resources_adapters"`
 
+	// Whether adapted resources are packed into an ASAR archive named "<resources path>.asar" which is
+	// bound instead of the resources folder
+	ResourcesASAR bool `json:"resources_asar"`
+
+	// Gitignore-style patterns of resources that are left out of the ASAR archive, eg "*.node"
+	// They're bound in the "<resources path>.asar.unpacked" folder
+	ResourcesASARUnpacked []string `json:"resources_asar_unpacked"`
+
+	// Gitignore-style patterns of resources that are not bound, eg "*.map" or "node_modules/"
+	// Patterns are relative to the resources folder, support "**" and can be negated with "!"
+	ResourcesExclude []string `json:"resources_exclude"`
+
+	// Gitignore-style patterns of resources renamed to include a hash of their content, eg "app.js"
+	// becomes "app.3f2a9c.js"
+	// References to them in html and css resources are rewritten and a manifest mapping logical names to
+	// hashed names is generated in the bind package
+	ResourcesHash []string `json:"resources_hash"`
+
+	// The number of hex characters of resources hashes
+	// Defaults to 6
+	ResourcesHashLength int `json:"resources_hash_length"`
+
+	// Gitignore-style patterns of resources that are bound
+	// Defaults to every resource
+	ResourcesInclude []string `json:"resources_include"`
+
 	// The path where the resources are/will be created
 	// This path must be relative to the input path
 	// Defaults to "resources"
 	ResourcesPath string `json:"resources_path"`
 
+	// The SBOM configuration
+	SBOM ConfigurationSBOM `json:"sbom"`
+
 	// Show Windows console
 	ShowWindowsConsole bool `json:"show_windows_console"`
 
@@ -86,6 +150,9 @@ type Configuration struct {
 	// Defaults to a temp directory
 	VendorDirPath string `json:"vendor_dir_path"`
 
+	// The third party notices configuration
+	ThirdPartyNotices ConfigurationThirdPartyNotices `json:"third_party_notices"`
+
 	// Version of Astilectron install
 	VersionAstilectron string `json:"version_astilectron"`
 
@@ -97,10 +164,34 @@ type Configuration struct {
 	WorkingDirectoryPath string `json:"working_directory_path"`
 
 	//!\\ DEBUG ONLY
-	AstilectronPath string `json:"astilectron_path"` // when making changes to astilectron
+	// The path of a local astilectron directory when making changes to astilectron
+	AstilectronPath string `json:"astilectron_path"`
+}
+
+// ConfigurationArchive represents the configuration of the archives of environment output directories
+// Archives preserve unix modes and symlinks
+type ConfigurationArchive struct {
+	// Whether each environment output directory is archived in the output path
+	Enabled bool `json:"enabled"`
+
+	// The archive formats indexed by OS or by "<os>/<arch>": "zip", "tar.gz" or "tar.xz"
+	// Defaults to "zip" for darwin and windows and to "tar.gz" for linux
+	Formats map[string]string `json:"formats"`
+
+	// The name of the archives without extension
+	// It can use {{.AppName}}, {{.Arch}}, {{.OS}} and {{.Version}} templates, where the version is the
+	// release version
+	// Defaults to "{{.AppName}}-{{.Version}}-{{.OS}}-{{.Arch}}", or to "{{.AppName}}-{{.OS}}-{{.Arch}}"
+	// when there's no version
+	Name string `json:"name"`
 }
 
+// ConfigurationBind represents the bind configuration
 type ConfigurationBind struct {
+	// Compression rules of bound files, the first rule matching a file wins
+	// Files matching no rule are gzipped unless they're already compressed, such as zip files or images
+	Compression []ConfigurationBindCompression `json:"compression"`
+
 	// The path where the file will be written
 	// Defaults to the input path
 	OutputPath string `json:"output_path"`
@@ -110,52 +201,192 @@ type ConfigurationBind struct {
 	Package string `json:"package"`
 }
 
+// ConfigurationBindCompression represents a compression rule of bound files
+type ConfigurationBindCompression struct {
+	// The compression algorithm: "none", "gzip" or "zstd"
+	// Files using "zstd" require the app to depend on github.com/klauspost/compress
+	Algorithm string `json:"algorithm"`
+
+	// The compression level: from 1 to 9 for "gzip" and from 1 to 22 for "zstd"
+	// Defaults to the algorithm default level
+	Level int `json:"level"`
+
+	// Gitignore-style pattern of the files the rule applies to, relative to the bind dir
+	// eg "resources/**/*.js" or "vendor_astilectron_bundler/*.zip"
+	Pattern string `json:"pattern"`
+}
+
 // ConfigurationEnvironment represents the bundle configuration environment
 type ConfigurationEnvironment struct {
-	Arch                 string            `json:"arch"`
+	// The target architecture
+	// The OS/arch pair must be supported by go, see "go tool dist list"
+	Arch string `json:"arch"`
+
+	// Environment variables added to the go build command
 	EnvironmentVariables map[string]string `json:"env"`
-	OS                   string            `json:"os"`
+
+	// The target OS
+	OS string `json:"os"`
+}
+
+// ConfigurationPublish represents the configuration of the S3-compatible storage artifacts are uploaded to
+// Credentials are read from the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment
+// variables
+type ConfigurationPublish struct {
+	// The name of the bucket
+	Bucket string `json:"bucket"`
+
+	// The URL of the S3-compatible endpoint, eg "http://localhost:9000"
+	// Defaults to "https://s3.<region>.amazonaws.com"
+	Endpoint string `json:"endpoint"`
+
+	// The prefix of the object keys, which are the paths of the artifacts relative to the output path
+	// It can use {{.AppName}} and {{.Version}} templates, eg "{{.AppName}}/{{.Version}}"
+	Prefix string `json:"prefix"`
+
+	// The region of the bucket
+	// Defaults to the AWS_REGION environment variable or "us-east-1"
+	Region string `json:"region"`
+
+	// Whether the bucket is part of the host, eg "https://<bucket>.s3.amazonaws.com", rather than of the
+	// path, eg "https://s3.amazonaws.com/<bucket>"
+	VirtualHostedStyle bool `json:"virtual_hosted_style"`
+}
+
+// ConfigurationRelease represents the configuration of the update feeds: a Sparkle appcast.xml for darwin
+// environments and electron-updater style latest.yml, latest-mac.yml and latest-linux.yml feeds
+type ConfigurationRelease struct {
+	// The URL the artifacts are served from, which the file URLs of the feeds are built upon
+	// It can use {{.AppName}}, {{.Arch}}, {{.OS}} and {{.Version}} templates, eg
+	// "https://example.com/{{.Version}}/{{.OS}}-{{.Arch}}"
+	BaseURL string `json:"base_url"`
+
+	// The path of the file containing the release notes
+	NotesPath string `json:"notes_path"`
+
+	// The version of the release
+	// Defaults to the CFBundleShortVersionString of the Info.plist property list
+	Version string `json:"version"`
 }
 
+// ConfigurationResourcesAdapter represents a command executed on resources
 type ConfigurationResourcesAdapter struct {
+	// Arguments of the command
+	// They can use {{.AppName}}, {{.Arch}}, {{.OS}}, {{.ResourcesPath}}, {{.VersionAstilectron}} and
+	// {{.VersionElectron}} templates
 	Args []string `json:"args"`
-	Dir  string   `json:"dir"`
-	Name string   `json:"name"`
+
+	// The archs the command is executed for
+	// Defaults to every arch
+	Arch []string `json:"arch"`
+
+	// The directory the command is executed in
+	// This path must be relative to the resources folder
+	// Defaults to the resources folder
+	Dir string `json:"dir"`
+
+	// Whether the command output depends on the environment
+	// Adapters that are not environment specific run once per bundle and their output is shared by
+	// every environment, whereas environment specific adapters run for each environment afterwards
+	// Adapters with "os" or "arch" filters and adapters whose args or environment variables use the
+	// {{.OS}} or {{.Arch}} templates are always environment specific
+	EnvironmentSpecific bool `json:"environment_specific"`
+
+	// Environment variables added to the command
+	// They can use the same templates as arguments
+	EnvironmentVariables map[string]string `json:"env"`
+
+	// Gitignore-style patterns of the files builtin adapters apply to, relative to the adapter directory
+	// Defaults to the files the builtin adapter is meant for, eg "*.js" for "builtin:minify-js"
+	Files []string `json:"files"`
+
+	// The name of the command or of a builtin adapter: "builtin:minify-css", "builtin:minify-html",
+	// "builtin:minify-js", "builtin:optimize-png" or "builtin:template"
+	Name string `json:"name"`
+
+	// The OSes the command is executed for
+	// Defaults to every OS
+	OS []string `json:"os"`
+
+	// The duration after which the command is killed, eg "30s" or "5m"
+	// Defaults to no timeout
+	Timeout string `json:"timeout"`
+}
+
+// ConfigurationSBOM represents the configuration of the software bill of materials written next to the
+// artifacts of each environment
+type ConfigurationSBOM struct {
+	// Whether an SBOM is written
+	Enabled bool `json:"enabled"`
+
+	// Whether an SPDX document is written in addition to the CycloneDX one
+	SPDX bool `json:"spdx"`
+}
+
+// ConfigurationThirdPartyNotices represents the configuration of the THIRD_PARTY_NOTICES file gathering
+// the licenses of the go runtime, of the go modules the app depends on, of astilectron and of electron
+type ConfigurationThirdPartyNotices struct {
+	// Whether the file is also bound at the root of the resources folder
+	Bind bool `json:"bind"`
+
+	// Whether the file is written
+	Enabled bool `json:"enabled"`
 }
 
 // Bundler represents an object capable of bundling an Astilectron app
 type Bundler struct {
-	appName              string
-	bindPackage          string
-	buildFlags           map[string]string
-	cancel               context.CancelFunc
-	ctx                  context.Context
-	d                    *astikit.HTTPDownloader
-	darwinAgentApp       bool
-	environments         []ConfigurationEnvironment
-	infoPlist            map[string]interface{}
-	l                    astikit.SeverityLogger
-	ldflags              LDFlags
-	ldflagsPackage       string
-	pathAstilectron      string
-	pathBindInput        string
-	pathBindOutput       string
-	pathBuild            string
-	pathCache            string
-	pathIconDarwin       string
-	pathIconLinux        string
-	pathIconWindows      string
-	pathInput            string
-	pathGoBinary         string
-	pathOutput           string
-	pathResources        string
-	pathVendor           string
-	pathWorkingDirectory string
-	pathManifest         string
-	resourcesAdapters    []ConfigurationResourcesAdapter
-	showWindowsConsole   bool
-	versionAstilectron   string
-	versionElectron      string
+	appName               string
+	archiveFormats        map[string]string
+	archiveName           *template.Template
+	bindCompression       []compressionRule
+	bindPackage           string
+	buildFlags            map[string]string
+	cancel                context.CancelFunc
+	configurationHash     string
+	ctx                   context.Context
+	d                     *astikit.HTTPDownloader
+	darwinAgentApp        bool
+	environments          []ConfigurationEnvironment
+	force                 bool
+	infoPlist             map[string]interface{}
+	l                     astikit.SeverityLogger
+	ldflags               LDFlags
+	ldflagsPackage        string
+	noticesBind           bool
+	noticesEnabled        bool
+	pathAstilectron       string
+	pathBindInput         string
+	pathBindOutput        string
+	pathBuild             string
+	pathCache             string
+	pathIconDarwin        string
+	pathIconLinux         string
+	pathIconWindows       string
+	pathInput             string
+	pathGoBinary          string
+	pathOutput            string
+	pathReleaseNotes      string
+	pathResources         string
+	pathResourcesStaged   string
+	pathSigningKey        string
+	pathVendor            string
+	pathWorkingDirectory  string
+	pathManifest          string
+	publisher             *publisher
+	releaseBaseURL        *template.Template
+	releaseVersion        string
+	resourcesAdapters     []ConfigurationResourcesAdapter
+	resourcesASAR         bool
+	resourcesASARUnpacked pathPatterns
+	resourcesFilter       pathFilter
+	resourcesHash         pathPatterns
+	resourcesHashLength   int
+	resourcesStaged       bool
+	sbomEnabled           bool
+	sbomSPDX              bool
+	showWindowsConsole    bool
+	versionAstilectron    string
+	versionElectron       string
 }
 
 // absPath computes the absolute path
@@ -187,7 +418,9 @@ func New(c *Configuration, l astikit.StdLogger) (b *Bundler, err error) {
 		}),
 		environments:       c.Environments,
 		darwinAgentApp:     c.DarwinAgentApp,
+		force:              c.Force,
 		resourcesAdapters:  c.ResourcesAdapters,
+		resourcesASAR:      c.ResourcesASAR,
 		l:                  astikit.AdaptStdLogger(l),
 		ldflags:            c.LDFlags,
 		ldflagsPackage:     c.LDFlagsPackage,
@@ -213,6 +446,18 @@ func New(c *Configuration, l astikit.StdLogger) (b *Bundler, err error) {
 		b.buildFlags = c.BuildFlags
 	}
 
+	// Configuration hash. Environments are left out since each environment is hashed separately and
+	// filtering or adding environments must not invalidate the others.
+	hc := *c
+	hc.Environments = nil
+	var cb []byte
+	if cb, err = json.Marshal(hc); err != nil {
+		err = fmt.Errorf("marshaling configuration failed: %w", err)
+		return
+	}
+	h := sha256.Sum256(cb)
+	b.configurationHash = hex.EncodeToString(h[:])
+
 	// Add context
 	b.ctx, b.cancel = context.WithCancel(context.Background())
 
@@ -238,6 +483,7 @@ func New(c *Configuration, l astikit.StdLogger) (b *Bundler, err error) {
 	// Paths that depend on the working directory path
 	b.pathBindInput = filepath.Join(b.pathWorkingDirectory, "bind")
 	b.pathCache = filepath.Join(b.pathWorkingDirectory, "cache")
+	b.pathResourcesStaged = filepath.Join(b.pathWorkingDirectory, "resources")
 
 	// Darwin icon path
 	if b.pathIconDarwin, err = absPath(c.IconPathDarwin, nil); err != nil {
@@ -259,6 +505,16 @@ func New(c *Configuration, l astikit.StdLogger) (b *Bundler, err error) {
 		return
 	}
 
+	// Release notes path
+	if b.pathReleaseNotes, err = absPath(c.Release.NotesPath, nil); err != nil {
+		return
+	}
+
+	// Checksums signing key path
+	if b.pathSigningKey, err = absPath(c.ChecksumsSigningKeyPath, nil); err != nil {
+		return
+	}
+
 	// Input path
 	if b.pathInput, err = absPath(c.InputPath, os.Getwd); err != nil {
 		return
@@ -288,6 +544,89 @@ func New(c *Configuration, l astikit.StdLogger) (b *Bundler, err error) {
 		b.pathResources = "resources"
 	}
 
+	// Bind compression
+	for idx, bc := range c.Bind.Compression {
+		var r compressionRule
+		if r, err = newCompressionRule(bc); err != nil {
+			err = fmt.Errorf("creating bind compression rule #%d failed: %w", idx, err)
+			return
+		}
+		b.bindCompression = append(b.bindCompression, r)
+	}
+
+	// Resources filter
+	if b.resourcesFilter, err = newPathFilter(c.ResourcesInclude, c.ResourcesExclude); err != nil {
+		err = fmt.Errorf("creating resources filter failed: %w", err)
+		return
+	}
+
+	// Resources adapters
+	for idx, a := range b.resourcesAdapters {
+		if err = a.validate(); err != nil {
+			err = fmt.Errorf("validating resources adapter #%d failed: %w", idx, err)
+			return
+		}
+	}
+
+	// Resources ASAR unpacked patterns
+	if b.resourcesASARUnpacked, err = newPathPatterns(c.ResourcesASARUnpacked); err != nil {
+		err = fmt.Errorf("parsing resources asar unpacked patterns failed: %w", err)
+		return
+	}
+
+	// Third party notices
+	b.noticesBind = c.ThirdPartyNotices.Bind
+	b.noticesEnabled = c.ThirdPartyNotices.Enabled
+
+	// Archive
+	if c.Archive.Enabled {
+		if err = validateArchiveFormats(c.Archive.Formats); err != nil {
+			err = fmt.Errorf("validating archive formats failed: %w", err)
+			return
+		}
+		b.archiveFormats = c.Archive.Formats
+		if b.archiveName, err = newArchiveNameTemplate(c); err != nil {
+			err = fmt.Errorf("creating archive name template failed: %w", err)
+			return
+		}
+	}
+
+	// Publisher
+	if len(c.Publish.Bucket) > 0 {
+		if b.publisher, err = newPublisher(c); err != nil {
+			err = fmt.Errorf("creating publisher failed: %w", err)
+			return
+		}
+	}
+
+	// Release
+	b.releaseVersion = releaseVersion(c)
+	if len(c.Release.BaseURL) > 0 {
+		if b.releaseBaseURL, err = newReleaseURLTemplate(c.Release.BaseURL); err != nil {
+			err = fmt.Errorf("creating release base url template failed: %w", err)
+			return
+		}
+		if len(b.releaseVersion) == 0 {
+			err = errors.New("release version is not set")
+			return
+		}
+	}
+
+	// SBOM
+	b.sbomEnabled = c.SBOM.Enabled
+	b.sbomSPDX = c.SBOM.SPDX
+
+	// Resources hash
+	if b.resourcesHash, err = newPathPatterns(c.ResourcesHash); err != nil {
+		err = fmt.Errorf("parsing resources hash patterns failed: %w", err)
+		return
+	}
+	b.resourcesHashLength = c.ResourcesHashLength
+	if err = validateResourcesHashLength(b.resourcesHashLength); err != nil {
+		err = fmt.Errorf("validating resources hash length failed: %w", err)
+		return
+	}
+
 	// Vendor path
 	if b.pathVendor = c.VendorDirPath; len(b.pathVendor) == 0 {
 		b.pathVendor = vendorDirectoryName
@@ -362,13 +701,81 @@ func (b *Bundler) Bundle() (err error) {
 		return
 	}
 
+	// Staged resources are only valid for this bundle
+	defer func() { b.resourcesStaged = false }()
+
 	// Loop through environments
+	var bundled []ConfigurationEnvironment
 	for _, e := range b.environments {
+		// Skip environments that are up to date
+		if !b.force {
+			var ok bool
+			if ok, err = b.isUpToDate(e); err != nil {
+				err = fmt.Errorf("checking whether environment %s/%s is up to date failed: %w", e.OS, e.Arch, err)
+				return
+			} else if ok {
+				b.l.Infof("Environment %s/%s is up to date, skipping", e.OS, e.Arch)
+				continue
+			}
+		}
+
+		// Stage resources once for every environment
+		if !b.resourcesStaged {
+			if err = b.stageResources(); err != nil {
+				err = fmt.Errorf("staging resources failed: %w", err)
+				return
+			}
+		}
+
+		// Remove fingerprint in case bundling fails
+		if err = b.removeFingerprint(e); err != nil {
+			err = fmt.Errorf("removing fingerprint of environment %s/%s failed: %w", e.OS, e.Arch, err)
+			return
+		}
+
+		// Bundle
 		b.l.Debugf("Bundling for environment %s/%s", e.OS, e.Arch)
 		if err = b.bundle(e); err != nil {
 			err = fmt.Errorf("bundling for environment %s/%s failed: %w", e.OS, e.Arch, err)
 			return
 		}
+
+		bundled = append(bundled, e)
+	}
+
+	// Release
+	if b.releaseBaseURL != nil {
+		if err = b.Release(); err != nil {
+			err = fmt.Errorf("releasing failed: %w", err)
+			return
+		}
+	}
+
+	// Write fingerprints once release artifacts have been written as well
+	for _, e := range bundled {
+		if err = b.writeFingerprint(e); err != nil {
+			err = fmt.Errorf("writing fingerprint of environment %s/%s failed: %w", e.OS, e.Arch, err)
+			return
+		}
+	}
+
+	// Write checksums
+	if err = b.writeChecksums(); err != nil {
+		err = fmt.Errorf("writing checksums failed: %w", err)
+		return
+	}
+
+	// Publish
+	if b.publisher != nil {
+		var r BundleResult
+		if r, err = b.Result(); err != nil {
+			err = fmt.Errorf("getting result failed: %w", err)
+			return
+		}
+		if err = b.Publish(r); err != nil {
+			err = fmt.Errorf("publishing failed: %w", err)
+			return
+		}
 	}
 	return
 }
@@ -391,12 +798,94 @@ func (b *Bundler) bundle(e ConfigurationEnvironment) (err error) {
 	}
 
 	// Reset output dir
-	var environmentPath = filepath.Join(b.pathOutput, e.OS+"-"+e.Arch)
+	var environmentPath = b.environmentPath(e)
 	if err = b.resetDir(environmentPath); err != nil {
 		err = fmt.Errorf("resetting dir %s failed: %w", environmentPath, err)
 		return
 	}
 
+	// Build cmd
+	var binaryPath = filepath.Join(environmentPath, "binary")
+	b.l.Debugf("Building for os %s and arch %s astilectron: %s electron: %s", e.OS, e.Arch, b.versionAstilectron, b.versionElectron)
+	var cmd = b.buildCmd(e, binaryPath)
+
+	// Exec
+	var o []byte
+	b.l.Debugf("Executing %s", strings.Join(cmd.Args, " "))
+	if o, err = cmd.CombinedOutput(); err != nil {
+		err = fmt.Errorf("building failed: %s", o)
+		return
+	}
+
+	// Write third party notices
+	if b.noticesEnabled {
+		if err = b.writeThirdPartyNotices(e, filepath.Join(environmentPath, thirdPartyNoticesFileName)); err != nil {
+			err = fmt.Errorf("writing third party notices failed: %w", err)
+			return
+		}
+	}
+
+	// Write SBOM
+	if b.sbomEnabled {
+		if err = b.writeSBOM(e, binaryPath); err != nil {
+			err = fmt.Errorf("writing SBOM failed: %w", err)
+			return
+		}
+	}
+
+	// Finish bundle based on OS
+	switch e.OS {
+	case "darwin":
+		err = b.finishDarwin(environmentPath, binaryPath)
+	case "linux":
+		err = b.finishLinux(environmentPath, binaryPath)
+	case "windows":
+		err = b.finishWindows(environmentPath, binaryPath)
+	default:
+		err = fmt.Errorf("OS %s is not yet implemented", e.OS)
+	}
+	if err != nil {
+		return
+	}
+
+	// Archive
+	if b.archiveName != nil {
+		if err = b.archiveEnvironment(e); err != nil {
+			err = fmt.Errorf("archiving environment failed: %w", err)
+			return
+		}
+	}
+	return
+}
+
+// environmentPath returns the output path of an environment
+func (b *Bundler) environmentPath(e ConfigurationEnvironment) string {
+	return filepath.Join(b.pathOutput, e.OS+"-"+e.Arch)
+}
+
+// listOutputEnvironments returns the sorted environments whose output directory is present in an output
+// path, including environments that are not part of the configuration
+func listOutputEnvironments(outputPath string) (es []ConfigurationEnvironment, err error) {
+	// Read dir
+	var fis []os.FileInfo
+	if fis, err = ioutil.ReadDir(outputPath); err != nil {
+		err = fmt.Errorf("reading dir %s failed: %w", outputPath, err)
+		return
+	}
+
+	// Loop through dirs
+	for _, fi := range fis {
+		ss := strings.SplitN(fi.Name(), "-", 2)
+		if !fi.IsDir() || len(ss) != 2 || !astilectron.IsValidOS(ss[0]) {
+			continue
+		}
+		es = append(es, ConfigurationEnvironment{Arch: ss[1], OS: ss[0]})
+	}
+	return
+}
+
+// buildCmd creates the go build cmd
+func (b *Bundler) buildCmd(e ConfigurationEnvironment, binaryPath string) (cmd *exec.Cmd) {
 	std := LDFlags{
 		"X": []string{
 			b.ldflagsPackage + `.AppName=` + b.appName,
@@ -410,15 +899,15 @@ func (b *Bundler) bundle(e ConfigurationEnvironment) (err error) {
 	}
 	std.Merge(b.ldflags)
 
-	// Get gopath
-	gp := os.Getenv("GOPATH")
-	if len(gp) == 0 {
-		gp = build.Default.GOPATH
-	}
-
 	args := []string{"build", "-ldflags", std.String()}
 	var flag string
-	for k, v := range b.buildFlags {
+	var ks []string
+	for k := range b.buildFlags {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	for _, k := range ks {
+		v := b.buildFlags[k]
 		if hasDash := strings.HasPrefix(k, "-"); hasDash {
 			flag = k
 		} else {
@@ -430,44 +919,36 @@ func (b *Bundler) bundle(e ConfigurationEnvironment) (err error) {
 			args = append(args, flag)
 		}
 	}
-
-	var binaryPath = filepath.Join(environmentPath, "binary")
 	args = append(args, "-o", binaryPath, b.pathBuild)
 
-	// Build cmd
-	b.l.Debugf("Building for os %s and arch %s astilectron: %s electron: %s", e.OS, e.Arch, b.versionAstilectron, b.versionElectron)
-	var cmd = exec.Command(b.pathGoBinary, args...)
+	// Create cmd
+	cmd = exec.Command(b.pathGoBinary, args...)
 	cmd.Env = os.Environ()
-	cmd.Env = append(cmd.Env,
-		"GOARCH="+e.Arch,
-		"GOOS="+e.OS,
-		"GOPATH="+gp,
-	)
+	cmd.Env = append(cmd.Env, buildEnv(e)...)
+	return
+}
 
-	if e.EnvironmentVariables != nil {
-		for k, v := range e.EnvironmentVariables {
-			cmd.Env = append(cmd.Env, k+"="+v)
-		}
+// buildEnv returns the environment variables added to the go build cmd
+func buildEnv(e ConfigurationEnvironment) (env []string) {
+	// Get gopath
+	gp := os.Getenv("GOPATH")
+	if len(gp) == 0 {
+		gp = build.Default.GOPATH
 	}
 
-	// Exec
-	var o []byte
-	b.l.Debugf("Executing %s", strings.Join(cmd.Args, " "))
-	if o, err = cmd.CombinedOutput(); err != nil {
-		err = fmt.Errorf("building failed: %s", o)
-		return
+	env = []string{
+		"GOARCH=" + e.Arch,
+		"GOOS=" + e.OS,
+		"GOPATH=" + gp,
 	}
 
-	// Finish bundle based on OS
-	switch e.OS {
-	case "darwin":
-		err = b.finishDarwin(environmentPath, binaryPath)
-	case "linux":
-		err = b.finishLinux(environmentPath, binaryPath)
-	case "windows":
-		err = b.finishWindows(environmentPath, binaryPath)
-	default:
-		err = fmt.Errorf("OS %s is not yet implemented", e.OS)
+	var ks []string
+	for k := range e.EnvironmentVariables {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	for _, k := range ks {
+		env = append(env, k+"="+e.EnvironmentVariables[k])
 	}
 	return
 }
@@ -490,7 +971,12 @@ func (b *Bundler) resetDir(p string) (err error) {
 }
 
 // BindData binds the data
-func (b *Bundler) BindData(os, arch string) (err error) {
+func (b *Bundler) BindData(os, arch string) error {
+	return b.bindData(os, arch, true)
+}
+
+// bindData binds the data, resources adapters are skipped unless adapt is true
+func (b *Bundler) bindData(os, arch string, adapt bool) (err error) {
 	// Reset bind dir
 	if err = b.resetDir(b.pathBindInput); err != nil {
 		err = fmt.Errorf("resetting dir %s failed: %w", b.pathBindInput, err)
@@ -504,25 +990,52 @@ func (b *Bundler) BindData(os, arch string) (err error) {
 	}
 
 	// Adapt resources
-	if err = b.adaptResources(); err != nil {
+	if err = b.adaptResources(os, arch, adapt); err != nil {
 		err = fmt.Errorf("adapting resources failed: %w", err)
 		return
 	}
 
-	// Build bindata config
-	var c = bindata.NewConfig()
-	c.Input = []bindata.InputConfig{{Path: b.pathBindInput, Recursive: true}}
-	c.Output = filepath.Join(b.pathBindOutput, fmt.Sprintf("bind_%s_%s.go", os, arch))
-	c.Package = b.bindPackage
-	c.Prefix = b.pathBindInput
-	c.Tags = fmt.Sprintf("%s,%s", os, arch)
+	// Bind third party notices
+	if b.noticesBind && b.noticesEnabled {
+		e := ConfigurationEnvironment{Arch: arch, OS: os}
+		for _, v := range b.environments {
+			if v.OS == os && v.Arch == arch {
+				e = v
+			}
+		}
+		p := filepath.Join(b.pathBindInput, b.pathResources, thirdPartyNoticesFileName)
+		if err = b.writeThirdPartyNotices(e, p); err != nil {
+			err = fmt.Errorf("writing third party notices failed: %w", err)
+			return
+		}
+	}
+
+	// Hash resources
+	if len(b.resourcesHash) > 0 {
+		if err = b.hashBindResources(os, arch); err != nil {
+			err = fmt.Errorf("hashing resources failed: %w", err)
+			return
+		}
+	}
+
+	// Pack resources
+	if b.resourcesASAR {
+		if err = b.packResources(); err != nil {
+			err = fmt.Errorf("packing resources failed: %w", err)
+			return
+		}
+	}
 
 	// Bind data
-	b.l.Debugf("Generating %s", c.Output)
-	err = bindata.Translate(c)
+	err = b.writeBindFile(os, arch)
 	return
 }
 
+// bindOutputPath returns the path of the bind file of an environment
+func (b *Bundler) bindOutputPath(os, arch string) string {
+	return filepath.Join(b.pathBindOutput, fmt.Sprintf("bind_%s_%s.go", os, arch))
+}
+
 // provisionVendor provisions the vendor folder
 func (b *Bundler) provisionVendor(oS, arch string) (err error) {
 	// Create the vendor folder
@@ -532,6 +1045,28 @@ func (b *Bundler) provisionVendor(oS, arch string) (err error) {
 		return
 	}
 
+	// Cache the vendor
+	if err = b.cacheVendor(oS, arch); err != nil {
+		err = fmt.Errorf("caching the vendor failed: %w", err)
+		return
+	}
+
+	// Copy astilectron
+	if err = b.copyVendorZip(b.astilectronCachePath(), filepath.Join(b.pathVendor, zipNameAstilectron)); err != nil {
+		err = fmt.Errorf("copying astilectron vendor failed: %w", err)
+		return
+	}
+
+	// Copy electron
+	if err = b.copyVendorZip(b.electronCachePath(oS, arch), filepath.Join(b.pathVendor, zipNameElectron)); err != nil {
+		err = fmt.Errorf("copying electron vendor for OS %s and arch %s failed: %w", oS, arch, err)
+		return
+	}
+	return
+}
+
+// cacheVendor makes sure the vendor zip files are in the cache folder
+func (b *Bundler) cacheVendor(oS, arch string) (err error) {
 	// Create the cache folder
 	b.l.Debugf("Creating %s", b.pathCache)
 	if err = os.MkdirAll(b.pathCache, 0755); err != nil {
@@ -539,22 +1074,22 @@ func (b *Bundler) provisionVendor(oS, arch string) (err error) {
 		return
 	}
 
-	// Provision astilectron
-	if err = b.provisionVendorAstilectron(); err != nil {
-		err = fmt.Errorf("provisioning astilectron vendor failed: %w", err)
+	// Cache astilectron
+	if err = b.cacheVendorAstilectron(); err != nil {
+		err = fmt.Errorf("caching astilectron vendor failed: %w", err)
 		return
 	}
 
-	// Provision electron
-	if err = b.provisionVendorElectron(oS, arch); err != nil {
-		err = fmt.Errorf("provisioning electron vendor for OS %s and arch %s failed: %w", oS, arch, err)
+	// Cache electron
+	if err = b.cacheVendorElectron(oS, arch); err != nil {
+		err = fmt.Errorf("caching electron vendor for OS %s and arch %s failed: %w", oS, arch, err)
 		return
 	}
 	return
 }
 
-// provisionVendorZip provisions a vendor zip file
-func (b *Bundler) provisionVendorZip(pathDownload, pathCache, pathVendor string) (err error) {
+// cacheVendorZip downloads a vendor zip file unless it's already in the cache
+func (b *Bundler) cacheVendorZip(pathDownload, pathCache string) (err error) {
 	// Download source
 	if _, errStat := os.Stat(pathCache); os.IsNotExist(errStat) {
 		if err = astilectron.Download(b.ctx, b.l, b.d, pathDownload, pathCache); err != nil {
@@ -569,7 +1104,11 @@ func (b *Bundler) provisionVendorZip(pathDownload, pathCache, pathVendor string)
 	if b.ctx.Err() != nil {
 		return b.ctx.Err()
 	}
+	return
+}
 
+// copyVendorZip copies a cached vendor zip file into the vendor folder
+func (b *Bundler) copyVendorZip(pathCache, pathVendor string) (err error) {
 	// Copy
 	b.l.Debugf("Copying %s to %s", pathCache, pathVendor)
 	if err = astikit.CopyFile(b.ctx, pathVendor, pathCache, astikit.LocalCopyFileFunc); err != nil {
@@ -584,10 +1123,9 @@ func (b *Bundler) provisionVendorZip(pathDownload, pathCache, pathVendor string)
 	return
 }
 
-// provisionVendorAstilectron provisions the astilectron vendor zip file
-func (b *Bundler) provisionVendorAstilectron() (err error) {
-
-	var p = filepath.Join(b.pathCache, fmt.Sprintf("astilectron-%s.zip", b.versionAstilectron))
+// cacheVendorAstilectron caches the astilectron vendor zip file
+func (b *Bundler) cacheVendorAstilectron() (err error) {
+	var p = b.astilectronCachePath()
 	if len(b.pathAstilectron) > 0 {
 		// Zip
 		b.l.Debugf("Zipping %s into %s", b.pathAstilectron, p)
@@ -601,59 +1139,271 @@ func (b *Bundler) provisionVendorAstilectron() (err error) {
 			return b.ctx.Err()
 		}
 	}
-	return b.provisionVendorZip(astilectron.AstilectronDownloadSrc(b.versionAstilectron), p, filepath.Join(b.pathVendor, zipNameAstilectron))
+	return b.cacheVendorZip(astilectron.AstilectronDownloadSrc(b.versionAstilectron), p)
 }
 
-// provisionVendorElectron provisions the electron vendor zip file
-func (b *Bundler) provisionVendorElectron(oS, arch string) error {
-	return b.provisionVendorZip(
-		astilectron.ElectronDownloadSrc(oS, arch, b.versionElectron),
-		filepath.Join(b.pathCache, fmt.Sprintf("electron-%s-%s-%s.zip", oS, arch, b.versionElectron)),
-		filepath.Join(b.pathVendor, zipNameElectron))
+// cacheVendorElectron caches the electron vendor zip file
+func (b *Bundler) cacheVendorElectron(oS, arch string) error {
+	return b.cacheVendorZip(astilectron.ElectronDownloadSrc(oS, arch, b.versionElectron), b.electronCachePath(oS, arch))
 }
 
-func (b *Bundler) adaptResources() (err error) {
-	// Create dir
+// astilectronCachePath returns the cache path of the astilectron vendor zip file
+func (b *Bundler) astilectronCachePath() string {
+	return filepath.Join(b.pathCache, fmt.Sprintf("astilectron-%s.zip", b.versionAstilectron))
+}
+
+// electronCachePath returns the cache path of the electron vendor zip file
+func (b *Bundler) electronCachePath(oS, arch string) string {
+	return filepath.Join(b.pathCache, fmt.Sprintf("electron-%s-%s-%s.zip", oS, arch, b.versionElectron))
+}
+
+// adaptResources copies the resources into the bind dir and runs resources adapters on them. When resources
+// have been staged, the staged copy is used and only environment specific adapters are run.
+func (b *Bundler) adaptResources(oS, arch string, adapt bool) (err error) {
+	// Reuse staged resources
 	var o = filepath.Join(b.pathBindInput, b.pathResources)
+	if b.resourcesStaged {
+		b.l.Debugf("Creating %s", o)
+		if err = os.MkdirAll(o, 0755); err != nil {
+			err = fmt.Errorf("mkdirall %s failed: %w", o, err)
+			return
+		}
+		b.l.Debugf("Copying staged resources %s to %s", b.pathResourcesStaged, o)
+		if err = astikit.CopyFile(b.ctx, o, b.pathResourcesStaged, astikit.LocalCopyFileFunc); err != nil {
+			err = fmt.Errorf("copying %s to %s failed: %w", b.pathResourcesStaged, o, err)
+			return
+		}
+	} else {
+		// Copy resources
+		if err = b.copyResources(o); err != nil {
+			err = fmt.Errorf("copying resources failed: %w", err)
+			return
+		}
+
+		// Run shared adapters
+		if adapt {
+			if err = b.runResourcesAdapters(oS, arch, o, false); err != nil {
+				err = fmt.Errorf("running shared resources adapters failed: %w", err)
+				return
+			}
+		}
+	}
+
+	// Run environment specific adapters
+	if adapt {
+		if err = b.runResourcesAdapters(oS, arch, o, true); err != nil {
+			err = fmt.Errorf("running environment specific resources adapters failed: %w", err)
+			return
+		}
+	}
+	return
+}
+
+// stageResources copies the resources into the staged dir and runs adapters that are not environment
+// specific on them, so that environments only have to run environment specific adapters
+func (b *Bundler) stageResources() (err error) {
+	// Reset dir
+	if err = b.resetDir(b.pathResourcesStaged); err != nil {
+		err = fmt.Errorf("resetting dir %s failed: %w", b.pathResourcesStaged, err)
+		return
+	}
+
+	// Copy resources
+	if err = b.copyResources(b.pathResourcesStaged); err != nil {
+		err = fmt.Errorf("copying resources failed: %w", err)
+		return
+	}
+
+	// Run shared adapters
+	if err = b.runResourcesAdapters("", "", b.pathResourcesStaged, false); err != nil {
+		err = fmt.Errorf("running shared resources adapters failed: %w", err)
+		return
+	}
+	b.resourcesStaged = true
+	return
+}
+
+// copyResources copies the resources that are not excluded to a dir
+func (b *Bundler) copyResources(o string) (err error) {
+	// Create dir
 	b.l.Debugf("Creating %s", o)
 	if err = os.MkdirAll(o, 0755); err != nil {
 		err = fmt.Errorf("mkdirall %s failed: %w", o, err)
 		return
 	}
 
-	// Copy resources
+	// Stat resources
 	var i = filepath.Join(b.pathInput, b.pathResources)
+	if _, err = os.Stat(i); err != nil {
+		err = fmt.Errorf("stating %s failed: %w", i, err)
+		return
+	}
+
+	// List resources
+	var es []resourceEntry
+	if es, err = listResources(i, func(p string, fi os.FileInfo) bool { return true }); err != nil {
+		err = fmt.Errorf("listing resources of %s failed: %w", i, err)
+		return
+	}
+
+	// Copy resources
 	b.l.Debugf("Copying %s to %s", i, o)
-	if err = astikit.CopyFile(b.ctx, o, i, astikit.LocalCopyFileFunc); err != nil {
-		err = fmt.Errorf("copying %s to %s failed: %w", i, o, err)
+	var excludedBytes, excludedFiles int64
+	for _, e := range es {
+		// Excluded
+		if !b.resourcesFilter.keep(filepath.ToSlash(e.rel), e.fi.IsDir()) {
+			if !e.fi.IsDir() {
+				excludedBytes += e.fi.Size()
+				excludedFiles++
+			}
+			continue
+		}
+
+		// Create dir, so that empty dirs are kept
+		if e.fi.IsDir() {
+			if err = os.MkdirAll(filepath.Join(o, e.rel), 0755); err != nil {
+				err = fmt.Errorf("mkdirall %s failed: %w", filepath.Join(o, e.rel), err)
+				return
+			}
+			continue
+		}
+
+		// Copy
+		if err = astikit.CopyFile(b.ctx, filepath.Join(o, e.rel), e.path, astikit.LocalCopyFileFunc); err != nil {
+			err = fmt.Errorf("copying %s to %s failed: %w", e.path, filepath.Join(o, e.rel), err)
+			return
+		}
+	}
+	if excludedFiles > 0 {
+		b.l.Infof("Excluded %d resources totaling %d bytes", excludedFiles, excludedBytes)
+	}
+	return
+}
+
+// resourceEntry represents a file or a dir of the resources folder
+type resourceEntry struct {
+	// Info of the symlink target for symlinks
+	fi   os.FileInfo
+	path string
+	rel  string
+}
+
+// listResources returns the sorted files and dirs of a dir kept by the filter. Unlike listFiles, symlinks
+// are followed, as they always were when copying resources, and dirs are listed so that empty dirs can
+// be kept.
+func listResources(dir string, filter func(p string, fi os.FileInfo) bool) (es []resourceEntry, err error) {
+	// Get real path
+	var rp string
+	if rp, err = filepath.EvalSymlinks(dir); err != nil {
+		err = fmt.Errorf("evaluating symlinks of %s failed: %w", dir, err)
 		return
 	}
 
-	// Nothing to do
-	if len(b.resourcesAdapters) == 0 {
+	// Walk
+	if err = walkResources(dir, "", filter, map[string]bool{rp: true}, &es); err != nil {
+		return
+	}
+	sort.Slice(es, func(i, j int) bool { return es[i].rel < es[j].rel })
+	return
+}
+
+// walkResources appends the resources of a dir whose real path and the ones of its ancestors are
+// provided
+func walkResources(dir, rel string, filter func(p string, fi os.FileInfo) bool, ancestors map[string]bool, es *[]resourceEntry) (err error) {
+	// Read dir
+	var fis []os.FileInfo
+	if fis, err = ioutil.ReadDir(dir); err != nil {
+		err = fmt.Errorf("reading dir %s failed: %w", dir, err)
 		return
 	}
 
-	// Loop through adapters
-	for _, a := range b.resourcesAdapters {
-		// Create cmd
-		cmd := exec.CommandContext(b.ctx, a.Name, a.Args...)
-		cmd.Dir = o
-		if a.Dir != "" {
-			cmd.Dir = filepath.Join(o, a.Dir)
+	// Loop through entries
+	for _, fi := range fis {
+		// Follow symlinks
+		p := filepath.Join(dir, fi.Name())
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if fi, err = os.Stat(p); err != nil {
+				err = fmt.Errorf("stating %s failed: %w", p, err)
+				return
+			}
+		}
+
+		// Filter
+		if !filter(p, fi) || (!fi.IsDir() && !fi.Mode().IsRegular()) {
+			continue
+		}
+		e := resourceEntry{fi: fi, path: p, rel: filepath.Join(rel, fi.Name())}
+
+		// File
+		if !fi.IsDir() {
+			*es = append(*es, e)
+			continue
+		}
+
+		// Symlinked dirs pointing to one of their ancestors are skipped
+		var rp string
+		if rp, err = filepath.EvalSymlinks(p); err != nil {
+			err = fmt.Errorf("evaluating symlinks of %s failed: %w", p, err)
+			return
+		}
+		if ancestors[rp] {
+			continue
+		}
+
+		// Walk through dir
+		*es = append(*es, e)
+		ancestors[rp] = true
+		err = walkResources(p, e.rel, filter, ancestors, es)
+		delete(ancestors, rp)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// runResourcesAdapters runs either the shared or the environment specific resources adapters in a dir
+func (b *Bundler) runResourcesAdapters(oS, arch, dir string, environmentSpecific bool) (err error) {
+	for idx, a := range b.resourcesAdapters {
+		// Adapter is not of the requested kind
+		if a.isEnvironmentSpecific() != environmentSpecific {
+			continue
 		}
 
-		// Run
-		b.l.Debugf("Running %s in directory %s", strings.Join(cmd.Args, " "), cmd.Dir)
-		var b []byte
-		if b, err = cmd.CombinedOutput(); err != nil {
-			err = fmt.Errorf("running %s failed with output %s", strings.Join(cmd.Args, " "), b)
+		// Adapter doesn't run for this environment
+		if environmentSpecific && !a.matches(oS, arch) {
+			b.l.Debugf("Skipping resources adapter #%d for %s/%s", idx, oS, arch)
+			continue
+		}
+
+		// Run shared adapters without environment, whatever dir they're run in
+		aOS, aArch := oS, arch
+		if !environmentSpecific {
+			aOS, aArch = "", ""
+		}
+		if err = b.runResourcesAdapter(a, aOS, aArch, dir); err != nil {
+			err = fmt.Errorf("running resources adapter #%d failed: %w", idx, err)
 			return
 		}
 	}
 	return
 }
 
+// resourcesFileFilter returns a filter keeping resources that are bound. Excluded dirs are skipped.
+func (b *Bundler) resourcesFileFilter() func(p string, fi os.FileInfo) bool {
+	resourcesPath := filepath.Join(b.pathInput, b.pathResources)
+	return func(p string, fi os.FileInfo) bool {
+		if p == resourcesPath {
+			return true
+		}
+		rp, err := filepath.Rel(resourcesPath, p)
+		if err != nil {
+			return false
+		}
+		return b.resourcesFilter.keep(filepath.ToSlash(rp), fi.IsDir())
+	}
+}
+
 // addWindowsSyso adds the proper windows .syso if needed
 func (b *Bundler) addWindowsSyso(arch string) (err error) {
 	if len(b.pathIconWindows) > 0 || len(b.pathManifest) > 0 {
@@ -678,15 +1428,11 @@ func (b *Bundler) finishDarwin(environmentPath, binaryPath string) (err error) {
 		return
 	}
 
-	var macOSBinaryPath = filepath.Join(macOSPath, b.appName)
+	var macOSBinaryPath = filepath.Join(macOSPath, b.darwinBinaryName())
 
 	var infoPlist *plister.InfoPlist
 	if b.infoPlist != nil {
 		infoPlist = plister.MapToInfoPlist(b.infoPlist)
-		binaryName, _ := infoPlist.Get("CFBundleExecutable").(string)
-		if binaryName != "" {
-			macOSBinaryPath = filepath.Join(macOSPath, binaryName)
-		}
 	}
 
 	// Move binary
@@ -718,17 +1464,8 @@ func (b *Bundler) finishDarwin(environmentPath, binaryPath string) (err error) {
 			return
 		}
 
-		iconFileName := b.appName + filepath.Ext(b.pathIconDarwin)
-
-		if infoPlist != nil {
-			ifn, _ := infoPlist.Get("CFBundleIconFile").(string)
-			if ifn != "" {
-				iconFileName = ifn
-			}
-		}
-
 		// Copy icon
-		var ip = filepath.Join(resourcesPath, iconFileName)
+		var ip = filepath.Join(resourcesPath, b.darwinIconName())
 		b.l.Debugf("Copying %s to %s", b.pathIconDarwin, ip)
 		if err = astikit.CopyFile(b.ctx, ip, b.pathIconDarwin, astikit.LocalCopyFileFunc); err != nil {
 			err = fmt.Errorf("copying %s to %s failed: %w", b.pathIconDarwin, ip, err)
@@ -782,6 +1519,26 @@ func (b *Bundler) finishDarwin(environmentPath, binaryPath string) (err error) {
 	return
 }
 
+// darwinBinaryName returns the name of the darwin binary
+func (b *Bundler) darwinBinaryName() string {
+	if b.infoPlist != nil {
+		if n, _ := plister.MapToInfoPlist(b.infoPlist).Get("CFBundleExecutable").(string); n != "" {
+			return n
+		}
+	}
+	return b.appName
+}
+
+// darwinIconName returns the name of the darwin icon
+func (b *Bundler) darwinIconName() string {
+	if b.infoPlist != nil {
+		if n, _ := plister.MapToInfoPlist(b.infoPlist).Get("CFBundleIconFile").(string); n != "" {
+			return n
+		}
+	}
+	return b.appName + filepath.Ext(b.pathIconDarwin)
+}
+
 // finishLinux finishes bundling for a linux system
 // TODO Add .desktop file
 func (b *Bundler) finishLinux(environmentPath, binaryPath string) (err error) {
@@ -0,0 +1,399 @@
+package astibundler
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/asticode/go-astikit"
+)
+
+// Checksums file names
+const (
+	ChecksumsFileName          = "SHA256SUMS"
+	ChecksumsSignatureFileName = ChecksumsFileName + ".minisig"
+)
+
+// minisignAlgorithm is the minisign signature algorithm of signatures computed on the whole message
+var minisignAlgorithm = []byte("Ed")
+
+// writeChecksums writes the sha256 of every file of every environment output dir and of every environment
+// archive in the SHA256SUMS file of the output dir, using the sha256sum format, and signs it if a signing
+// key has been provided. Environment output dirs present in the output dir are all listed, even when only
+// some environments have been bundled, so that the file covers the whole output dir.
+func (b *Bundler) writeChecksums() (err error) {
+	// List environments
+	var es []ConfigurationEnvironment
+	if es, err = listOutputEnvironments(b.pathOutput); err != nil {
+		err = fmt.Errorf("listing environments of %s failed: %w", b.pathOutput, err)
+		return
+	}
+
+	// Loop through environments
+	buf := &bytes.Buffer{}
+	for _, e := range es {
+		// List files
+		var ps []string
+		environmentPath := b.environmentPath(e)
+		if ps, err = listFiles(environmentPath, func(p string, fi os.FileInfo) bool { return true }); err != nil {
+			err = fmt.Errorf("listing files of %s failed: %w", environmentPath, err)
+			return
+		}
+
+		// Hash files
+		for _, p := range ps {
+			h := sha256.New()
+			if err = hashFile(h, p); err != nil {
+				err = fmt.Errorf("hashing %s failed: %w", p, err)
+				return
+			}
+			rp, _ := filepath.Rel(b.pathOutput, p)
+			fmt.Fprintf(buf, "%s  %s\n", hex.EncodeToString(h.Sum(nil)), filepath.ToSlash(rp))
+		}
+	}
+
+	// Loop through archives
+	for _, e := range es {
+		if b.archiveName == nil {
+			break
+		}
+		var p string
+		if p, err = b.archivePath(e); err != nil {
+			err = fmt.Errorf("getting archive path failed: %w", err)
+			return
+		}
+		h := sha256.New()
+		if err = hashFile(h, p); err != nil {
+			// Environments that have not been bundled have no archive
+			if os.IsNotExist(err) {
+				err = nil
+				continue
+			}
+			err = fmt.Errorf("hashing %s failed: %w", p, err)
+			return
+		}
+		fmt.Fprintf(buf, "%s  %s\n", hex.EncodeToString(h.Sum(nil)), filepath.Base(p))
+	}
+
+	// Write
+	p := filepath.Join(b.pathOutput, ChecksumsFileName)
+	b.l.Debugf("Writing checksums to %s", p)
+	if err = ioutil.WriteFile(p, buf.Bytes(), 0644); err != nil {
+		err = fmt.Errorf("writing %s failed: %w", p, err)
+		return
+	}
+
+	// No signing key
+	if len(b.pathSigningKey) == 0 {
+		return
+	}
+
+	// Read key
+	var keyID []byte
+	var sk ed25519.PrivateKey
+	if keyID, sk, err = readSigningKey(b.pathSigningKey); err != nil {
+		err = fmt.Errorf("reading signing key %s failed: %w", b.pathSigningKey, err)
+		return
+	}
+
+	// Sign
+	sp := filepath.Join(b.pathOutput, ChecksumsSignatureFileName)
+	b.l.Debugf("Writing checksums signature to %s", sp)
+	if err = ioutil.WriteFile(sp, signMinisign(keyID, sk, buf.Bytes(), fmt.Sprintf("timestamp:%d\tfile:%s", time.Now().Unix(), ChecksumsFileName)), 0644); err != nil {
+		err = fmt.Errorf("writing %s failed: %w", sp, err)
+		return
+	}
+	return
+}
+
+// readSigningKey reads either an unencrypted minisign secret key or a PEM encoded ed25519 private key
+func readSigningKey(p string) (keyID []byte, sk ed25519.PrivateKey, err error) {
+	// Read
+	var b []byte
+	if b, err = ioutil.ReadFile(p); err != nil {
+		err = fmt.Errorf("reading %s failed: %w", p, err)
+		return
+	}
+
+	// PEM
+	if bl, _ := pem.Decode(b); bl != nil {
+		var k interface{}
+		if k, err = x509.ParsePKCS8PrivateKey(bl.Bytes); err != nil {
+			err = fmt.Errorf("parsing PKCS8 private key failed: %w", err)
+			return
+		}
+		var ok bool
+		if sk, ok = k.(ed25519.PrivateKey); !ok {
+			err = errors.New("private key is not an ed25519 key")
+			return
+		}
+		keyID = pemKeyID(sk.Public().(ed25519.PublicKey))
+		return
+	}
+
+	// Minisign: algorithm (2) + kdf algorithm (2) + checksum algorithm (2) + kdf salt (32) + kdf ops
+	// limit (8) + kdf mem limit (8) + key id (8) + secret key (64) + checksum (32)
+	var d []byte
+	if d, err = decodeMinisignLine(b); err != nil {
+		err = fmt.Errorf("decoding minisign secret key failed: %w", err)
+		return
+	}
+	if len(d) != 158 || !bytes.Equal(d[:2], minisignAlgorithm) {
+		err = errors.New("key is neither a minisign secret key nor a PEM encoded ed25519 private key")
+		return
+	}
+	if d[2] != 0 || d[3] != 0 {
+		err = errors.New("encrypted minisign secret keys are not supported, generate the key with minisign -G -W")
+		return
+	}
+	keyID = d[54:62]
+	sk = ed25519.PrivateKey(d[62:126])
+	return
+}
+
+// readVerificationKey reads either a minisign public key or a PEM encoded ed25519 public key
+func readVerificationKey(p string) (keyID []byte, pk ed25519.PublicKey, err error) {
+	// Read
+	var b []byte
+	if b, err = ioutil.ReadFile(p); err != nil {
+		err = fmt.Errorf("reading %s failed: %w", p, err)
+		return
+	}
+
+	// PEM
+	if bl, _ := pem.Decode(b); bl != nil {
+		var k interface{}
+		if k, err = x509.ParsePKIXPublicKey(bl.Bytes); err != nil {
+			err = fmt.Errorf("parsing PKIX public key failed: %w", err)
+			return
+		}
+		var ok bool
+		if pk, ok = k.(ed25519.PublicKey); !ok {
+			err = errors.New("public key is not an ed25519 key")
+			return
+		}
+		keyID = pemKeyID(pk)
+		return
+	}
+
+	// Minisign: algorithm (2) + key id (8) + public key (32)
+	var d []byte
+	if d, err = decodeMinisignLine(b); err != nil {
+		err = fmt.Errorf("decoding minisign public key failed: %w", err)
+		return
+	}
+	if len(d) != 42 || !bytes.Equal(d[:2], minisignAlgorithm) {
+		err = errors.New("key is neither a minisign public key nor a PEM encoded ed25519 public key")
+		return
+	}
+	keyID = d[2:10]
+	pk = ed25519.PublicKey(d[10:])
+	return
+}
+
+// pemKeyID returns the key id of PEM encoded keys, which, unlike minisign keys, don't have one
+func pemKeyID(pk ed25519.PublicKey) []byte {
+	h := sha256.Sum256(pk)
+	return h[:8]
+}
+
+// decodeMinisignLine decodes the first base64 line following the untrusted comment of a minisign file
+func decodeMinisignLine(b []byte) (d []byte, err error) {
+	ls := minisignLines(b)
+	if len(ls) < 2 || !strings.HasPrefix(ls[0], "untrusted comment:") {
+		err = errors.New("untrusted comment is missing")
+		return
+	}
+	if d, err = base64.StdEncoding.DecodeString(ls[1]); err != nil {
+		err = fmt.Errorf("base64 decoding failed: %w", err)
+		return
+	}
+	return
+}
+
+func minisignLines(b []byte) (ls []string) {
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		if l := strings.TrimRight(s.Text(), "\r"); l != "" {
+			ls = append(ls, l)
+		}
+	}
+	return
+}
+
+// signMinisign returns the minisign signature of a message
+func signMinisign(keyID []byte, sk ed25519.PrivateKey, msg []byte, trustedComment string) []byte {
+	sig := append(append(append([]byte{}, minisignAlgorithm...), keyID...), ed25519.Sign(sk, msg)...)
+	global := ed25519.Sign(sk, append(append([]byte{}, sig[10:]...), trustedComment...))
+	return []byte(fmt.Sprintf("untrusted comment: signature from astilectron-bundler\n%s\ntrusted comment: %s\n%s\n",
+		base64.StdEncoding.EncodeToString(sig), trustedComment, base64.StdEncoding.EncodeToString(global)))
+}
+
+// verifyMinisign verifies the minisign signature of a message
+func verifyMinisign(keyID []byte, pk ed25519.PublicKey, msg, sig []byte) (err error) {
+	// Parse signature
+	ls := minisignLines(sig)
+	if len(ls) != 4 || !strings.HasPrefix(ls[0], "untrusted comment:") || !strings.HasPrefix(ls[2], "trusted comment: ") {
+		err = errors.New("signature is invalid")
+		return
+	}
+	var s, global []byte
+	if s, err = base64.StdEncoding.DecodeString(ls[1]); err != nil {
+		err = fmt.Errorf("base64 decoding signature failed: %w", err)
+		return
+	}
+	if global, err = base64.StdEncoding.DecodeString(ls[3]); err != nil {
+		err = fmt.Errorf("base64 decoding global signature failed: %w", err)
+		return
+	}
+	if len(s) != 74 || len(global) != ed25519.SignatureSize {
+		err = errors.New("signature is invalid")
+		return
+	}
+
+	// Algorithm
+	if !bytes.Equal(s[:2], minisignAlgorithm) {
+		err = fmt.Errorf("signature algorithm %s is not supported", s[:2])
+		return
+	}
+
+	// Key id
+	if !bytes.Equal(s[2:10], keyID) {
+		err = fmt.Errorf("signature key id %X doesn't match public key id %X", s[2:10], keyID)
+		return
+	}
+
+	// Verify
+	if !ed25519.Verify(pk, msg, s[10:]) {
+		err = errors.New("signature doesn't match")
+		return
+	}
+	if !ed25519.Verify(pk, append(append([]byte{}, s[10:]...), strings.TrimPrefix(ls[2], "trusted comment: ")...), global) {
+		err = errors.New("trusted comment signature doesn't match")
+		return
+	}
+	return
+}
+
+// VerifyChecksums checks the files listed in the SHA256SUMS file of a dir against their sha256. Paths must
+// be relative to the dir and every file of the folders they belong to, such as environment output
+// folders, must be listed. If a public key path is provided, the SHA256SUMS.minisig signature is
+// verified first. The public key is either a minisign public key or a PEM encoded ed25519 public key.
+func VerifyChecksums(dir, publicKeyPath string) (err error) {
+	// Read checksums
+	var b []byte
+	p := filepath.Join(dir, ChecksumsFileName)
+	if b, err = ioutil.ReadFile(p); err != nil {
+		err = fmt.Errorf("reading %s failed: %w", p, err)
+		return
+	}
+
+	// Verify signature
+	if len(publicKeyPath) > 0 {
+		// Read key
+		var keyID []byte
+		var pk ed25519.PublicKey
+		if keyID, pk, err = readVerificationKey(publicKeyPath); err != nil {
+			err = fmt.Errorf("reading public key %s failed: %w", publicKeyPath, err)
+			return
+		}
+
+		// Read signature
+		var sig []byte
+		sp := filepath.Join(dir, ChecksumsSignatureFileName)
+		if sig, err = ioutil.ReadFile(sp); err != nil {
+			err = fmt.Errorf("reading %s failed: %w", sp, err)
+			return
+		}
+
+		// Verify
+		if err = verifyMinisign(keyID, pk, b, sig); err != nil {
+			err = fmt.Errorf("verifying %s failed: %w", sp, err)
+			return
+		}
+	}
+
+	// Loop through lines
+	errs := astikit.NewErrors()
+	listed := make(map[string]bool)
+	var dirs []string
+	for idx, l := range strings.Split(strings.TrimRight(string(b), "\n"), "\n") {
+		// Parse line
+		ss := strings.SplitN(l, "  ", 2)
+		if len(ss) != 2 {
+			errs.Add(fmt.Errorf("line %d is invalid", idx+1))
+			continue
+		}
+
+		// Paths must not point outside the dir
+		if !isLocalSlashPath(ss[1]) {
+			errs.Add(fmt.Errorf("line %d: path %s is not relative to the dir", idx+1, ss[1]))
+			continue
+		}
+		listed[ss[1]] = true
+		if i := strings.Index(ss[1], "/"); i > 0 && !stringsContain(dirs, ss[1][:i]) {
+			dirs = append(dirs, ss[1][:i])
+		}
+
+		// Hash file
+		h := sha256.New()
+		if err = hashFile(h, filepath.Join(dir, filepath.FromSlash(ss[1]))); err != nil {
+			errs.Add(fmt.Errorf("hashing %s failed: %w", ss[1], err))
+			continue
+		}
+
+		// Compare
+		if s := hex.EncodeToString(h.Sum(nil)); s != ss[0] {
+			errs.Add(fmt.Errorf("%s checksum %s doesn't match %s", ss[1], s, ss[0]))
+		}
+	}
+
+	// Loop through the folders of listed files
+	for _, d := range dirs {
+		// List files
+		var ps []string
+		if ps, err = listFiles(filepath.Join(dir, d), func(p string, fi os.FileInfo) bool { return true }); err != nil {
+			errs.Add(fmt.Errorf("listing files of %s failed: %w", d, err))
+			continue
+		}
+
+		// Files must be listed
+		for _, p := range ps {
+			rp, _ := filepath.Rel(dir, p)
+			if rp = filepath.ToSlash(rp); !listed[rp] {
+				errs.Add(fmt.Errorf("%s is not listed in %s", rp, ChecksumsFileName))
+			}
+		}
+	}
+	if !errs.IsNil() {
+		err = errs
+		return
+	}
+	return
+}
+
+// isLocalSlashPath checks whether a slash separated path is relative and stays inside its dir
+func isLocalSlashPath(p string) bool {
+	if len(p) == 0 || path.IsAbs(p) || filepath.IsAbs(p) || len(filepath.VolumeName(p)) > 0 || strings.Contains(p, "\\") {
+		return false
+	}
+	for _, s := range strings.Split(p, "/") {
+		if s == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+package astibundler
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecksums(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Keys
+	pk, sk, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := x509.MarshalPKCS8PrivateKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, "key.pem", string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: bs})))
+	if bs, err = x509.MarshalPKIXPublicKey(pk); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, "key.pub.pem", string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: bs})))
+	keyID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	msk := append(append(append([]byte("Ed\x00\x00B2"), make([]byte, 48)...), keyID...), sk...)
+	writeTestFile(t, dir, "minisign.key", "untrusted comment: minisign secret key\n"+base64.StdEncoding.EncodeToString(append(msk, make([]byte, 32)...))+"\n")
+	writeTestFile(t, dir, "minisign.pub", "untrusted comment: minisign public key\n"+base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), pk...))+"\n")
+	opk, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, "other.pub", "untrusted comment: minisign public key\n"+base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), opk...))+"\n")
+
+	// Artifacts
+	writeTestFile(t, dir, "output/linux-amd64/App", "linux")
+	writeTestFile(t, dir, "output/darwin-amd64/App.app/Contents/MacOS/App", "darwin")
+	writeTestFile(t, dir, "output/other.txt", "other")
+
+	for _, k := range []struct{ private, public string }{
+		{private: "key.pem", public: "key.pub.pem"},
+		{private: "minisign.key", public: "minisign.pub"},
+	} {
+		// Environments that have not been bundled this time are listed as well
+		b, err := New(&Configuration{
+			ChecksumsSigningKeyPath: filepath.Join(dir, k.private),
+			Environments:            []ConfigurationEnvironment{{Arch: "amd64", OS: "linux"}, {Arch: "386", OS: "windows"}},
+			OutputPath:              filepath.Join(dir, "output"),
+		}, log.New(ioutil.Discard, "", 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.NoError(t, b.writeChecksums())
+		o, err := ioutil.ReadFile(filepath.Join(dir, "output", ChecksumsFileName))
+		assert.NoError(t, err)
+		assert.Equal(t, `26ce1a1580f693873b6268fef54c5f0d0607f2896cad02ce2894c0c899a11575  darwin-amd64/App.app/Contents/MacOS/App
+caf90169eefa5f807d577486b9f795ab86ae2983c5c20806cff959117e90af18  linux-amd64/App
+`, string(o))
+		assert.NoError(t, VerifyChecksums(filepath.Join(dir, "output"), filepath.Join(dir, k.public)))
+	}
+	assert.Contains(t, VerifyChecksums(filepath.Join(dir, "output"), filepath.Join(dir, "other.pub")).Error(), "signature doesn't match")
+
+	writeTestFile(t, dir, "output/linux-amd64/App", "tampered")
+	assert.EqualError(t, VerifyChecksums(filepath.Join(dir, "output"), ""), "linux-amd64/App checksum d121be3103007b41edf96f8262925f8c7d61894afe9a041843b631f69445bc57 doesn't match caf90169eefa5f807d577486b9f795ab86ae2983c5c20806cff959117e90af18")
+
+	// Unlisted files of listed folders
+	writeTestFile(t, dir, "output/linux-amd64/App", "linux")
+	writeTestFile(t, dir, "output/linux-amd64/injected.so", "injected")
+	assert.EqualError(t, VerifyChecksums(filepath.Join(dir, "output"), ""), "linux-amd64/injected.so is not listed in SHA256SUMS")
+	assert.NoError(t, os.Remove(filepath.Join(dir, "output/linux-amd64/injected.so")))
+
+	// Paths outside the dir
+	for _, p := range []string{"../other.pub", "linux-amd64/../../other.pub", "/etc/passwd", `..\other.pub`} {
+		writeTestFile(t, dir, "output/"+ChecksumsFileName, "caf90169eefa5f807d577486b9f795ab86ae2983c5c20806cff959117e90af18  "+p+"\n")
+		assert.EqualError(t, VerifyChecksums(filepath.Join(dir, "output"), ""), "line 1: path "+p+" is not relative to the dir", p)
+	}
+}
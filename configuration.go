@@ -0,0 +1,676 @@
+package astibundler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Configuration formats
+const (
+	ConfigurationFormatJSON = "json"
+	ConfigurationFormatTOML = "toml"
+	ConfigurationFormatYAML = "yaml"
+)
+
+// DefaultConfigurationNames represents the configuration file names looked up, in order, when no
+// configuration path is provided
+var DefaultConfigurationNames = []string{
+	"bundler.json",
+	"bundler.yaml",
+	"bundler.yml",
+	"bundler.toml",
+}
+
+// ConfigurationFormat returns the configuration format based on the path extension
+func ConfigurationFormat(path string) (f string, err error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		f = ConfigurationFormatJSON
+	case ".toml":
+		f = ConfigurationFormatTOML
+	case ".yaml", ".yml":
+		f = ConfigurationFormatYAML
+	default:
+		err = fmt.Errorf("extension of %s is not supported", path)
+	}
+	return
+}
+
+// FindConfigurationPath returns the path of the first default configuration file found in a directory
+func FindConfigurationPath(dir string) (p string, err error) {
+	for _, n := range DefaultConfigurationNames {
+		p = filepath.Join(dir, n)
+		if _, err = os.Stat(p); err == nil {
+			return
+		} else if !os.IsNotExist(err) {
+			err = fmt.Errorf("stating %s failed: %w", p, err)
+			return
+		}
+	}
+	err = fmt.Errorf("no configuration file found in %s, looked for %s", dir, strings.Join(DefaultConfigurationNames, ", "))
+	return
+}
+
+// LoadConfiguration loads a configuration file whose format is detected based on its extension.
+// Base configurations referenced by the "extends" key are loaded and deep merged with their overrides
+// and, if not empty, the profile is merged last. Environment variables are then interpolated.
+func LoadConfiguration(path, profile string) (c *Configuration, err error) {
+	// Resolve extends
+	var f *configurationFile
+	if f, err = resolveConfigurationFile(path, make(map[string]bool)); err != nil {
+		err = fmt.Errorf("resolving configuration file %s failed: %w", path, err)
+		return
+	}
+
+	// Check unknown keys
+	if err = f.checkUnknownKeys(); err != nil {
+		err = fmt.Errorf("checking unknown keys failed: %w", err)
+		return
+	}
+
+	// Apply profile
+	if err = f.applyProfile(profile); err != nil {
+		err = fmt.Errorf("applying profile %s failed: %w", profile, err)
+		return
+	}
+
+	// Decode
+	if c, err = f.decode(); err != nil {
+		err = fmt.Errorf("decoding configuration file %s failed: %w", path, err)
+		return
+	}
+
+	// Interpolate
+	if err = c.Interpolate(os.LookupEnv); err != nil {
+		err = fmt.Errorf("interpolating configuration file %s failed: %w", path, err)
+		return
+	}
+	return
+}
+
+// configurationFile represents a configuration file that has been read but not yet decoded
+type configurationFile struct {
+	path string
+	// Positions ("file:line") indexed by dotted field path (e.g. "environments.0.arch"), when the format
+	// provides them
+	positions map[string]string
+	value     map[string]interface{}
+}
+
+// resolveConfigurationFile reads a configuration file and recursively merges it on top of the
+// configuration it extends
+func resolveConfigurationFile(path string, visited map[string]bool) (f *configurationFile, err error) {
+	// Absolute path
+	if path, err = filepath.Abs(path); err != nil {
+		err = fmt.Errorf("filepath.Abs of %s failed: %w", path, err)
+		return
+	}
+
+	// Check cycles
+	if visited[path] {
+		err = fmt.Errorf("%s is extended in a cycle", path)
+		return
+	}
+	visited[path] = true
+
+	// Read file
+	if f, err = readConfigurationFile(path); err != nil {
+		err = fmt.Errorf("reading configuration file %s failed: %w", path, err)
+		return
+	}
+
+	// Nothing to extend
+	i, ok := f.value["extends"]
+	if !ok {
+		return
+	}
+	delete(f.value, "extends")
+
+	// Get base path
+	var bp string
+	if bp, ok = i.(string); !ok || bp == "" {
+		err = fmt.Errorf("%s: extends must be a non-empty string", f.position("extends"))
+		return
+	}
+	if !filepath.IsAbs(bp) {
+		bp = filepath.Join(filepath.Dir(path), bp)
+	}
+
+	// Resolve base
+	var base *configurationFile
+	if base, err = resolveConfigurationFile(bp, visited); err != nil {
+		err = fmt.Errorf("resolving configuration file %s failed: %w", bp, err)
+		return
+	}
+
+	// Merge
+	base.merge(f.value, f.positions, "")
+	base.path = f.path
+	f = base
+	return
+}
+
+// applyProfile merges the profile on top of the configuration and removes the profiles
+func (f *configurationFile) applyProfile(profile string) (err error) {
+	// Get profiles
+	ps, _ := f.value["profiles"].(map[string]interface{})
+	delete(f.value, "profiles")
+
+	// No profile
+	if profile == "" {
+		return
+	}
+
+	// Get profile
+	p, ok := ps[profile].(map[string]interface{})
+	if !ok {
+		var names []string
+		for n := range ps {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		err = fmt.Errorf("profile %s doesn't exist, available profiles are [%s]", profile, strings.Join(names, ", "))
+		return
+	}
+
+	// Merge
+	f.merge(p, f.positions, "profiles."+profile+".")
+	return
+}
+
+// merge deep merges values on top of the configuration file. Maps are merged recursively whereas
+// other values, including lists, are replaced.
+func (f *configurationFile) merge(value map[string]interface{}, positions map[string]string, prefix string) {
+	// Merge values
+	mergeConfigurationValues(f.value, value)
+
+	// Merge positions
+	for k, p := range positions {
+		if strings.HasPrefix(k, prefix) {
+			f.positions[strings.TrimPrefix(k, prefix)] = p
+		}
+	}
+}
+
+func mergeConfigurationValues(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if sm, ok := v.(map[string]interface{}); ok {
+			if dm, ok := dst[k].(map[string]interface{}); ok {
+				mergeConfigurationValues(dm, sm)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
+// checkUnknownKeys returns an error listing keys that don't match any configuration field, profiles
+// included
+func (f *configurationFile) checkUnknownKeys() error {
+	// Root
+	t := reflect.TypeOf(Configuration{})
+	ks := unknownConfigurationKeys(f.value, t, "")
+
+	// Profiles
+	ps, _ := f.value["profiles"].(map[string]interface{})
+	for n, p := range ps {
+		ks = append(ks, unknownConfigurationKeys(p, t, "profiles."+n)...)
+	}
+
+	// No unknown keys
+	if len(ks) == 0 {
+		return nil
+	}
+
+	// Create error
+	sort.Strings(ks)
+	var ss []string
+	for _, k := range ks {
+		ss = append(ss, fmt.Sprintf("%s: %s", f.position(k), k))
+	}
+	return fmt.Errorf("unknown keys: %s", strings.Join(ss, ", "))
+}
+
+// unknownConfigurationKeys returns the dotted paths of keys that don't match any field of the type
+func unknownConfigurationKeys(i interface{}, t reflect.Type, path string) (ks []string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		m, _ := i.(map[string]interface{})
+		for k, v := range m {
+			ks = append(ks, unknownConfigurationKeys(v, t.Elem(), joinConfigurationPath(path, k))...)
+		}
+	case reflect.Slice:
+		s, _ := i.([]interface{})
+		for idx, v := range s {
+			ks = append(ks, unknownConfigurationKeys(v, t.Elem(), joinConfigurationPath(path, strconv.Itoa(idx)))...)
+		}
+	case reflect.Struct:
+		// Index fields
+		fs := make(map[string]reflect.Type)
+		for idx := 0; idx < t.NumField(); idx++ {
+			f := t.Field(idx)
+			n := strings.Split(f.Tag.Get("json"), ",")[0]
+			if n == "-" {
+				continue
+			} else if n == "" {
+				n = f.Name
+			}
+			fs[n] = f.Type
+		}
+
+		// Loop through keys
+		m, _ := i.(map[string]interface{})
+		for k, v := range m {
+			ft, ok := fs[k]
+			if !ok {
+				ks = append(ks, joinConfigurationPath(path, k))
+				continue
+			}
+			ks = append(ks, unknownConfigurationKeys(v, ft, joinConfigurationPath(path, k))...)
+		}
+	}
+	return
+}
+
+// position returns the position of a field or of its closest parent if known or the file path otherwise
+func (f *configurationFile) position(field string) string {
+	for field != "" {
+		if p, ok := f.positions[field]; ok {
+			return p
+		}
+		if idx := strings.LastIndex(field, "."); idx >= 0 {
+			field = field[:idx]
+		} else {
+			field = ""
+		}
+	}
+	return f.path
+}
+
+// readConfigurationFile reads a configuration file into generic values
+func readConfigurationFile(path string) (f *configurationFile, err error) {
+	// Get format
+	var format string
+	if format, err = ConfigurationFormat(path); err != nil {
+		err = fmt.Errorf("getting configuration format failed: %w", err)
+		return
+	}
+
+	// Read file
+	var b []byte
+	if b, err = ioutil.ReadFile(path); err != nil {
+		err = fmt.Errorf("reading %s failed: %w", path, err)
+		return
+	}
+
+	// Parse
+	f = &configurationFile{
+		path:      path,
+		positions: make(map[string]string),
+		value:     make(map[string]interface{}),
+	}
+	switch format {
+	case ConfigurationFormatJSON:
+		err = f.parseJSON(b)
+	case ConfigurationFormatTOML:
+		err = f.parseTOML(b)
+	case ConfigurationFormatYAML:
+		err = f.parseYAML(b)
+	}
+	return
+}
+
+func (f *configurationFile) parseJSON(b []byte) (err error) {
+	// Type errors are only reported with their offset when decoding straight into the configuration
+	if err = json.Unmarshal(b, &Configuration{}); err != nil {
+		err = f.jsonError(b, err)
+		return
+	}
+
+	// Unmarshal
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err = d.Decode(&f.value); err != nil {
+		err = f.jsonError(b, err)
+		return
+	}
+
+	// Index positions
+	if err = f.indexJSONPositions(json.NewDecoder(bytes.NewReader(b)), b, ""); err != nil {
+		err = f.jsonError(b, err)
+		return
+	}
+	return
+}
+
+// indexJSONPositions stores the position of every value read by the decoder indexed by its dotted path
+func (f *configurationFile) indexJSONPositions(d *json.Decoder, b []byte, path string) (err error) {
+	// Read token
+	var t json.Token
+	if t, err = d.Token(); err != nil {
+		return
+	}
+
+	// Loop through children
+	switch t {
+	case json.Delim('{'):
+		for d.More() {
+			l := jsonLine(b, d.InputOffset())
+			if t, err = d.Token(); err != nil {
+				return
+			}
+			p := joinConfigurationPath(path, fmt.Sprintf("%v", t))
+			f.positions[p] = fmt.Sprintf("%s:%d", f.path, l)
+			if err = f.indexJSONPositions(d, b, p); err != nil {
+				return
+			}
+		}
+	case json.Delim('['):
+		for idx := 0; d.More(); idx++ {
+			p := joinConfigurationPath(path, strconv.Itoa(idx))
+			f.positions[p] = fmt.Sprintf("%s:%d", f.path, jsonLine(b, d.InputOffset()))
+			if err = f.indexJSONPositions(d, b, p); err != nil {
+				return
+			}
+		}
+	default:
+		return
+	}
+
+	// Read closing delimiter
+	_, err = d.Token()
+	return
+}
+
+// jsonLine returns the line of the next token starting at the offset
+func jsonLine(b []byte, offset int64) int {
+	for offset < int64(len(b)) && strings.IndexByte(" \t\r\n,:", b[offset]) >= 0 {
+		offset++
+	}
+	return bytes.Count(b[:offset], []byte("\n")) + 1
+}
+
+// jsonError adds the line number to json errors that provide an offset
+func (f *configurationFile) jsonError(b []byte, err error) error {
+	var offset int64
+	var se *json.SyntaxError
+	var te *json.UnmarshalTypeError
+	if errors.As(err, &se) {
+		offset = se.Offset
+	} else if errors.As(err, &te) {
+		offset = te.Offset
+	} else {
+		return err
+	}
+	if offset > int64(len(b)) {
+		offset = int64(len(b))
+	}
+	return fmt.Errorf("%s:%d: %w", f.path, bytes.Count(b[:offset], []byte("\n"))+1, err)
+}
+
+func (f *configurationFile) parseTOML(b []byte) (err error) {
+	// TOML errors already contain the line number
+	if _, err = toml.Decode(string(b), &f.value); err != nil {
+		err = fmt.Errorf("%s: %w", f.path, err)
+		return
+	}
+
+	// Arrays of tables are decoded as []map[string]interface{}
+	f.value = normalizeConfigurationValue(f.value).(map[string]interface{})
+
+	// Index positions
+	f.indexTOMLPositions(b)
+	return
+}
+
+// indexTOMLPositions stores the position of every table and key indexed by its dotted path. Keys of
+// inline tables are not indexed and share the position of their parent.
+func (f *configurationFile) indexTOMLPositions(b []byte) {
+	var table, multiline string
+	arrays := make(map[string]int)
+	for idx, l := range strings.Split(string(b), "\n") {
+		// Multiline string
+		if multiline != "" {
+			if strings.Count(l, multiline)%2 == 1 {
+				multiline = ""
+			}
+			continue
+		}
+
+		// Empty line or comment
+		if l = strings.TrimSpace(l); l == "" || l[0] == '#' {
+			continue
+		}
+
+		// Array of tables
+		position := fmt.Sprintf("%s:%d", f.path, idx+1)
+		if strings.HasPrefix(l, "[[") {
+			p, _ := f.resolveTOMLKey(l[2:], arrays)
+			table = joinConfigurationPath(p, strconv.Itoa(arrays[p]))
+			arrays[p]++
+			if _, ok := f.positions[p]; !ok {
+				f.positions[p] = position
+			}
+			f.positions[table] = position
+			continue
+		}
+
+		// Table
+		if l[0] == '[' {
+			table, _ = f.resolveTOMLKey(l[1:], arrays)
+			f.positions[table] = position
+			continue
+		}
+
+		// Key
+		k, v := f.resolveTOMLKey(l, nil)
+		if !strings.HasPrefix(v, "=") {
+			continue
+		}
+		f.positions[joinConfigurationPath(table, k)] = position
+
+		// Multiline string
+		v = strings.TrimSpace(v[1:])
+		for _, d := range []string{`"""`, "'''"} {
+			if strings.HasPrefix(v, d) && strings.Count(v, d) == 1 {
+				multiline = d
+			}
+		}
+	}
+}
+
+// resolveTOMLKey parses the dotted key at the beginning of the line and returns its path, where parent
+// arrays of tables point to their last element, and the rest of the line
+func (f *configurationFile) resolveTOMLKey(l string, arrays map[string]int) (path, rest string) {
+	var k []byte
+	var quote byte
+	for idx := 0; idx < len(l); idx++ {
+		c := l[idx]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				k = append(k, c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '.' || c == '=' || c == ']':
+			path = joinConfigurationPath(path, string(k))
+			k = k[:0]
+			if c != '.' {
+				rest = l[idx:]
+				return
+			}
+			if n, ok := arrays[path]; ok {
+				path = joinConfigurationPath(path, strconv.Itoa(n-1))
+			}
+		case c != ' ' && c != '\t':
+			k = append(k, c)
+		}
+	}
+	return
+}
+
+func (f *configurationFile) parseYAML(b []byte) (err error) {
+	// Unmarshal
+	var n yaml.Node
+	if err = yaml.Unmarshal(b, &n); err != nil {
+		err = fmt.Errorf("%s: %w", f.path, err)
+		return
+	}
+
+	// Empty document
+	if len(n.Content) == 0 {
+		return
+	}
+
+	// Decode
+	var v interface{}
+	if err = n.Decode(&v); err != nil {
+		err = fmt.Errorf("%s: %w", f.path, err)
+		return
+	}
+
+	// Make sure the root is an object
+	var ok bool
+	if f.value, ok = normalizeConfigurationValue(v).(map[string]interface{}); !ok {
+		err = fmt.Errorf("%s:%d: root must be a mapping", f.path, n.Content[0].Line)
+		return
+	}
+
+	// Index positions
+	f.indexYAMLPositions(n.Content[0], "")
+	return
+}
+
+// normalizeConfigurationValue makes sure decoded values only contain map[string]interface{} and
+// []interface{} so that they can be checked, merged and marshaled to json
+func normalizeConfigurationValue(i interface{}) interface{} {
+	switch v := i.(type) {
+	case map[string]interface{}:
+		for k, vv := range v {
+			v[k] = normalizeConfigurationValue(vv)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{})
+		for k, vv := range v {
+			m[fmt.Sprintf("%v", k)] = normalizeConfigurationValue(vv)
+		}
+		return m
+	case []interface{}:
+		for idx, vv := range v {
+			v[idx] = normalizeConfigurationValue(vv)
+		}
+		return v
+	case []map[string]interface{}:
+		s := make([]interface{}, len(v))
+		for idx, vv := range v {
+			s[idx] = normalizeConfigurationValue(vv)
+		}
+		return s
+	}
+	return i
+}
+
+// indexYAMLPositions stores the position of every node indexed by its dotted path
+func (f *configurationFile) indexYAMLPositions(n *yaml.Node, path string) {
+	if path != "" {
+		f.positions[path] = fmt.Sprintf("%s:%d", f.path, n.Line)
+	}
+	switch n.Kind {
+	case yaml.MappingNode:
+		for idx := 0; idx+1 < len(n.Content); idx += 2 {
+			f.indexYAMLPositions(n.Content[idx+1], joinConfigurationPath(path, n.Content[idx].Value))
+		}
+	case yaml.SequenceNode:
+		for idx, c := range n.Content {
+			f.indexYAMLPositions(c, joinConfigurationPath(path, strconv.Itoa(idx)))
+		}
+	}
+}
+
+func joinConfigurationPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// decode decodes the generic values into a configuration
+func (f *configurationFile) decode() (c *Configuration, err error) {
+	// Marshal
+	var b []byte
+	if b, err = json.Marshal(f.value); err != nil {
+		err = fmt.Errorf("marshaling failed: %w", err)
+		return
+	}
+
+	// Unmarshal
+	c = &Configuration{}
+	if err = json.Unmarshal(b, c); err != nil {
+		var te *json.UnmarshalTypeError
+		if errors.As(err, &te) {
+			err = fmt.Errorf("%s: %w", f.position(te.Field), err)
+		}
+		return
+	}
+	return
+}
+
+// FilterEnvironments only keeps environments whose "os/arch" matches at least one of the patterns.
+// Patterns follow path.Match syntax (e.g. "linux/*") and an error is returned if a pattern matches
+// no environment.
+func (c *Configuration) FilterEnvironments(patterns []string) (err error) {
+	// Nothing to filter
+	if len(patterns) == 0 {
+		return
+	}
+
+	// Loop through environments
+	var es []ConfigurationEnvironment
+	matched := make(map[string]bool)
+	for _, e := range c.Environments {
+		var keep bool
+		for _, p := range patterns {
+			var ok bool
+			if ok, err = path.Match(p, e.OS+"/"+e.Arch); err != nil {
+				err = fmt.Errorf("matching pattern %s failed: %w", p, err)
+				return
+			} else if ok {
+				keep = true
+				matched[p] = true
+			}
+		}
+		if keep {
+			es = append(es, e)
+		}
+	}
+
+	// Make sure every pattern has matched
+	for _, p := range patterns {
+		if !matched[p] {
+			err = fmt.Errorf("pattern %s doesn't match any environment", p)
+			return
+		}
+	}
+	c.Environments = es
+	return
+}
@@ -0,0 +1,173 @@
+package astibundler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfiguration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	e := &Configuration{
+		AppName:      "Test",
+		Environments: []ConfigurationEnvironment{{Arch: "amd64", OS: "linux", EnvironmentVariables: map[string]string{"CGO_ENABLED": "0"}}},
+		InfoPlist:    map[string]interface{}{"CFBundleVersion": "1.0.0"},
+		LDFlags:      LDFlags{"X": []string{"main.Version=1"}},
+	}
+
+	// JSON
+	c, err := LoadConfiguration(writeTestFile(t, dir, "bundler.json", `{
+	"app_name": "Test",
+	"environments": [{"arch": "amd64", "os": "linux", "env": {"CGO_ENABLED": "0"}}],
+	"info_plist": {"CFBundleVersion": "1.0.0"},
+	"ldflags": {"X": ["main.Version=1"]}
+}`), "")
+	assert.NoError(t, err)
+	assert.Equal(t, e, c)
+
+	// YAML
+	c, err = LoadConfiguration(writeTestFile(t, dir, "bundler.yml", `# Comment
+app_name: Test
+environments:
+  - arch: amd64
+    os: linux
+    env:
+      CGO_ENABLED: "0"
+info_plist:
+  CFBundleVersion: 1.0.0
+ldflags:
+  X: [main.Version=1]
+`), "")
+	assert.NoError(t, err)
+	assert.Equal(t, e, c)
+
+	// TOML
+	c, err = LoadConfiguration(writeTestFile(t, dir, "bundler.toml", `# Comment
+app_name = "Test"
+
+[info_plist]
+CFBundleVersion = "1.0.0"
+
+[ldflags]
+X = ["main.Version=1"]
+
+[[environments]]
+arch = "amd64"
+os = "linux"
+env = { CGO_ENABLED = "0" }
+`), "")
+	assert.NoError(t, err)
+	assert.Equal(t, e, c)
+
+	// Errors
+	_, err = LoadConfiguration(writeTestFile(t, dir, "invalid.json", "{\n\t\"app_name\": 1\n}"), "")
+	assert.Contains(t, err.Error(), "invalid.json:2:")
+	_, err = LoadConfiguration(writeTestFile(t, dir, "invalid.yaml", "app_name: Test\nenvironments:\n  - arch: [amd64]\n"), "")
+	assert.Contains(t, err.Error(), "invalid.yaml:3:")
+	_, err = LoadConfiguration(writeTestFile(t, dir, "invalid.toml", "app_name = \"Test\"\nenvironments = ["), "")
+	assert.Contains(t, err.Error(), "line 2")
+	_, err = LoadConfiguration(writeTestFile(t, dir, "invalid.xml", ""), "")
+	assert.Error(t, err)
+	_, err = LoadConfiguration(writeTestFile(t, dir, "unknown.yaml", "app_name: Test\nicon_path_mac: icon.icns\nenvironments:\n  - archh: amd64\nprofiles:\n  dev:\n    outputpath: dev\n"), "")
+	assert.Contains(t, err.Error(), "unknown.yaml:4: environments.0.archh, ")
+	assert.Contains(t, err.Error(), "unknown.yaml:2: icon_path_mac, ")
+	assert.Contains(t, err.Error(), "unknown.yaml:7: profiles.dev.outputpath")
+	_, err = LoadConfiguration(writeTestFile(t, dir, "unknown.toml", "app_name = \"Test\"\n\n[[environments]]\narhc = \"amd64\"\nos = \"linux\"\n"), "")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown.toml:4: environments.0.arhc")
+	}
+	_, err = LoadConfiguration(writeTestFile(t, dir, "unknown.json", "{\n\t\"app_name\": \"Test\",\n\t\"environments\": [{\"arhc\": \"amd64\", \"os\": \"linux\"}]\n}"), "")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown.json:3: environments.0.arhc")
+	}
+	_, err = LoadConfiguration(writeTestFile(t, dir, "type.toml", "app_name = \"Test\"\n\n[bind]\npackage = 1\n"), "")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "type.toml:4: ")
+	}
+	_, err = LoadConfiguration(writeTestFile(t, dir, "type.yaml", "app_name: Test\nbind:\n  package: [main]\n"), "")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "type.yaml:3: ")
+	}
+
+	// Find
+	p, err := FindConfigurationPath(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "bundler.json"), p)
+}
+
+func TestLoadConfigurationExtends(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeTestFile(t, dir, "base.json", `{
+	"app_name": "Test",
+	"bind": {"output_path": "bind", "package": "main"},
+	"environments": [{"arch": "amd64", "os": "linux"}],
+	"profiles": {
+		"production": {"ldflags": {"s": []}, "output_path": "dist"}
+	}
+}`)
+	p := writeTestFile(t, dir, "bundler.yaml", `extends: base.json
+bind:
+  package: bind
+environments:
+  - arch: amd64
+    os: darwin
+profiles:
+  staging:
+    output_path: staging
+`)
+
+	// No profile
+	c, err := LoadConfiguration(p, "")
+	assert.NoError(t, err)
+	assert.Equal(t, &Configuration{
+		AppName:      "Test",
+		Bind:         ConfigurationBind{OutputPath: "bind", Package: "bind"},
+		Environments: []ConfigurationEnvironment{{Arch: "amd64", OS: "darwin"}},
+	}, c)
+
+	// Profiles
+	c, err = LoadConfiguration(p, "production")
+	assert.NoError(t, err)
+	assert.Equal(t, "dist", c.OutputPath)
+	assert.Equal(t, LDFlags{"s": []string{}}, c.LDFlags)
+	c, err = LoadConfiguration(p, "staging")
+	assert.NoError(t, err)
+	assert.Equal(t, "staging", c.OutputPath)
+	_, err = LoadConfiguration(p, "invalid")
+	assert.Contains(t, err.Error(), "available profiles are [production, staging]")
+
+	// Cycle
+	writeTestFile(t, dir, "base.json", `{"extends": "bundler.yaml"}`)
+	_, err = LoadConfiguration(p, "")
+	assert.Contains(t, err.Error(), "cycle")
+}
+
+func TestConfigurationFilterEnvironments(t *testing.T) {
+	c := &Configuration{Environments: []ConfigurationEnvironment{
+		{Arch: "amd64", OS: "darwin"},
+		{Arch: "amd64", OS: "linux"},
+		{Arch: "arm64", OS: "linux"},
+		{Arch: "amd64", OS: "windows"},
+	}}
+	assert.NoError(t, c.FilterEnvironments([]string{"linux/*", "windows/amd64"}))
+	assert.Equal(t, []ConfigurationEnvironment{
+		{Arch: "amd64", OS: "linux"},
+		{Arch: "arm64", OS: "linux"},
+		{Arch: "amd64", OS: "windows"},
+	}, c.Environments)
+	assert.EqualError(t, c.FilterEnvironments([]string{"linux/*", "darwin/*"}), "pattern darwin/* doesn't match any environment")
+	assert.Error(t, c.FilterEnvironments([]string{"linux/["}))
+}
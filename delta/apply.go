@@ -0,0 +1,98 @@
+// Package delta creates and applies bsdiff style binary patches so that updaters can download the
+// difference between two releases instead of the whole files.
+package delta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// magic is the header of patches
+const magic = "ASTIDLT1"
+
+// ErrCorruptPatch is returned when a patch is corrupt
+var ErrCorruptPatch = errors.New("delta: corrupt patch")
+
+func encodeInt64(i int64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(i))
+	return b
+}
+
+func readInt64(r io.Reader) (i int64, err error) {
+	b := make([]byte, 8)
+	if _, err = io.ReadFull(r, b); err != nil {
+		return
+	}
+	i = int64(binary.LittleEndian.Uint64(b))
+	return
+}
+
+// Apply returns the result of applying a patch created by Diff to old
+func Apply(old []byte, patch io.Reader) (new []byte, err error) {
+	// Read header
+	h := make([]byte, len(magic))
+	if _, err = io.ReadFull(patch, h); err != nil || !bytes.Equal(h, []byte(magic)) {
+		err = ErrCorruptPatch
+		return
+	}
+	var size int64
+	if size, err = readInt64(patch); err != nil || size < 0 || size >= math.MaxInt32 {
+		err = ErrCorruptPatch
+		return
+	}
+
+	// Create zstd reader
+	var r *zstd.Decoder
+	if r, err = zstd.NewReader(patch, zstd.WithDecoderConcurrency(1)); err != nil {
+		err = fmt.Errorf("delta: creating zstd reader failed: %w", err)
+		return
+	}
+	defer r.Close()
+
+	// Loop through control triples
+	new = make([]byte, size)
+	var oldPos, newPos int64
+	for newPos < size {
+		// Read triple
+		var ctrl [3]int64
+		for idx := range ctrl {
+			if ctrl[idx], err = readInt64(r); err != nil {
+				err = fmt.Errorf("%w: reading control failed: %v", ErrCorruptPatch, err)
+				return
+			}
+		}
+		if ctrl[0] < 0 || ctrl[1] < 0 || newPos+ctrl[0]+ctrl[1] > size {
+			err = ErrCorruptPatch
+			return
+		}
+
+		// Add diff to old
+		if _, err = io.ReadFull(r, new[newPos:newPos+ctrl[0]]); err != nil {
+			err = fmt.Errorf("%w: reading diff failed: %v", ErrCorruptPatch, err)
+			return
+		}
+		for idx := int64(0); idx < ctrl[0]; idx++ {
+			if oldPos+idx >= 0 && oldPos+idx < int64(len(old)) {
+				new[newPos+idx] += old[oldPos+idx]
+			}
+		}
+		newPos += ctrl[0]
+		oldPos += ctrl[0]
+
+		// Copy extra
+		if _, err = io.ReadFull(r, new[newPos:newPos+ctrl[1]]); err != nil {
+			err = fmt.Errorf("%w: reading extra failed: %v", ErrCorruptPatch, err)
+			return
+		}
+		newPos += ctrl[1]
+		oldPos += ctrl[2]
+	}
+	return
+}
@@ -0,0 +1,72 @@
+package delta
+
+import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQsufsort(t *testing.T) {
+	b := []byte("mississippi banana bandana")
+	i := qsufsort(b)
+	assert.Len(t, i, len(b)+1)
+	assert.Equal(t, int32(len(b)), i[0])
+	assert.True(t, sort.SliceIsSorted(i, func(x, y int) bool { return bytes.Compare(b[i[x]:], b[i[y]:]) < 0 }))
+}
+
+func TestDiffApply(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	old := make([]byte, 1<<16)
+	r.Read(old)
+	new := append([]byte{}, old[:20000]...)
+	new = append(new, []byte("inserted bytes")...)
+	new = append(new, old[20000:40000]...)
+	for idx := 30000; idx < 30100; idx++ {
+		new[idx]++
+	}
+	new = append(new, old[50000:]...)
+
+	for _, v := range []struct{ old, new []byte }{
+		{old: old, new: new},
+		{old: nil, new: []byte("new")},
+		{old: []byte("old"), new: nil},
+		{old: nil, new: nil},
+	} {
+		p, err := Diff(v.old, v.new)
+		assert.NoError(t, err)
+		o, err := Apply(v.old, bytes.NewReader(p))
+		assert.NoError(t, err)
+		assert.Equal(t, len(v.new), len(o))
+		assert.True(t, bytes.Equal(v.new, o))
+	}
+
+	p, err := Diff(old, new)
+	assert.NoError(t, err)
+	assert.True(t, len(p) < 1000)
+	_, err = Apply(old, bytes.NewReader(p[:len(p)-10]))
+	assert.Error(t, err)
+	_, err = Apply(old, bytes.NewReader([]byte("invalid")))
+	assert.Equal(t, ErrCorruptPatch, err)
+}
+
+func TestIndexPatchApply(t *testing.T) {
+	old, new := []byte("old content"), []byte("new content")
+	p, err := Diff(old, new)
+	assert.NoError(t, err)
+	ip := IndexPatch{
+		From:  NewIndexFile("App", old),
+		Patch: NewIndexFile("linux-amd64/App.patch", p),
+		To:    NewIndexFile("App", new),
+	}
+	o, err := ip.Apply(old, p)
+	assert.NoError(t, err)
+	assert.Equal(t, new, o)
+	_, err = ip.Apply([]byte("other content"), p)
+	assert.Error(t, err)
+	ip.To = NewIndexFile("App", []byte("other content"))
+	_, err = ip.Apply(old, p)
+	assert.Error(t, err)
+}
@@ -0,0 +1,329 @@
+package delta
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Diff returns the patch turning old into new. It uses bsdiff's algorithm: old is suffix sorted,
+// approximate matches of new are looked for in it and the patch is made of control triples, byte-wise
+// differences of the approximate matches, which mostly are zeros, and extra bytes that couldn't be
+// matched, the whole being zstd compressed.
+func Diff(old, new []byte) (patch []byte, err error) {
+	// Check sizes
+	if len(old) >= math.MaxInt32 || len(new) >= math.MaxInt32 {
+		err = errors.New("delta: files must be smaller than 2GB")
+		return
+	}
+
+	// Write header
+	buf := &bytes.Buffer{}
+	buf.WriteString(magic)
+	buf.Write(encodeInt64(int64(len(new))))
+
+	// Create zstd writer
+	var w *zstd.Encoder
+	if w, err = zstd.NewWriter(buf, zstd.WithEncoderLevel(zstd.SpeedBetterCompression)); err != nil {
+		err = fmt.Errorf("delta: creating zstd writer failed: %w", err)
+		return
+	}
+
+	// Loop through control triples
+	d := &differ{i: qsufsort(old), new: new, old: old}
+	for d.next() {
+		ctrl := append(append(encodeInt64(int64(len(d.diff))), encodeInt64(int64(len(d.extra)))...), encodeInt64(int64(d.seek))...)
+		for _, b := range [][]byte{ctrl, d.diff, d.extra} {
+			if _, err = w.Write(b); err != nil {
+				w.Close()
+				err = fmt.Errorf("delta: writing failed: %w", err)
+				return
+			}
+		}
+	}
+
+	// Close zstd writer
+	if err = w.Close(); err != nil {
+		err = fmt.Errorf("delta: closing zstd writer failed: %w", err)
+		return
+	}
+	patch = buf.Bytes()
+	return
+}
+
+// differ computes control triples of a patch one at a time
+type differ struct {
+	diff       []byte
+	extra      []byte
+	i          []int32
+	lastOffset int
+	lastPos    int
+	lastScan   int
+	len        int
+	new        []byte
+	old        []byte
+	pos        int
+	scan       int
+	seek       int
+}
+
+// next computes the next control triple and returns false once new has been scanned entirely
+func (d *differ) next() bool {
+	for d.scan < len(d.new) {
+		// Look for the next approximate match that is not an extension of the previous one
+		oldScore := 0
+		d.scan += d.len
+		for scsc := d.scan; d.scan < len(d.new); d.scan++ {
+			d.pos, d.len = search(d.i, d.old, d.new[d.scan:], 0, len(d.old))
+			for ; scsc < d.scan+d.len; scsc++ {
+				if scsc+d.lastOffset < len(d.old) && d.old[scsc+d.lastOffset] == d.new[scsc] {
+					oldScore++
+				}
+			}
+			if (d.len == oldScore && d.len != 0) || d.len > oldScore+8 {
+				break
+			}
+			if d.scan+d.lastOffset < len(d.old) && d.old[d.scan+d.lastOffset] == d.new[d.scan] {
+				oldScore--
+			}
+		}
+		if d.len == oldScore && d.scan != len(d.new) {
+			continue
+		}
+
+		// Extend the previous match forward
+		s, sf, lenf := 0, 0, 0
+		for i := 0; d.lastScan+i < d.scan && d.lastPos+i < len(d.old); {
+			if d.old[d.lastPos+i] == d.new[d.lastScan+i] {
+				s++
+			}
+			i++
+			if s*2-i > sf*2-lenf {
+				sf, lenf = s, i
+			}
+		}
+
+		// Extend the current match backward
+		lenb := 0
+		if d.scan < len(d.new) {
+			s, sb := 0, 0
+			for i := 1; d.scan >= d.lastScan+i && d.pos >= i; i++ {
+				if d.old[d.pos-i] == d.new[d.scan-i] {
+					s++
+				}
+				if s*2-i > sb*2-lenb {
+					sb, lenb = s, i
+				}
+			}
+		}
+
+		// Split overlapping extensions
+		if d.lastScan+lenf > d.scan-lenb {
+			overlap := (d.lastScan + lenf) - (d.scan - lenb)
+			s, ss, lens := 0, 0, 0
+			for i := 0; i < overlap; i++ {
+				if d.new[d.lastScan+lenf-overlap+i] == d.old[d.lastPos+lenf-overlap+i] {
+					s++
+				}
+				if d.new[d.scan-lenb+i] == d.old[d.pos-lenb+i] {
+					s--
+				}
+				if s > ss {
+					ss, lens = s, i+1
+				}
+			}
+			lenf += lens - overlap
+			lenb -= lens
+		}
+
+		// Compute triple
+		d.diff = make([]byte, lenf)
+		for i := range d.diff {
+			d.diff[i] = d.new[d.lastScan+i] - d.old[d.lastPos+i]
+		}
+		d.extra = d.new[d.lastScan+lenf : d.scan-lenb]
+		d.seek = (d.pos - lenb) - (d.lastPos + lenf)
+		d.lastScan = d.scan - lenb
+		d.lastPos = d.pos - lenb
+		d.lastOffset = d.pos - d.scan
+		return true
+	}
+	return false
+}
+
+// search returns the position and the length of the longest match of new among the suffixes of old
+// sorted in i between st and en
+func search(i []int32, old, new []byte, st, en int) (pos, n int) {
+	for en-st >= 2 {
+		x := st + (en-st)/2
+		if bytes.Compare(old[i[x]:min(len(old), int(i[x])+len(new))], new[:min(len(new), len(old)-int(i[x]))]) < 0 {
+			st = x
+		} else {
+			en = x
+		}
+	}
+	x, y := matchLen(old[i[st]:], new), matchLen(old[i[en]:], new)
+	if x > y {
+		return int(i[st]), x
+	}
+	return int(i[en]), y
+}
+
+func matchLen(a, b []byte) (i int) {
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// qsufsort returns the suffix array of b using Larsson and Sadakane's algorithm. The empty suffix comes
+// first.
+func qsufsort(b []byte) []int32 {
+	// Bucket suffixes by their first byte
+	var buckets [256]int32
+	for _, c := range b {
+		buckets[c]++
+	}
+	for i := 1; i < 256; i++ {
+		buckets[i] += buckets[i-1]
+	}
+	copy(buckets[1:], buckets[:255])
+	buckets[0] = 0
+
+	// Initialize suffixes and groups
+	n := int32(len(b))
+	i := make([]int32, n+1)
+	v := make([]int32, n+1)
+	for idx, c := range b {
+		buckets[c]++
+		i[buckets[c]] = int32(idx)
+	}
+	i[0] = n
+	for idx, c := range b {
+		v[idx] = buckets[c]
+	}
+	v[n] = 0
+	for idx := 1; idx < 256; idx++ {
+		if buckets[idx] == buckets[idx-1]+1 {
+			i[buckets[idx]] = -1
+		}
+	}
+	i[0] = -1
+
+	// Sort groups by doubling the number of compared bytes until every group is a singleton
+	for h := int32(1); i[0] != -(n + 1); h += h {
+		l := int32(0)
+		idx := int32(0)
+		for idx < n+1 {
+			if i[idx] < 0 {
+				l -= i[idx]
+				idx -= i[idx]
+			} else {
+				if l != 0 {
+					i[idx-l] = -l
+				}
+				l = v[i[idx]] + 1 - idx
+				split(i, v, idx, l, h)
+				idx += l
+				l = 0
+			}
+		}
+		if l != 0 {
+			i[idx-l] = -l
+		}
+	}
+
+	// Invert
+	for idx := int32(0); idx < n+1; idx++ {
+		i[v[idx]] = idx
+	}
+	return i
+}
+
+// split sorts the group of length l starting at start based on the group of the suffixes h bytes further
+func split(i, v []int32, start, l, h int32) {
+	// Small groups are selection sorted
+	if l < 16 {
+		var j int32
+		for k := start; k < start+l; k += j {
+			j = 1
+			x := v[i[k]+h]
+			for idx := int32(1); k+idx < start+l; idx++ {
+				if v[i[k+idx]+h] < x {
+					x = v[i[k+idx]+h]
+					j = 0
+				}
+				if v[i[k+idx]+h] == x {
+					i[k+j], i[k+idx] = i[k+idx], i[k+j]
+					j++
+				}
+			}
+			for idx := int32(0); idx < j; idx++ {
+				v[i[k+idx]] = k + j - 1
+			}
+			if j == 1 {
+				i[k] = -1
+			}
+		}
+		return
+	}
+
+	// Partition around the middle suffix
+	x := v[i[start+l/2]+h]
+	var jj, kk int32
+	for idx := start; idx < start+l; idx++ {
+		if v[i[idx]+h] < x {
+			jj++
+		}
+		if v[i[idx]+h] == x {
+			kk++
+		}
+	}
+	jj += start
+	kk += jj
+
+	idx, j, k := start, int32(0), int32(0)
+	for idx < jj {
+		if v[i[idx]+h] < x {
+			idx++
+		} else if v[i[idx]+h] == x {
+			i[idx], i[jj+j] = i[jj+j], i[idx]
+			j++
+		} else {
+			i[idx], i[kk+k] = i[kk+k], i[idx]
+			k++
+		}
+	}
+	for jj+j < kk {
+		if v[i[jj+j]+h] == x {
+			j++
+		} else {
+			i[jj+j], i[kk+k] = i[kk+k], i[jj+j]
+			k++
+		}
+	}
+
+	// Recurse
+	if jj > start {
+		split(i, v, start, jj-start, h)
+	}
+	for idx := int32(0); idx < kk-jj; idx++ {
+		v[i[jj+idx]] = kk - 1
+	}
+	if jj == kk-1 {
+		i[jj] = -1
+	}
+	if start+l > kk {
+		split(i, v, kk, start+l-kk, h)
+	}
+}
@@ -0,0 +1,138 @@
+package delta
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// IndexFileName is the name of the index written next to patches
+const IndexFileName = "index.json"
+
+// Index lists what turns the files of a release into the files of another release: patches of files
+// that have changed, files that are copied as is and files that are removed. Files that are not listed
+// are unchanged.
+type Index struct {
+	// Files of the current release that are new or whose patch would not be smaller than the file
+	Added       []IndexAddition `json:"added"`
+	FromVersion string          `json:"from_version"`
+	Patches     []IndexPatch    `json:"patches"`
+	// Files of the previous release that are not part of the current release
+	Removed   []IndexRemoval `json:"removed"`
+	ToVersion string         `json:"to_version"`
+}
+
+// IndexAddition represents a file of an environment that is copied as is
+type IndexAddition struct {
+	Arch string `json:"arch"`
+	// Copy of the file, whose path is relative to the index directory
+	Copy IndexFile `json:"copy"`
+	OS   string    `json:"os"`
+	// File of the current release, whose path is relative to the environment output directory
+	To IndexFile `json:"to"`
+}
+
+// IndexRemoval represents a file of an environment that is removed
+type IndexRemoval struct {
+	Arch string `json:"arch"`
+	// File of the previous release, whose path is relative to the environment output directory
+	From IndexFile `json:"from"`
+	OS   string    `json:"os"`
+}
+
+// IndexPatch represents the patch of a file of an environment
+type IndexPatch struct {
+	Arch string `json:"arch"`
+	// File of the previous release, whose path is relative to the environment output directory
+	From IndexFile `json:"from"`
+	OS   string    `json:"os"`
+	// Patch file, whose path is relative to the index directory
+	Patch IndexFile `json:"patch"`
+	// File of the current release, whose path is relative to the environment output directory
+	To IndexFile `json:"to"`
+}
+
+// IndexFile represents a file of an index
+type IndexFile struct {
+	// Permissions of files of the current release, such as the executable bit of binaries
+	Mode   os.FileMode `json:"mode,omitempty"`
+	Path   string      `json:"path"`
+	SHA256 string      `json:"sha256"`
+	Size   int64       `json:"size"`
+}
+
+// NewIndexFile creates an index file based on its content
+func NewIndexFile(path string, b []byte) IndexFile {
+	h := sha256.Sum256(b)
+	return IndexFile{
+		Path:   path,
+		SHA256: hex.EncodeToString(h[:]),
+		Size:   int64(len(b)),
+	}
+}
+
+// WithMode returns the file with its permissions
+func (f IndexFile) WithMode(m os.FileMode) IndexFile {
+	f.Mode = m
+	return f
+}
+
+// WriteFile writes content to a path and restores the permissions of the file, which default to 0644
+func (f IndexFile) WriteFile(path string, b []byte) (err error) {
+	m := f.Mode
+	if m == 0 {
+		m = 0644
+	}
+	if err = ioutil.WriteFile(path, b, m); err != nil {
+		err = fmt.Errorf("delta: writing %s failed: %w", path, err)
+		return
+	}
+	if err = os.Chmod(path, m); err != nil {
+		err = fmt.Errorf("delta: chmoding %s failed: %w", path, err)
+		return
+	}
+	return
+}
+
+// verify checks that content matches the file
+func (f IndexFile) verify(b []byte) error {
+	if g := NewIndexFile(f.Path, b); g.Size != f.Size || g.SHA256 != f.SHA256 {
+		return fmt.Errorf("delta: %s sha256 %s doesn't match %s", f.Path, g.SHA256, f.SHA256)
+	}
+	return nil
+}
+
+// Apply applies the patch to the old file after having checked both of them and checks the new file
+func (p IndexPatch) Apply(old, patch []byte) (new []byte, err error) {
+	// Check inputs
+	if err = p.From.verify(old); err != nil {
+		return
+	}
+	if err = p.Patch.verify(patch); err != nil {
+		return
+	}
+
+	// Apply
+	if new, err = Apply(old, bytes.NewReader(patch)); err != nil {
+		return
+	}
+
+	// Check output
+	if err = p.To.verify(new); err != nil {
+		new = nil
+		return
+	}
+	return
+}
+
+// Apply checks the copy and returns it as the new file
+func (a IndexAddition) Apply(copy []byte) (new []byte, err error) {
+	if err = a.Copy.verify(copy); err != nil {
+		return
+	}
+	new = copy
+	return
+}
@@ -0,0 +1,293 @@
+package astibundler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"text/template"
+)
+
+// BindDevData generates a bind file whose assets are read from disk at runtime instead of being
+// embedded: resources are read from the resources folder and vendor zip files from the cache. The app
+// therefore picks up resources changes without data being bound again, resources include and exclude
+// patterns being applied at runtime. It must not be used to bundle production apps. Resources packed in
+// an ASAR archive are not supported since they're read from disk.
+func (b *Bundler) BindDevData(os, arch string) (err error) {
+	// Resources are read from the resources folder as is
+	if b.resourcesASAR {
+		err = errors.New("binding data in dev mode is not supported when resources_asar is true")
+		return
+	}
+
+	// Cache the vendor
+	if err = b.cacheVendor(os, arch); err != nil {
+		err = fmt.Errorf("caching the vendor failed: %w", err)
+		return
+	}
+
+	// Get vendor name
+	var vendorName string
+	if vendorName, err = filepath.Rel(b.pathBindInput, b.pathVendor); err != nil {
+		err = fmt.Errorf("getting relative path of %s failed: %w", b.pathVendor, err)
+		return
+	}
+	vendorName = filepath.ToSlash(vendorName)
+
+	// Execute template
+	buf := &bytes.Buffer{}
+	if err = devBindTemplate.Execute(buf, devBindData{
+		Arch: arch,
+		Dirs: map[string]string{
+			path.Clean(filepath.ToSlash(b.pathResources)): filepath.Join(b.pathInput, b.pathResources),
+		},
+		Files: map[string]string{
+			path.Join(vendorName, zipNameAstilectron): b.astilectronCachePath(),
+			path.Join(vendorName, zipNameElectron):    b.electronCachePath(os, arch),
+		},
+		Exclude: newDevBindPatterns(b.resourcesFilter.exclude),
+		Include: newDevBindPatterns(b.resourcesFilter.include),
+		OS:      os,
+		Package: b.bindPackage,
+	}); err != nil {
+		err = fmt.Errorf("executing template failed: %w", err)
+		return
+	}
+
+	// Format
+	var src []byte
+	if src, err = format.Source(buf.Bytes()); err != nil {
+		err = fmt.Errorf("formatting source failed: %w", err)
+		return
+	}
+
+	// Write
+	p := b.bindOutputPath(os, arch)
+	b.l.Debugf("Generating %s", p)
+	if err = ioutil.WriteFile(p, src, 0644); err != nil {
+		err = fmt.Errorf("writing %s failed: %w", p, err)
+		return
+	}
+
+	// Resources are not hashed in dev mode but the manifest is still generated so that the app compiles
+	if len(b.resourcesHash) > 0 {
+		if err = b.writeResourcesManifest(os, arch, nil); err != nil {
+			err = fmt.Errorf("writing resources manifest failed: %w", err)
+			return
+		}
+	}
+	return
+}
+
+type devBindData struct {
+	Arch string
+	// Dirs paths indexed by asset name prefix
+	Dirs map[string]string
+	// Resources exclude patterns
+	Exclude []devBindPattern
+	// Files paths indexed by asset name
+	Files map[string]string
+	// Resources include patterns
+	Include []devBindPattern
+	OS      string
+	Package string
+}
+
+// devBindPattern is the exported version of a path pattern used by the template
+type devBindPattern struct {
+	DirOnly  bool
+	Negated  bool
+	Segments []string
+}
+
+func newDevBindPatterns(ps pathPatterns) (o []devBindPattern) {
+	for _, p := range ps {
+		o = append(o, devBindPattern{
+			DirOnly:  p.dirOnly,
+			Negated:  p.negated,
+			Segments: p.segments,
+		})
+	}
+	return
+}
+
+var devBindTemplate = template.Must(template.New("bind").Parse(`// Code generated by astilectron-bundler in dev mode.
+// Assets are read from disk at runtime and are not embedded.
+// DO NOT EDIT!
+
+//go:build {{ .OS }} && {{ .Arch }}
+// +build {{ .OS }},{{ .Arch }}
+
+package {{ .Package }}
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// bindDevFiles are the paths of files indexed by asset name
+var bindDevFiles = map[string]string{
+{{- range $k, $v := .Files }}
+	{{ printf "%q" $k }}: {{ printf "%q" $v }},
+{{- end }}
+}
+
+// bindDevDirs are the paths of dirs indexed by asset name prefix
+var bindDevDirs = map[string]string{
+{{- range $k, $v := .Dirs }}
+	{{ printf "%q" $k }}: {{ printf "%q" $v }},
+{{- end }}
+}
+
+// bindDevPattern is a gitignore-style pattern whose segments are matched with path.Match, "**" matching
+// any number of segments
+type bindDevPattern struct {
+	dirOnly  bool
+	negated  bool
+	segments []string
+}
+
+// bindDevExclude and bindDevInclude are the resources exclude and include patterns
+var bindDevExclude = []bindDevPattern{
+{{- range .Exclude }}
+	{dirOnly: {{ .DirOnly }}, negated: {{ .Negated }}, segments: {{ printf "%#v" .Segments }}},
+{{- end }}
+}
+
+var bindDevInclude = []bindDevPattern{
+{{- range .Include }}
+	{dirOnly: {{ .DirOnly }}, negated: {{ .Negated }}, segments: {{ printf "%#v" .Segments }}},
+{{- end }}
+}
+
+// bindDevKeep checks whether a slash separated path relative to the resources folder is bound
+func bindDevKeep(name string, isDir bool) bool {
+	if bindDevMatch(bindDevExclude, name, isDir) {
+		return false
+	}
+	return isDir || len(bindDevInclude) == 0 || bindDevMatch(bindDevInclude, name, false)
+}
+
+// bindDevMatch checks whether a path, or one of its parent dirs, matches the patterns, the last matching
+// pattern winning
+func bindDevMatch(ps []bindDevPattern, name string, isDir bool) bool {
+	ss := strings.Split(name, "/")
+	for idx := 1; idx <= len(ss); idx++ {
+		m := false
+		for _, p := range ps {
+			if (!p.dirOnly || idx < len(ss) || isDir) && bindDevMatchSegments(p.segments, ss[:idx]) {
+				m = !p.negated
+			}
+		}
+		if m {
+			return true
+		}
+	}
+	return false
+}
+
+func bindDevMatchSegments(ps, ns []string) bool {
+	for len(ps) > 0 {
+		if ps[0] == "**" {
+			for idx := 0; idx <= len(ns); idx++ {
+				if bindDevMatchSegments(ps[1:], ns[idx:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(ns) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(ps[0], ns[0]); !ok {
+			return false
+		}
+		ps, ns = ps[1:], ns[1:]
+	}
+	return len(ns) == 0
+}
+
+// bindDevPath returns the path of an asset on disk. Names are cleaned as if they were absolute so that
+// they can't point outside of the dirs.
+func bindDevPath(name string) (string, error) {
+	cannonicalName := path.Clean("/" + strings.Replace(name, "\\", "/", -1))[1:]
+	if p, ok := bindDevFiles[cannonicalName]; ok {
+		return p, nil
+	}
+	for prefix, dir := range bindDevDirs {
+		if rel := strings.TrimPrefix(cannonicalName, prefix+"/"); rel != cannonicalName && bindDevKeep(rel, false) {
+			return filepath.Join(dir, filepath.FromSlash(rel)), nil
+		}
+	}
+	return "", fmt.Errorf("Asset %s not found", name)
+}
+
+// Asset loads and returns the asset for the given name.
+// It returns an error if the asset could not be found or
+// could not be loaded.
+func Asset(name string) ([]byte, error) {
+	p, err := bindDevPath(name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading asset %s at %s: %v", name, p, err)
+	}
+	return b, nil
+}
+
+// AssetInfo loads and returns the asset info for the given name.
+// It returns an error if the asset could not be found or
+// could not be loaded.
+func AssetInfo(name string) (os.FileInfo, error) {
+	p, err := bindDevPath(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading asset info %s at %s: %v", name, p, err)
+	}
+	return fi, nil
+}
+
+// AssetNames returns the names of the assets.
+func AssetNames() []string {
+	var names []string
+	for name := range bindDevFiles {
+		names = append(names, name)
+	}
+	for prefix, dir := range bindDevDirs {
+		filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+			if err != nil || p == dir {
+				return nil
+			}
+			rp, err := filepath.Rel(dir, p)
+			if err != nil {
+				return nil
+			}
+			if !bindDevKeep(filepath.ToSlash(rp), fi.IsDir()) {
+				if fi.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if fi.Mode().IsRegular() {
+				names = append(names, prefix+"/"+filepath.ToSlash(rp))
+			}
+			return nil
+		})
+	}
+	sort.Strings(names)
+	return names
+}
+` + bindCommonSource))
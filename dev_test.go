@@ -0,0 +1,74 @@
+package astibundler
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindDevData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	b, err := New(&Configuration{
+		InputPath:            filepath.Join(dir, "app"),
+		ResourcesExclude:     []string{"*.map", "private/"},
+		WorkingDirectoryPath: filepath.Join(dir, "wd"),
+	}, log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, filepath.Join("wd", "cache", filepath.Base(b.astilectronCachePath())), "astilectron")
+	writeTestFile(t, dir, filepath.Join("wd", "cache", filepath.Base(b.electronCachePath(runtime.GOOS, runtime.GOARCH))), "electron")
+	writeTestFile(t, dir, "app/go.mod", "module app\n\ngo 1.13\n")
+	writeTestFile(t, dir, "app/resources/app/index.html", "v1")
+	writeTestFile(t, dir, "app/resources/private/secret.txt", "secret")
+	writeTestFile(t, dir, "app/main.go", `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(AssetNames())
+	fmt.Println(AssetDir("resources"))
+	fmt.Println(string(MustAsset("resources/app/index.html")), string(MustAsset("vendor_astilectron_bundler/electron.zip")))
+	for _, n := range []string{"resources/../go.mod", "resources/app/../../main.go", "resources/app/index.js.map", "resources/private/secret.txt"} {
+		_, err := Asset(n)
+		fmt.Println(err)
+	}
+	fmt.Println(string(MustAsset("/resources/./app/index.html")))
+}
+`)
+
+	err = b.BindDevData(runtime.GOOS, runtime.GOARCH)
+	assert.NoError(t, err)
+	src, err := ioutil.ReadFile(b.bindOutputPath(runtime.GOOS, runtime.GOARCH))
+	assert.NoError(t, err)
+	assert.Contains(t, string(src), "//go:build "+runtime.GOOS+" && "+runtime.GOARCH+"\n// +build "+runtime.GOOS+","+runtime.GOARCH+"\n")
+
+	// Resources changes are picked up without binding data again
+	writeTestFile(t, dir, "app/resources/app/index.html", "v2")
+	writeTestFile(t, dir, "app/resources/app/main.js", "")
+	writeTestFile(t, dir, "app/resources/app/index.js.map", "")
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = filepath.Join(dir, "app")
+	o, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(o))
+	assert.Equal(t, "[resources/app/index.html resources/app/main.js vendor_astilectron_bundler/astilectron.zip vendor_astilectron_bundler/electron.zip]\n[app] <nil>\nv2 electron\nAsset resources/../go.mod not found\nAsset resources/app/../../main.go not found\nAsset resources/app/index.js.map not found\nAsset resources/private/secret.txt not found\nv2\n", string(o))
+}
+
+func TestBindDevDataResourcesASAR(t *testing.T) {
+	b, err := New(&Configuration{ResourcesASAR: true}, log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualError(t, b.BindDevData(runtime.GOOS, runtime.GOARCH), "binding data in dev mode is not supported when resources_asar is true")
+}
@@ -0,0 +1,297 @@
+package astibundler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/asticode/go-astikit"
+	"github.com/asticode/go-astilectron-bundler/delta"
+)
+
+// DiffOptions represents the options of Diff
+type DiffOptions struct {
+	// The output path of the previous release
+	FromPath string
+	// The version of the previous release
+	// Defaults to the version of the update feeds of the previous release
+	FromVersion string
+	// The path where patches and their index are written
+	// Defaults to "<to path>/patches/<from version>"
+	OutputPath string
+	// The output path of the current release
+	ToPath string
+	// The version of the current release
+	// Defaults to the version of the update feeds of the current release
+	ToVersion string
+}
+
+// Diff writes a binary patch for every file that has changed between the environment output directories
+// of two releases, as well as an index listing them with their hashes. New files and files whose patch
+// would not be smaller than the file are copied as is, and removed files are listed, so that the current
+// release can be rebuilt from the previous one. Patches are applied with the delta package.
+func Diff(o DiffOptions, l astikit.StdLogger) (i delta.Index, err error) {
+	// Versions
+	sl := astikit.AdaptStdLogger(l)
+	i.FromVersion, i.ToVersion = o.FromVersion, o.ToVersion
+	if len(i.FromVersion) == 0 {
+		if i.FromVersion, err = releaseFeedVersion(o.FromPath); err != nil {
+			err = fmt.Errorf("getting version of %s failed: %w", o.FromPath, err)
+			return
+		}
+	}
+	if len(i.ToVersion) == 0 {
+		if i.ToVersion, err = releaseFeedVersion(o.ToPath); err != nil {
+			err = fmt.Errorf("getting version of %s failed: %w", o.ToPath, err)
+			return
+		}
+	}
+
+	// Output path
+	i.Added, i.Patches, i.Removed = []delta.IndexAddition{}, []delta.IndexPatch{}, []delta.IndexRemoval{}
+	if len(o.OutputPath) == 0 {
+		o.OutputPath = filepath.Join(o.ToPath, "patches", i.FromVersion)
+	}
+
+	// List environments
+	var es []ConfigurationEnvironment
+	if es, err = listOutputEnvironments(o.ToPath); err != nil {
+		err = fmt.Errorf("listing environments of %s failed: %w", o.ToPath, err)
+		return
+	}
+
+	// Loop through environments
+	var patchSize, toSize int64
+	for _, e := range es {
+		// Environment is not part of the previous release
+		name := e.OS + "-" + e.Arch
+		if _, errStat := os.Stat(filepath.Join(o.FromPath, name)); errStat != nil {
+			sl.Debugf("Environment %s/%s is not part of %s, skipping", e.OS, e.Arch, o.FromPath)
+			continue
+		}
+
+		// Diff
+		var ei delta.Index
+		if ei, err = diffEnvironment(e, i.FromVersion, i.ToVersion, filepath.Join(o.FromPath, name), filepath.Join(o.ToPath, name), o.OutputPath, sl); err != nil {
+			err = fmt.Errorf("diffing environment %s/%s failed: %w", e.OS, e.Arch, err)
+			return
+		}
+		for _, a := range ei.Added {
+			patchSize += a.Copy.Size
+			toSize += a.To.Size
+		}
+		for _, p := range ei.Patches {
+			patchSize += p.Patch.Size
+			toSize += p.To.Size
+		}
+		i.Added = append(i.Added, ei.Added...)
+		i.Patches = append(i.Patches, ei.Patches...)
+		i.Removed = append(i.Removed, ei.Removed...)
+	}
+
+	// Marshal index
+	var b []byte
+	if b, err = json.MarshalIndent(i, "", "  "); err != nil {
+		err = fmt.Errorf("marshaling index failed: %w", err)
+		return
+	}
+
+	// Write index
+	if err = os.MkdirAll(o.OutputPath, 0755); err != nil {
+		err = fmt.Errorf("mkdirall %s failed: %w", o.OutputPath, err)
+		return
+	}
+	p := filepath.Join(o.OutputPath, delta.IndexFileName)
+	sl.Debugf("Writing patches index to %s", p)
+	if err = ioutil.WriteFile(p, append(b, '\n'), 0644); err != nil {
+		err = fmt.Errorf("writing %s failed: %w", p, err)
+		return
+	}
+	sl.Infof("%d patches, %d added files and %d removed files from %s to %s totaling %d bytes instead of %d bytes written to %s", len(i.Patches), len(i.Added), len(i.Removed), i.FromVersion, i.ToVersion, patchSize, toSize, o.OutputPath)
+	return
+}
+
+// diffEnvironment writes the patches of the files of an environment output directory that have changed
+// and the copies of the files that can't be patched, and lists the files that have been removed. The
+// versioned darwin release zip is skipped since its files are diffed individually.
+func diffEnvironment(e ConfigurationEnvironment, fromVersion, toVersion, fromPath, toPath, outputPath string, l astikit.SeverityLogger) (i delta.Index, err error) {
+	// List files
+	var fs []string
+	if fs, err = listFiles(toPath, func(p string, fi os.FileInfo) bool { return true }); err != nil {
+		err = fmt.Errorf("listing files of %s failed: %w", toPath, err)
+		return
+	}
+
+	// Loop through files
+	for _, f := range fs {
+		// Release zip
+		rp, _ := filepath.Rel(toPath, f)
+		if isDarwinReleaseZip(e, toVersion, rp) {
+			continue
+		}
+
+		// Stat current file
+		var fi os.FileInfo
+		if fi, err = os.Stat(f); err != nil {
+			err = fmt.Errorf("stating %s failed: %w", f, err)
+			return
+		}
+		mode := fi.Mode().Perm()
+
+		// Read current file
+		var to []byte
+		if to, err = ioutil.ReadFile(f); err != nil {
+			err = fmt.Errorf("reading %s failed: %w", f, err)
+			return
+		}
+
+		// Stat previous file
+		var fromFi os.FileInfo
+		if fromFi, err = os.Stat(filepath.Join(fromPath, rp)); err != nil && !os.IsNotExist(err) {
+			err = fmt.Errorf("stating %s failed: %w", filepath.Join(fromPath, rp), err)
+			return
+		}
+
+		// File is new
+		if err != nil {
+			err = nil
+			l.Debugf("%s is new", f)
+			var a delta.IndexAddition
+			if a, err = writeDiffCopy(e, rp, to, mode, outputPath); err != nil {
+				err = fmt.Errorf("writing copy of %s failed: %w", f, err)
+				return
+			}
+			i.Added = append(i.Added, a)
+			continue
+		}
+
+		// Read previous file
+		var from []byte
+		if from, err = ioutil.ReadFile(filepath.Join(fromPath, rp)); err != nil {
+			err = fmt.Errorf("reading %s failed: %w", filepath.Join(fromPath, rp), err)
+			return
+		}
+
+		// File has not changed
+		if bytes.Equal(from, to) {
+			// Only its mode has changed
+			if fromFi.Mode().Perm() != mode {
+				l.Debugf("Mode of %s has changed, copying it", f)
+				var a delta.IndexAddition
+				if a, err = writeDiffCopy(e, rp, to, mode, outputPath); err != nil {
+					err = fmt.Errorf("writing copy of %s failed: %w", f, err)
+					return
+				}
+				i.Added = append(i.Added, a)
+			}
+			continue
+		}
+
+		// Diff
+		l.Debugf("Diffing %s", f)
+		var patch []byte
+		if patch, err = delta.Diff(from, to); err != nil {
+			err = fmt.Errorf("diffing %s failed: %w", f, err)
+			return
+		}
+
+		// Patch is useless
+		if len(patch) >= len(to) {
+			l.Debugf("Patch of %s is not smaller than the file, copying it instead", f)
+			var a delta.IndexAddition
+			if a, err = writeDiffCopy(e, rp, to, mode, outputPath); err != nil {
+				err = fmt.Errorf("writing copy of %s failed: %w", f, err)
+				return
+			}
+			i.Added = append(i.Added, a)
+			continue
+		}
+
+		// Write patch
+		patchPath := filepath.Join(e.OS+"-"+e.Arch, rp+".patch")
+		if err = writeDiffFile(filepath.Join(outputPath, patchPath), patch); err != nil {
+			err = fmt.Errorf("writing patch of %s failed: %w", f, err)
+			return
+		}
+
+		// Add to index
+		i.Patches = append(i.Patches, delta.IndexPatch{
+			Arch:  e.Arch,
+			From:  delta.NewIndexFile(filepath.ToSlash(rp), from),
+			OS:    e.OS,
+			Patch: delta.NewIndexFile(filepath.ToSlash(patchPath), patch),
+			To:    delta.NewIndexFile(filepath.ToSlash(rp), to).WithMode(mode),
+		})
+	}
+
+	// List previous files
+	if fs, err = listFiles(fromPath, func(p string, fi os.FileInfo) bool { return true }); err != nil {
+		err = fmt.Errorf("listing files of %s failed: %w", fromPath, err)
+		return
+	}
+
+	// Loop through previous files
+	for _, f := range fs {
+		// File still exists
+		rp, _ := filepath.Rel(fromPath, f)
+		if isDarwinReleaseZip(e, fromVersion, rp) {
+			continue
+		}
+		if _, errStat := os.Stat(filepath.Join(toPath, rp)); errStat == nil {
+			continue
+		}
+
+		// Read
+		var from []byte
+		if from, err = ioutil.ReadFile(f); err != nil {
+			err = fmt.Errorf("reading %s failed: %w", f, err)
+			return
+		}
+
+		// Add to index
+		l.Debugf("%s has been removed", f)
+		i.Removed = append(i.Removed, delta.IndexRemoval{
+			Arch: e.Arch,
+			From: delta.NewIndexFile(filepath.ToSlash(rp), from),
+			OS:   e.OS,
+		})
+	}
+	return
+}
+
+// writeDiffCopy writes the copy of a file that can't be patched
+func writeDiffCopy(e ConfigurationEnvironment, rp string, to []byte, mode os.FileMode, outputPath string) (a delta.IndexAddition, err error) {
+	copyPath := filepath.Join(e.OS+"-"+e.Arch, rp)
+	if err = writeDiffFile(filepath.Join(outputPath, copyPath), to); err != nil {
+		return
+	}
+	a = delta.IndexAddition{
+		Arch: e.Arch,
+		Copy: delta.NewIndexFile(filepath.ToSlash(copyPath), to),
+		OS:   e.OS,
+		To:   delta.NewIndexFile(filepath.ToSlash(rp), to).WithMode(mode),
+	}
+	return
+}
+
+// isDarwinReleaseZip checks whether a path relative to an environment output directory is the darwin
+// release zip of a version
+func isDarwinReleaseZip(e ConfigurationEnvironment, version, rp string) bool {
+	return e.OS == "darwin" && !strings.ContainsRune(rp, filepath.Separator) && strings.HasSuffix(rp, fmt.Sprintf("-%s-%s-%s.zip", version, e.OS, e.Arch))
+}
+
+func writeDiffFile(p string, b []byte) (err error) {
+	if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		err = fmt.Errorf("mkdirall %s failed: %w", filepath.Dir(p), err)
+		return
+	}
+	if err = ioutil.WriteFile(p, b, 0644); err != nil {
+		err = fmt.Errorf("writing %s failed: %w", p, err)
+		return
+	}
+	return
+}
@@ -0,0 +1,119 @@
+package astibundler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/asticode/go-astilectron-bundler/delta"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Releases
+	r := rand.New(rand.NewSource(1))
+	from := make([]byte, 1<<14)
+	r.Read(from)
+	to := append(append([]byte{}, from[:1000]...), from[2000:]...)
+	writeTestFile(t, dir, "from/latest-linux.yml", "version: 1.0.0\n")
+	writeTestFile(t, dir, "from/linux-amd64/App", string(from))
+	writeTestFile(t, dir, "from/linux-amd64/THIRD_PARTY_NOTICES", "notices")
+	writeTestFile(t, dir, "from/linux-amd64/removed.txt", "removed")
+	writeTestFile(t, dir, "to/latest-linux.yml", "version: 1.1.0\n")
+	writeTestFile(t, dir, "to/linux-amd64/App", string(to))
+	writeTestFile(t, dir, "to/linux-amd64/THIRD_PARTY_NOTICES", "notices")
+	writeTestFile(t, dir, "to/linux-amd64/sbom.cdx.json", "sbom")
+	writeTestFile(t, dir, "to/linux-amd64/run.sh", "run")
+	writeTestFile(t, dir, "from/linux-amd64/run.sh", "run")
+	writeTestFile(t, dir, "from/darwin-amd64/App.app/Contents/MacOS/App", string(to))
+	writeTestFile(t, dir, "from/darwin-amd64/App-1.0.0-darwin-amd64.zip", "zip 1.0.0")
+	writeTestFile(t, dir, "to/darwin-amd64/App.app/Contents/MacOS/App", string(to))
+	writeTestFile(t, dir, "to/darwin-amd64/App-1.1.0-darwin-amd64.zip", "zip 1.1.0")
+	for _, p := range []string{"from/linux-amd64/App", "to/linux-amd64/App", "to/linux-amd64/run.sh"} {
+		if err = os.Chmod(filepath.Join(dir, p), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Diff
+	i, err := Diff(DiffOptions{FromPath: filepath.Join(dir, "from"), ToPath: filepath.Join(dir, "to")}, log.New(ioutil.Discard, "", 0))
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.0", i.FromVersion)
+	assert.Equal(t, "1.1.0", i.ToVersion)
+	if assert.Len(t, i.Patches, 1) {
+		p := i.Patches[0]
+		assert.Equal(t, "amd64", p.Arch)
+		assert.Equal(t, "linux", p.OS)
+		assert.Equal(t, delta.NewIndexFile("App", from), p.From)
+		assert.Equal(t, delta.NewIndexFile("App", to).WithMode(0755), p.To)
+		assert.Equal(t, "linux-amd64/App.patch", p.Patch.Path)
+
+		// Apply
+		b, err := ioutil.ReadFile(filepath.Join(dir, "to/patches/1.0.0", p.Patch.Path))
+		assert.NoError(t, err)
+		o, err := p.Apply(from, b)
+		assert.NoError(t, err)
+		assert.Equal(t, to, o)
+		assert.NoError(t, p.To.WriteFile(filepath.Join(dir, "App"), o))
+		fi, err := os.Stat(filepath.Join(dir, "App"))
+		if assert.NoError(t, err) {
+			assert.Equal(t, os.FileMode(0755), fi.Mode().Perm())
+		}
+	}
+
+	// Added
+	if assert.Len(t, i.Added, 2) {
+		assert.Equal(t, delta.IndexAddition{
+			Arch: "amd64",
+			Copy: delta.NewIndexFile("linux-amd64/run.sh", []byte("run")),
+			OS:   "linux",
+			To:   delta.NewIndexFile("run.sh", []byte("run")).WithMode(0755),
+		}, i.Added[0])
+		a := i.Added[1]
+		assert.Equal(t, delta.IndexAddition{
+			Arch: "amd64",
+			Copy: delta.NewIndexFile("linux-amd64/sbom.cdx.json", []byte("sbom")),
+			OS:   "linux",
+			To:   delta.NewIndexFile("sbom.cdx.json", []byte("sbom")).WithMode(0644),
+		}, a)
+		b, err := ioutil.ReadFile(filepath.Join(dir, "to/patches/1.0.0", a.Copy.Path))
+		assert.NoError(t, err)
+		o, err := a.Apply(b)
+		assert.NoError(t, err)
+		assert.Equal(t, "sbom", string(o))
+		_, err = a.Apply([]byte("invalid"))
+		assert.Error(t, err)
+	}
+
+	// Removed
+	assert.Equal(t, []delta.IndexRemoval{{Arch: "amd64", From: delta.NewIndexFile("removed.txt", []byte("removed")), OS: "linux"}}, i.Removed)
+
+	// Index
+	b, err := ioutil.ReadFile(filepath.Join(dir, "to/patches/1.0.0", delta.IndexFileName))
+	assert.NoError(t, err)
+	var ri delta.Index
+	assert.NoError(t, json.Unmarshal(b, &ri))
+	assert.Equal(t, i, ri)
+
+	// Versions
+	_, err = Diff(DiffOptions{FromPath: filepath.Join(dir, "from/linux-amd64"), ToPath: filepath.Join(dir, "to")}, log.New(ioutil.Discard, "", 0))
+	assert.Error(t, err)
+	i, err = Diff(DiffOptions{FromPath: filepath.Join(dir, "from/linux-amd64"), FromVersion: "0.9.0", OutputPath: filepath.Join(dir, "patches"), ToPath: filepath.Join(dir, "to")}, log.New(ioutil.Discard, "", 0))
+	assert.NoError(t, err)
+	assert.Equal(t, "0.9.0", i.FromVersion)
+	assert.Empty(t, i.Added)
+	assert.Empty(t, i.Patches)
+	assert.Empty(t, i.Removed)
+	_, err = os.Stat(filepath.Join(dir, "patches", delta.IndexFileName))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,341 @@
+package astibundler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/asticode/go-astilectron"
+)
+
+// fingerprint represents what an environment has been bundled from and what it has produced
+type fingerprint struct {
+	// Artifacts sha256 indexed by path
+	Artifacts map[string]string `json:"artifacts"`
+	// Sha256 of everything the environment is bundled from
+	Inputs string `json:"inputs"`
+}
+
+// fingerprintPath returns the path where the fingerprint of an environment is stored
+func (b *Bundler) fingerprintPath(e ConfigurationEnvironment) string {
+	h := sha256.Sum256([]byte(b.environmentPath(e)))
+	return filepath.Join(b.pathWorkingDirectory, "fingerprints", hex.EncodeToString(h[:8])+".json")
+}
+
+// isUpToDate checks whether the environment has already been bundled from the same inputs and whether
+// its artifacts are unchanged
+func (b *Bundler) isUpToDate(e ConfigurationEnvironment) (ok bool, err error) {
+	// Read fingerprint
+	var bs []byte
+	p := b.fingerprintPath(e)
+	if bs, err = ioutil.ReadFile(p); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		} else {
+			err = fmt.Errorf("reading %s failed: %w", p, err)
+		}
+		return
+	}
+
+	// Unmarshal
+	var f fingerprint
+	if err = json.Unmarshal(bs, &f); err != nil {
+		// Corrupted fingerprints are ignored
+		b.l.Debugf("unmarshaling %s failed: %s", p, err)
+		err = nil
+		return
+	}
+
+	// Compute inputs
+	var inputs string
+	if inputs, err = b.fingerprintInputs(e); err != nil {
+		err = fmt.Errorf("computing inputs fingerprint failed: %w", err)
+		return
+	}
+
+	// Inputs have changed
+	if inputs != f.Inputs {
+		return
+	}
+
+	// Compute artifacts
+	var artifacts map[string]string
+	if artifacts, err = b.fingerprintArtifacts(e); err != nil {
+		err = fmt.Errorf("computing artifacts fingerprint failed: %w", err)
+		return
+	}
+
+	// Compare artifacts
+	if len(artifacts) != len(f.Artifacts) {
+		return
+	}
+	for k, v := range artifacts {
+		if f.Artifacts[k] != v {
+			return
+		}
+	}
+	ok = true
+	return
+}
+
+// writeFingerprint writes the fingerprint of an environment that has just been bundled
+func (b *Bundler) writeFingerprint(e ConfigurationEnvironment) (err error) {
+	// Compute
+	var f fingerprint
+	if f.Inputs, err = b.fingerprintInputs(e); err != nil {
+		err = fmt.Errorf("computing inputs fingerprint failed: %w", err)
+		return
+	}
+	if f.Artifacts, err = b.fingerprintArtifacts(e); err != nil {
+		err = fmt.Errorf("computing artifacts fingerprint failed: %w", err)
+		return
+	}
+
+	// Marshal
+	var bs []byte
+	if bs, err = json.Marshal(f); err != nil {
+		err = fmt.Errorf("marshaling failed: %w", err)
+		return
+	}
+
+	// Create dir
+	p := b.fingerprintPath(e)
+	if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		err = fmt.Errorf("mkdirall %s failed: %w", filepath.Dir(p), err)
+		return
+	}
+
+	// Write
+	b.l.Debugf("Writing fingerprint to %s", p)
+	if err = ioutil.WriteFile(p, bs, 0644); err != nil {
+		err = fmt.Errorf("writing %s failed: %w", p, err)
+		return
+	}
+	return
+}
+
+// removeFingerprint removes the fingerprint of an environment
+func (b *Bundler) removeFingerprint(e ConfigurationEnvironment) (err error) {
+	p := b.fingerprintPath(e)
+	if err = os.Remove(p); err != nil && !os.IsNotExist(err) {
+		err = fmt.Errorf("removing %s failed: %w", p, err)
+		return
+	}
+	err = nil
+	return
+}
+
+// fingerprintArtifacts returns the sha256 of the environment artifacts indexed by path. Missing
+// artifacts are skipped.
+func (b *Bundler) fingerprintArtifacts(e ConfigurationEnvironment) (m map[string]string, err error) {
+	m = make(map[string]string)
+	for _, p := range b.artifacts(e) {
+		h := sha256.New()
+		if err = hashFile(h, p); err != nil {
+			if os.IsNotExist(err) {
+				err = nil
+				continue
+			}
+			err = fmt.Errorf("hashing %s failed: %w", p, err)
+			return
+		}
+		m[p] = hex.EncodeToString(h.Sum(nil))
+	}
+	return
+}
+
+// fingerprintInputs returns the sha256 of everything the environment is bundled from: go sources, go.mod,
+// go.sum, resources, icons, manifest, configuration, astilectron and electron versions and go
+// toolchain version
+func (b *Bundler) fingerprintInputs(e ConfigurationEnvironment) (s string, err error) {
+	h := sha256.New()
+
+	// Configuration and environment
+	var bs []byte
+	if bs, err = json.Marshal(e); err != nil {
+		err = fmt.Errorf("marshaling environment failed: %w", err)
+		return
+	}
+	fmt.Fprintf(h, "configuration:%s\nenvironment:%s\nastilectron:%s:%s\nelectron:%s\n", b.configurationHash, bs, b.versionAstilectron, b.pathAstilectron, b.versionElectron)
+
+	// Go toolchain version
+	if bs, err = exec.Command(b.pathGoBinary, "version").Output(); err != nil {
+		err = fmt.Errorf("getting go version failed: %w", err)
+		return
+	}
+	fmt.Fprintf(h, "go:%s\n", strings.TrimSpace(string(bs)))
+
+	// Icons and manifest
+	for _, p := range []string{b.pathIconDarwin, b.pathIconLinux, b.pathIconWindows, b.pathManifest} {
+		if len(p) == 0 {
+			continue
+		}
+		fmt.Fprintf(h, "file:%s\n", p)
+		if err = hashFile(h, p); err != nil {
+			err = fmt.Errorf("hashing %s failed: %w", p, err)
+			return
+		}
+	}
+
+	// Resources, following symlinks like when they're copied
+	resourcesPath := filepath.Join(b.pathInput, b.pathResources)
+	if _, errStat := os.Stat(resourcesPath); errStat == nil {
+		var es []resourceEntry
+		if es, err = listResources(resourcesPath, b.resourcesFileFilter()); err != nil {
+			err = fmt.Errorf("listing resources of %s failed: %w", resourcesPath, err)
+			return
+		}
+		for _, e := range es {
+			if e.fi.IsDir() {
+				continue
+			}
+			fmt.Fprintf(h, "file:%s\n", filepath.ToSlash(e.rel))
+			if err = hashFile(h, e.path); err != nil {
+				err = fmt.Errorf("hashing %s failed: %w", e.path, err)
+				return
+			}
+		}
+	}
+
+	// Go sources
+	modulePath := goModulePath(b.pathInput)
+	if err = hashDir(h, modulePath, b.goSourcesFilter(modulePath)); err != nil {
+		err = fmt.Errorf("hashing %s failed: %w", modulePath, err)
+		return
+	}
+
+	s = hex.EncodeToString(h.Sum(nil))
+	return
+}
+
+// goSourcesFilter returns a filter keeping go sources, go.mod and go.sum files of a module while skipping
+// resources, outputs, hidden dirs and generated files
+func (b *Bundler) goSourcesFilter(modulePath string) func(p string, fi os.FileInfo) bool {
+	resourcesPath := filepath.Join(b.pathInput, b.pathResources)
+	return func(p string, fi os.FileInfo) bool {
+		// Skip resources, outputs and hidden dirs
+		if fi.IsDir() {
+			return p == modulePath || (!strings.HasPrefix(fi.Name(), ".") && fi.Name() != "node_modules" &&
+				p != resourcesPath && p != b.pathOutput && p != b.pathWorkingDirectory)
+		}
+
+		// Skip generated files
+		if filepath.Dir(p) == b.pathBindOutput && isGeneratedBindFile(fi.Name()) {
+			return false
+		} else if p == filepath.Join(b.pathInput, "windows.syso") {
+			return false
+		}
+		return filepath.Ext(p) == ".go" || fi.Name() == "go.mod" || fi.Name() == "go.sum"
+	}
+}
+
+// isGeneratedBindFile checks whether a file name is the one of a file generated in the bind output dir:
+// bind_<os>_<arch>.go, bind_manifest_<os>_<arch>.go or bind_asar.go
+func isGeneratedBindFile(name string) bool {
+	// Not a bind file
+	if !strings.HasPrefix(name, "bind_") || !strings.HasSuffix(name, ".go") {
+		return false
+	}
+
+	// ASAR reader
+	if name == "bind_asar.go" {
+		return true
+	}
+
+	// Bind file or resources manifest
+	ss := strings.Split(strings.TrimPrefix(strings.TrimSuffix(name, ".go"), "bind_"), "_")
+	if len(ss) == 3 && ss[0] == "manifest" {
+		ss = ss[1:]
+	}
+	return len(ss) == 2 && astilectron.IsValidOS(ss[0]) && len(ss[1]) > 0
+}
+
+// goModulePath returns the closest dir containing a go.mod file or the input dir if none is found
+func goModulePath(dir string) string {
+	for p := dir; ; {
+		if _, err := os.Stat(filepath.Join(p, "go.mod")); err == nil {
+			return p
+		}
+		pp := filepath.Dir(p)
+		if pp == p {
+			return dir
+		}
+		p = pp
+	}
+}
+
+// hashDir writes the relative path and content of every file of a dir kept by the filter in the hash.
+// Missing dirs are skipped.
+func hashDir(h hash.Hash, dir string, filter func(p string, fi os.FileInfo) bool) (err error) {
+	// List files
+	var ps []string
+	if ps, err = listFiles(dir, filter); err != nil {
+		err = fmt.Errorf("listing files of %s failed: %w", dir, err)
+		return
+	}
+
+	// Hash files
+	for _, p := range ps {
+		rp, _ := filepath.Rel(dir, p)
+		fmt.Fprintf(h, "file:%s\n", filepath.ToSlash(rp))
+		if err = hashFile(h, p); err != nil {
+			err = fmt.Errorf("hashing %s failed: %w", p, err)
+			return
+		}
+	}
+	return
+}
+
+// listFiles returns the sorted paths of every regular file of a dir kept by the filter. Dirs that are
+// not kept are skipped and missing dirs are considered empty.
+func listFiles(dir string, filter func(p string, fi os.FileInfo) bool) (ps []string, err error) {
+	// Walk
+	if err = filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		// Check error
+		if err != nil {
+			if p == dir && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		// Filter
+		if !filter(p, fi) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Only files are listed
+		if fi.Mode().IsRegular() {
+			ps = append(ps, p)
+		}
+		return nil
+	}); err != nil {
+		err = fmt.Errorf("walking through %s failed: %w", dir, err)
+		return
+	}
+	sort.Strings(ps)
+	return
+}
+
+// hashFile writes the content of a file in the hash
+func hashFile(h io.Writer, p string) (err error) {
+	var f *os.File
+	if f, err = os.Open(p); err != nil {
+		return
+	}
+	defer f.Close()
+	_, err = io.Copy(h, f)
+	return
+}
@@ -0,0 +1,103 @@
+package astibundler
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBundleSkipsUpToDateEnvironments(t *testing.T) {
+	for _, v := range []struct {
+		c       Configuration
+		filter  []string
+		name    string
+		skipped []string
+	}{
+		{
+			c: Configuration{Environments: []ConfigurationEnvironment{
+				{Arch: "amd64", OS: "darwin"},
+				{Arch: "amd64", OS: "linux"},
+			}},
+			filter:  []string{"linux/*"},
+			name:    "filtered environments",
+			skipped: []string{"linux/amd64"},
+		},
+		{
+			c: Configuration{
+				Environments: []ConfigurationEnvironment{{Arch: "amd64", OS: "darwin"}},
+				Release:      ConfigurationRelease{BaseURL: "https://example.com", Version: "1.0.0"},
+			},
+			name:    "darwin release",
+			skipped: []string{"darwin/amd64"},
+		},
+	} {
+		t.Run(v.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "astibundler")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			// App
+			writeTestFile(t, dir, "app/go.mod", "module app\n\ngo 1.13\n")
+			writeTestFile(t, dir, "app/main.go", "package main\n\nfunc main() {}\n")
+			writeTestFile(t, dir, "app/resources/app/index.html", "<html>")
+			writeTestFile(t, dir, "wd/cache/astilectron-0.49.0.zip", "astilectron")
+			for _, e := range v.c.Environments {
+				writeTestFile(t, dir, "wd/cache/electron-"+e.OS+"-"+e.Arch+"-11.4.3.zip", "electron")
+			}
+			v.c.AppName = "App"
+			v.c.InputPath = filepath.Join(dir, "app")
+			v.c.OutputPath = filepath.Join(dir, "output")
+			v.c.VersionAstilectron = "0.49.0"
+			v.c.VersionElectron = "11.4.3"
+			v.c.WorkingDirectoryPath = filepath.Join(dir, "wd")
+
+			// Bundle every environment
+			b, err := New(&v.c, log.New(ioutil.Discard, "", 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = b.Bundle(); err != nil {
+				t.Fatal(err)
+			}
+
+			// Bundle again
+			c := v.c
+			if err = c.FilterEnvironments(v.filter); err != nil {
+				t.Fatal(err)
+			}
+			buf := &bytes.Buffer{}
+			if b, err = New(&c, log.New(buf, "", 0)); err != nil {
+				t.Fatal(err)
+			}
+			assert.NoError(t, b.Bundle())
+			for _, e := range v.skipped {
+				assert.Contains(t, buf.String(), "Environment "+e+" is up to date, skipping")
+			}
+		})
+	}
+}
+
+func TestIsGeneratedBindFile(t *testing.T) {
+	for n, e := range map[string]bool{
+		"bind_asar.go":                   true,
+		"bind_darwin_arm64.go":           true,
+		"bind_linux_amd64.go":            true,
+		"bind_manifest_windows_386.go":   true,
+		"bind_helpers.go":                false,
+		"bind_linux_amd64_test.go":       false,
+		"bind_manifest.go":               false,
+		"bind_test.go":                   false,
+		"bind_windows_amd64.go.orig":     false,
+		"main.go":                        false,
+		"bind_manifest_linux_amd64.json": false,
+	} {
+		assert.Equal(t, e, isGeneratedBindFile(n), n)
+	}
+}
@@ -1,12 +1,23 @@
 module github.com/asticode/go-astilectron-bundler
 
-go 1.13
+go 1.17
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/akavel/rsrc v0.8.0
 	github.com/asticode/go-astikit v0.15.0
 	github.com/asticode/go-astilectron v0.25.0
-	github.com/asticode/go-bindata v1.0.0
+	github.com/klauspost/compress v1.11.13
 	github.com/sam-kamerer/go-plister v1.2.0
 	github.com/stretchr/testify v1.4.0
+	github.com/tdewolff/minify/v2 v2.12.0
+	github.com/ulikunitz/xz v0.5.12
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/tdewolff/parse/v2 v2.6.1 // indirect
+	gopkg.in/yaml.v2 v2.2.2 // indirect
 )
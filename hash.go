@@ -0,0 +1,373 @@
+package astibundler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Resources hash lengths
+const (
+	resourcesHashLengthDefault = 6
+	resourcesHashLengthMax     = 64
+	resourcesHashLengthMin     = 4
+)
+
+// Regexps matching references in html and css resources. The reference is the first non empty submatch.
+var (
+	resourcesHashCSSRegexps = []*regexp.Regexp{
+		regexp.MustCompile(`url\(\s*(?:"([^"]*)"|'([^']*)'|([^'"\s\)]+))\s*\)`),
+		regexp.MustCompile(`@import\s+(?:"([^"]*)"|'([^']*)')`),
+	}
+	resourcesHashHTMLRegexps = append([]*regexp.Regexp{
+		regexp.MustCompile(`(?i)\s(?:href|src|poster|data)\s*=\s*(?:"([^"]*)"|'([^']*)')`),
+	}, resourcesHashCSSRegexps...)
+)
+
+// validateResourcesHashLength validates the number of hex characters of resources hashes
+func validateResourcesHashLength(l int) error {
+	if l != 0 && (l < resourcesHashLengthMin || l > resourcesHashLengthMax) {
+		return fmt.Errorf("length %d is not between %d and %d", l, resourcesHashLengthMin, resourcesHashLengthMax)
+	}
+	return nil
+}
+
+// resourcesHasher renames resources to include a hash of their content and rewrites references to them
+type resourcesHasher struct {
+	dir      string
+	done     map[string]bool
+	length   int
+	manifest map[string]string
+	names    map[string]bool
+	patterns pathPatterns
+	// Resources being processed, in processing order
+	stack []string
+}
+
+// hashResources renames the resources of the bind dir matching the hash patterns so that they include a
+// hash of their content, rewrites references to them in html and css resources and returns the manifest
+// mapping logical names to hashed names
+func hashResources(dir string, patterns pathPatterns, length int) (manifest map[string]string, err error) {
+	// List files
+	var ps []string
+	if ps, err = listFiles(dir, func(p string, fi os.FileInfo) bool { return true }); err != nil {
+		err = fmt.Errorf("listing files of %s failed: %w", dir, err)
+		return
+	}
+
+	// Create hasher
+	h := &resourcesHasher{
+		dir:      dir,
+		done:     make(map[string]bool),
+		length:   length,
+		manifest: make(map[string]string),
+		names:    make(map[string]bool),
+		patterns: patterns,
+	}
+	if h.length == 0 {
+		h.length = resourcesHashLengthDefault
+	}
+	for _, p := range ps {
+		rp, _ := filepath.Rel(dir, p)
+		h.names[filepath.ToSlash(rp)] = true
+	}
+
+	// Process files in order so that the output doesn't depend on the walk
+	var names []string
+	for n := range h.names {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		if err = h.process(n); err != nil {
+			err = fmt.Errorf("processing %s failed: %w", n, err)
+			return
+		}
+	}
+	manifest = h.manifest
+	return
+}
+
+// process rewrites references of a resource, after having processed the resources it references, and
+// renames it if it matches the hash patterns. Cyclic references to a hashed resource fail since the
+// hash of each resource would depend on the hash of the other.
+func (h *resourcesHasher) process(name string) (err error) {
+	// Already processed
+	if h.done[name] {
+		return
+	}
+
+	// Being processed, in which case references are cyclic. A resource that is not hashed keeps its name
+	// and can therefore be referenced as is.
+	for idx, n := range h.stack {
+		if n == name {
+			if h.patterns.match(name, false) {
+				err = fmt.Errorf("references are cyclic: %s", strings.Join(append(append([]string{}, h.stack[idx:]...), name), " -> "))
+			}
+			return
+		}
+	}
+	h.stack = append(h.stack, name)
+	defer func() {
+		h.stack = h.stack[:len(h.stack)-1]
+		h.done[name] = true
+	}()
+
+	// Rewrite references
+	p := filepath.Join(h.dir, filepath.FromSlash(name))
+	if rs := resourcesHashRegexps(name); len(rs) > 0 {
+		if err = h.rewrite(name, p, rs); err != nil {
+			err = fmt.Errorf("rewriting references failed: %w", err)
+			return
+		}
+	}
+
+	// Resource is not hashed
+	if !h.patterns.match(name, false) {
+		return
+	}
+
+	// Hash
+	s := sha256.New()
+	if err = hashFile(s, p); err != nil {
+		err = fmt.Errorf("hashing %s failed: %w", p, err)
+		return
+	}
+	hashed := hashedResourceName(name, hex.EncodeToString(s.Sum(nil))[:h.length])
+
+	// Rename
+	if err = os.Rename(p, filepath.Join(h.dir, filepath.FromSlash(hashed))); err != nil {
+		err = fmt.Errorf("renaming %s failed: %w", p, err)
+		return
+	}
+	h.manifest[name] = hashed
+	return
+}
+
+// rewrite replaces references to hashed resources in a resource
+func (h *resourcesHasher) rewrite(name, p string, rs []*regexp.Regexp) (err error) {
+	// Read
+	var b []byte
+	if b, err = ioutil.ReadFile(p); err != nil {
+		err = fmt.Errorf("reading %s failed: %w", p, err)
+		return
+	}
+
+	// Loop through regexps
+	o := b
+	for _, r := range rs {
+		var errReplace error
+		o = r.ReplaceAllFunc(o, func(m []byte) []byte {
+			// Get reference
+			sm := r.FindSubmatchIndex(m)
+			start, end := -1, -1
+			for idx := 2; idx < len(sm); idx += 2 {
+				if sm[idx] >= 0 && sm[idx+1] > sm[idx] {
+					start, end = sm[idx], sm[idx+1]
+					break
+				}
+			}
+			if start < 0 {
+				return m
+			}
+
+			// Resolve reference
+			ref := string(m[start:end])
+			target, rest, ok := h.resolve(name, ref)
+			if !ok {
+				return m
+			}
+
+			// Process target first so that its hashed name is known
+			if err := h.process(target); err != nil {
+				errReplace = fmt.Errorf("processing %s failed: %w", target, err)
+				return m
+			}
+			hashed, ok := h.manifest[target]
+			if !ok {
+				return m
+			}
+
+			// Replace the base name of the reference
+			refPath := strings.TrimSuffix(ref, rest)
+			n := refPath[:strings.LastIndex(refPath, "/")+1] + path.Base(hashed) + rest
+			return append(append(append([]byte{}, m[:start]...), n...), m[end:]...)
+		})
+		if errReplace != nil {
+			err = errReplace
+			return
+		}
+	}
+
+	// Nothing changed
+	if bytes.Equal(o, b) {
+		return
+	}
+
+	// Write
+	if err = ioutil.WriteFile(p, o, 0); err != nil {
+		err = fmt.Errorf("writing %s failed: %w", p, err)
+		return
+	}
+	return
+}
+
+// resolve returns the resource a reference found in a resource points to as well as the query and
+// fragment of the reference
+func (h *resourcesHasher) resolve(name, ref string) (target, rest string, ok bool) {
+	// Skip external references, data URIs and fragments
+	if ref == "" || strings.HasPrefix(ref, "#") || strings.HasPrefix(ref, "//") || strings.Contains(strings.SplitN(ref, "/", 2)[0], ":") {
+		return
+	}
+
+	// Split query and fragment
+	refPath := ref
+	if idx := strings.IndexAny(ref, "?#"); idx >= 0 {
+		refPath, rest = ref[:idx], ref[idx:]
+	}
+	if refPath == "" || strings.HasSuffix(refPath, "/") {
+		return
+	}
+
+	// Resolve path
+	if strings.HasPrefix(refPath, "/") {
+		target = path.Clean(strings.TrimPrefix(refPath, "/"))
+	} else {
+		target = path.Join(path.Dir(name), refPath)
+	}
+	ok = h.names[target]
+	return
+}
+
+// resourcesHashRegexps returns the regexps matching references in a resource based on its extension
+func resourcesHashRegexps(name string) []*regexp.Regexp {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".css":
+		return resourcesHashCSSRegexps
+	case ".htm", ".html":
+		return resourcesHashHTMLRegexps
+	}
+	return nil
+}
+
+// hashedResourceName inserts a hash in a resource name before its extension, eg "app.js" becomes
+// "app.3f2a9c.js"
+func hashedResourceName(name, hash string) string {
+	ext := path.Ext(name)
+	if ext == path.Base(name) {
+		ext = ""
+	}
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// resourcesManifestPath returns the path of the resources manifest generated next to the bind file of an
+// environment
+func (b *Bundler) resourcesManifestPath(os, arch string) string {
+	return filepath.Join(b.pathBindOutput, fmt.Sprintf("bind_manifest_%s_%s.go", os, arch))
+}
+
+// hashBindResources hashes the resources of the bind dir and generates the resources manifest
+func (b *Bundler) hashBindResources(os, arch string) (err error) {
+	// Hash
+	dir := filepath.Join(b.pathBindInput, b.pathResources)
+	b.l.Debugf("Hashing resources of %s", dir)
+	var m map[string]string
+	if m, err = hashResources(dir, b.resourcesHash, b.resourcesHashLength); err != nil {
+		err = fmt.Errorf("hashing resources of %s failed: %w", dir, err)
+		return
+	}
+	b.l.Infof("Hashed %d resources", len(m))
+
+	// Write manifest
+	if err = b.writeResourcesManifest(os, arch, m); err != nil {
+		err = fmt.Errorf("writing resources manifest failed: %w", err)
+		return
+	}
+	return
+}
+
+// writeResourcesManifest generates the resources manifest of an environment in the bind package
+func (b *Bundler) writeResourcesManifest(os, arch string, m map[string]string) (err error) {
+	// Execute template
+	buf := &bytes.Buffer{}
+	if err = resourcesManifestTemplate.Execute(buf, resourcesManifestData{
+		Arch:     arch,
+		Manifest: m,
+		OS:       os,
+		Package:  b.bindPackage,
+	}); err != nil {
+		err = fmt.Errorf("executing template failed: %w", err)
+		return
+	}
+
+	// Format
+	var src []byte
+	if src, err = format.Source(buf.Bytes()); err != nil {
+		err = fmt.Errorf("formatting source failed: %w", err)
+		return
+	}
+
+	// Write
+	p := b.resourcesManifestPath(os, arch)
+	b.l.Debugf("Generating %s", p)
+	if err = ioutil.WriteFile(p, src, 0644); err != nil {
+		err = fmt.Errorf("writing %s failed: %w", p, err)
+		return
+	}
+	return
+}
+
+type resourcesManifestData struct {
+	Arch string
+	// Hashed names indexed by logical names
+	Manifest map[string]string
+	OS       string
+	Package  string
+}
+
+var resourcesManifestTemplate = template.Must(template.New("manifest").Parse(`// Code generated by astilectron-bundler.
+// DO NOT EDIT!
+
+//go:build {{ .OS }} && {{ .Arch }}
+// +build {{ .OS }},{{ .Arch }}
+
+package {{ .Package }}
+
+import "strings"
+
+// bindResourcesManifest are the hashed names of resources indexed by logical names
+var bindResourcesManifest = map[string]string{
+{{- range $k, $v := .Manifest }}
+	{{ printf "%q" $k }}: {{ printf "%q" $v }},
+{{- end }}
+}
+
+// ResourcesManifest returns the hashed names of resources indexed by logical names. Names are relative
+// to the resources folder.
+func ResourcesManifest() map[string]string {
+	m := make(map[string]string, len(bindResourcesManifest))
+	for k, v := range bindResourcesManifest {
+		m[k] = v
+	}
+	return m
+}
+
+// HashedResource returns the hashed name of a resource, or the name itself if the resource is not
+// hashed. Names are relative to the resources folder, eg "app/app.js".
+func HashedResource(name string) string {
+	if h, ok := bindResourcesManifest[strings.Replace(name, "\\", "/", -1)]; ok {
+		return h
+	}
+	return name
+}
+`))
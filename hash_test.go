@@ -0,0 +1,139 @@
+package astibundler
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashedResourceName(t *testing.T) {
+	assert.Equal(t, "app.3f2a9c.js", hashedResourceName("app.js", "3f2a9c"))
+	assert.Equal(t, "lib/app.min.3f2a9c.js", hashedResourceName("lib/app.min.js", "3f2a9c"))
+	assert.Equal(t, "LICENSE.3f2a9c", hashedResourceName("LICENSE", "3f2a9c"))
+	assert.Equal(t, ".env.3f2a9c", hashedResourceName(".env", "3f2a9c"))
+}
+
+func TestHashResources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeTestFile(t, dir, "app/index.html", `<html>
+<link rel="stylesheet" href="css/style.css?v=1">
+<script src='app.js'></script>
+<script src="https://example.com/app.js"></script>
+<a href="#app.js">link</a>
+<img src="/img/logo.png">
+<div style="background: url(../img/logo.png)"></div>
+</html>`)
+	writeTestFile(t, dir, "app/app.js", "console.log(1)")
+	writeTestFile(t, dir, "app/css/style.css", `@import "base.css"; body { background: url("../../img/logo.png#top"); } i { background: url(data:image/png;base64,AAAA) }`)
+	writeTestFile(t, dir, "app/css/base.css", "p { color: red }")
+	writeTestFile(t, dir, "img/logo.png", "png")
+
+	ps, err := newPathPatterns([]string{"*.js", "*.css", "*.png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := hashResources(dir, ps, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"app/app.js":        "app/app.0a2868.js",
+		"app/css/base.css":  "app/css/base.9e07b0.css",
+		"app/css/style.css": "app/css/style.a9656a.css",
+		"img/logo.png":      "img/logo.8f8cbb.png",
+	}, m)
+	for p, e := range map[string]string{
+		"app/index.html": `<html>
+<link rel="stylesheet" href="css/style.a9656a.css?v=1">
+<script src='app.0a2868.js'></script>
+<script src="https://example.com/app.js"></script>
+<a href="#app.js">link</a>
+<img src="/img/logo.8f8cbb.png">
+<div style="background: url(../img/logo.8f8cbb.png)"></div>
+</html>`,
+		"app/css/style.a9656a.css": `@import "base.9e07b0.css"; body { background: url("../../img/logo.8f8cbb.png#top"); } i { background: url(data:image/png;base64,AAAA) }`,
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, p))
+		assert.NoError(t, err)
+		assert.Equal(t, e, string(b), p)
+	}
+	_, err = os.Stat(filepath.Join(dir, "app", "app.js"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestWriteResourcesManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	b, err := New(&Configuration{
+		InputPath:     dir,
+		ResourcesHash: []string{"*.js"},
+	}, log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, "go.mod", "module app\n\ngo 1.13\n")
+	writeTestFile(t, dir, "main.go", `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(HashedResource("app\\app.js"), HashedResource("app/index.html"), len(ResourcesManifest()))
+}
+`)
+	assert.NoError(t, b.writeResourcesManifest(runtime.GOOS, runtime.GOARCH, map[string]string{"app/app.js": "app/app.0a2868.js"}))
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = dir
+	o, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(o))
+	assert.Equal(t, "app/app.0a2868.js app/index.html 1\n", string(o))
+}
+
+func TestHashResourcesCycle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, d := range []string{"unhashed", "hashed"} {
+		writeTestFile(t, dir, d+"/a.css", `@import "b.css";`)
+		writeTestFile(t, dir, d+"/b.css", `@import "a.css";`)
+	}
+
+	// Cycles are fine as long as the resources they go through are not hashed
+	ps, err := newPathPatterns([]string{"b.css"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := hashResources(filepath.Join(dir, "unhashed"), ps, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, m, 1) {
+		b, err := ioutil.ReadFile(filepath.Join(dir, "unhashed", "a.css"))
+		assert.NoError(t, err)
+		assert.Equal(t, `@import "`+path.Base(m["b.css"])+`";`, string(b))
+	}
+
+	// Cycles going through hashed resources fail
+	if ps, err = newPathPatterns([]string{"*.css"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = hashResources(filepath.Join(dir, "hashed"), ps, 0)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "references are cyclic: a.css -> b.css -> a.css")
+	}
+}
@@ -0,0 +1,150 @@
+package astibundler
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Interpolate replaces ${VAR} and ${VAR:-default} in every string of the configuration, including map
+// and list values, with the value returned by lookup. "$${" can be used to output a literal "${".
+// An error listing undefined variables is returned unless they have a default.
+func (c *Configuration) Interpolate(lookup func(string) (string, bool)) (err error) {
+	// Interpolate
+	i := &interpolator{
+		lookup:    lookup,
+		undefined: make(map[string]bool),
+	}
+	i.value(reflect.ValueOf(c))
+
+	// Invalid expressions
+	if len(i.invalid) > 0 {
+		err = fmt.Errorf("invalid expressions: %s", strings.Join(i.invalid, ", "))
+		return
+	}
+
+	// Undefined variables
+	if len(i.undefined) > 0 {
+		var ks []string
+		for k := range i.undefined {
+			ks = append(ks, k)
+		}
+		sort.Strings(ks)
+		err = fmt.Errorf("undefined environment variables: %s", strings.Join(ks, ", "))
+		return
+	}
+	return
+}
+
+type interpolator struct {
+	invalid   []string
+	lookup    func(string) (string, bool)
+	undefined map[string]bool
+}
+
+func (i *interpolator) value(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(i.string(v.String()))
+	case reflect.Ptr:
+		if !v.IsNil() {
+			i.value(v.Elem())
+		}
+	case reflect.Struct:
+		for idx := 0; idx < v.NumField(); idx++ {
+			if v.Field(idx).CanSet() {
+				i.value(v.Field(idx))
+			}
+		}
+	case reflect.Array, reflect.Slice:
+		for idx := 0; idx < v.Len(); idx++ {
+			i.value(v.Index(idx))
+		}
+	case reflect.Map:
+		// Map values are not addressable, therefore we interpolate a copy
+		for _, k := range v.MapKeys() {
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(v.MapIndex(k))
+			i.value(e)
+			v.SetMapIndex(k, e)
+		}
+	case reflect.Interface:
+		if !v.IsNil() {
+			e := reflect.New(v.Elem().Type()).Elem()
+			e.Set(v.Elem())
+			i.value(e)
+			v.Set(e)
+		}
+	}
+}
+
+func (i *interpolator) string(s string) string {
+	// Nothing to interpolate
+	if !strings.Contains(s, "${") {
+		return s
+	}
+
+	// Loop through expressions
+	var o strings.Builder
+	for {
+		// Find next expression
+		idx := strings.Index(s, "${")
+		if idx < 0 {
+			o.WriteString(s)
+			break
+		}
+
+		// Escaped
+		if idx > 0 && s[idx-1] == '$' {
+			o.WriteString(s[:idx])
+			o.WriteString("{")
+			s = s[idx+2:]
+			continue
+		}
+		o.WriteString(s[:idx])
+		s = s[idx+2:]
+
+		// Find end of expression
+		end := strings.Index(s, "}")
+		if end < 0 {
+			i.invalid = append(i.invalid, "${"+s)
+			o.WriteString("${" + s)
+			break
+		}
+		e := s[:end]
+		s = s[end+1:]
+
+		// Parse expression
+		name, def, hasDefault := e, "", false
+		if idx := strings.Index(e, ":-"); idx >= 0 {
+			name, def, hasDefault = e[:idx], e[idx+2:], true
+		}
+		if !isValidVariableName(name) {
+			i.invalid = append(i.invalid, "${"+e+"}")
+			continue
+		}
+
+		// Lookup
+		if v, ok := i.lookup(name); ok && (v != "" || !hasDefault) {
+			o.WriteString(v)
+		} else if hasDefault {
+			o.WriteString(def)
+		} else {
+			i.undefined[name] = true
+		}
+	}
+	return o.String()
+}
+
+func isValidVariableName(n string) bool {
+	if n == "" {
+		return false
+	}
+	for idx, r := range n {
+		if r != '_' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (idx == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+package astibundler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigurationInterpolate(t *testing.T) {
+	vs := map[string]string{"EMPTY": "", "OS": "linux", "VERSION": "1.0.0"}
+	lookup := func(k string) (v string, ok bool) {
+		v, ok = vs[k]
+		return
+	}
+
+	c := &Configuration{
+		AppName:      "App ${VERSION}",
+		BuildFlags:   map[string]string{"tags": "${TAGS:-release}"},
+		Environments: []ConfigurationEnvironment{{EnvironmentVariables: map[string]string{"CC": "${CC:-gcc}"}, OS: "${OS}"}},
+		InfoPlist:    map[string]interface{}{"CFBundleVersion": "${VERSION}", "Nested": []interface{}{"${EMPTY:-default}", true}},
+		LDFlags:      LDFlags{"X": []string{"main.Version=${VERSION}", "main.Literal=$${VERSION}"}},
+	}
+	assert.NoError(t, c.Interpolate(lookup))
+	assert.Equal(t, &Configuration{
+		AppName:      "App 1.0.0",
+		BuildFlags:   map[string]string{"tags": "release"},
+		Environments: []ConfigurationEnvironment{{EnvironmentVariables: map[string]string{"CC": "gcc"}, OS: "linux"}},
+		InfoPlist:    map[string]interface{}{"CFBundleVersion": "1.0.0", "Nested": []interface{}{"default", true}},
+		LDFlags:      LDFlags{"X": []string{"main.Version=1.0.0", "main.Literal=${VERSION}"}},
+	}, c)
+
+	c = &Configuration{AppName: "${B}${A}", OutputPath: "${A}", ResourcesPath: "${EMPTY}"}
+	err := c.Interpolate(lookup)
+	assert.EqualError(t, err, "undefined environment variables: A, B")
+
+	c = &Configuration{AppName: "${1A}"}
+	assert.Error(t, c.Interpolate(lookup))
+}
@@ -0,0 +1,271 @@
+package astibundler
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// thirdPartyNoticesFileName is the name of the third party notices file
+const thirdPartyNoticesFileName = "THIRD_PARTY_NOTICES"
+
+// licenseFileRegexp matches the names of the files containing licenses and notices of modules
+var licenseFileRegexp = regexp.MustCompile(`(?i)^(licen[cs]e|copying|notice|copyright|unlicense)([.\-_].*)?$`)
+
+// thirdPartyNotice represents the licenses of a third party software
+type thirdPartyNotice struct {
+	licenses []thirdPartyLicense
+	name     string
+	version  string
+}
+
+type thirdPartyLicense struct {
+	data []byte
+	name string
+}
+
+// thirdPartyNotices returns the licenses of the go runtime, of the go modules the main package depends on
+// for an environment, of astilectron and of electron, including chromium's LICENSES.chromium.html
+func (b *Bundler) thirdPartyNotices(e ConfigurationEnvironment) (o []byte, err error) {
+	// Go
+	var ns []thirdPartyNotice
+	var n thirdPartyNotice
+	if n, err = b.goNotice(); err != nil {
+		err = fmt.Errorf("getting go notice failed: %w", err)
+		return
+	}
+	ns = append(ns, n)
+
+	// Go modules
+	var mns []thirdPartyNotice
+	if mns, err = b.goModulesNotices(e); err != nil {
+		err = fmt.Errorf("getting go modules notices failed: %w", err)
+		return
+	}
+	ns = append(ns, mns...)
+
+	// Vendor
+	for _, v := range []struct {
+		name    string
+		names   []string
+		path    string
+		version string
+	}{
+		{name: "astilectron", names: []string{"LICENSE"}, path: b.astilectronCachePath(), version: b.versionAstilectron},
+		{name: "electron", names: []string{"LICENSE", "LICENSES.chromium.html"}, path: b.electronCachePath(e.OS, e.Arch), version: b.versionElectron},
+	} {
+		n := thirdPartyNotice{name: v.name, version: v.version}
+		if n.licenses, err = zipLicenses(v.path, v.names...); err != nil {
+			err = fmt.Errorf("reading licenses of %s failed: %w", v.path, err)
+			return
+		}
+		ns = append(ns, n)
+	}
+
+	// Write
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "THIRD PARTY NOTICES\n\n%s includes the following third party software.\n", b.appName)
+	sep := strings.Repeat("=", 80)
+	for _, n := range ns {
+		fmt.Fprintf(buf, "\n%s\n%s", sep, n.name)
+		if n.version != "" {
+			fmt.Fprintf(buf, " %s", n.version)
+		}
+		fmt.Fprintf(buf, "\n%s\n", sep)
+		if len(n.licenses) == 0 {
+			fmt.Fprintf(buf, "\nNo license file found\n")
+		}
+		for _, l := range n.licenses {
+			fmt.Fprintf(buf, "\n%s:\n\n%s\n", l.name, bytes.TrimSpace(l.data))
+		}
+	}
+	o = buf.Bytes()
+	return
+}
+
+// goNotice returns the license of the go runtime linked in the binary
+func (b *Bundler) goNotice() (n thirdPartyNotice, err error) {
+	// Get goroot
+	var o []byte
+	if o, err = exec.Command(b.pathGoBinary, "env", "GOROOT").Output(); err != nil {
+		err = fmt.Errorf("getting GOROOT failed: %w", err)
+		return
+	}
+
+	// Get version
+	var v []byte
+	if v, err = exec.Command(b.pathGoBinary, "env", "GOVERSION").Output(); err != nil {
+		// GOVERSION is not available before go1.16
+		v = nil
+		err = nil
+	}
+
+	// Read licenses
+	n = thirdPartyNotice{name: "Go", version: strings.TrimSpace(string(v))}
+	if n.licenses, err = dirLicenses(strings.TrimSpace(string(o))); err != nil {
+		err = fmt.Errorf("reading licenses failed: %w", err)
+		return
+	}
+	return
+}
+
+// goModulesNotices returns the licenses of the go modules the main package depends on for an environment
+func (b *Bundler) goModulesNotices(e ConfigurationEnvironment) (ns []thirdPartyNotice, err error) {
+	// List dependencies
+	args := []string{"list", "-deps", "-f", `{{with .Module}}{{if not .Main}}{{.Path}}{{"\t"}}{{.Version}}{{with .Replace}} => {{.Path}} {{.Version}}{{end}}{{"\t"}}{{.Dir}}{{end}}{{end}}`}
+	for _, k := range []string{"tags", "-tags"} {
+		if v, ok := b.buildFlags[k]; ok {
+			args = append(args, "-tags", v)
+		}
+	}
+	cmd := exec.Command(b.pathGoBinary, append(args, b.pathBuild)...)
+	cmd.Dir = b.pathInput
+	cmd.Env = append(os.Environ(), buildEnv(e)...)
+	var o []byte
+	if o, err = cmd.Output(); err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			err = fmt.Errorf("listing dependencies failed: %w: %s", err, bytes.TrimSpace(ee.Stderr))
+		} else {
+			err = fmt.Errorf("listing dependencies failed: %w", err)
+		}
+		return
+	}
+
+	// Parse modules
+	dirs := make(map[string]string)
+	var ks []string
+	for _, l := range strings.Split(string(o), "\n") {
+		ss := strings.Split(l, "\t")
+		if len(ss) != 3 {
+			continue
+		}
+		k := strings.TrimSpace(ss[0] + " " + ss[1])
+		if _, ok := dirs[k]; !ok {
+			ks = append(ks, k)
+		}
+		dirs[k] = ss[2]
+		if dirs[k] == "" {
+			// Vendored modules have no dir
+			dirs[k] = filepath.Join(goModulePath(b.pathInput), "vendor", filepath.FromSlash(ss[0]))
+		}
+	}
+	sort.Strings(ks)
+
+	// Loop through modules
+	for _, k := range ks {
+		ss := strings.SplitN(k, " ", 2)
+		n := thirdPartyNotice{name: ss[0]}
+		if len(ss) > 1 {
+			n.version = ss[1]
+		}
+		if n.licenses, err = dirLicenses(dirs[k]); err != nil {
+			err = fmt.Errorf("reading licenses of %s failed: %w", dirs[k], err)
+			return
+		}
+		ns = append(ns, n)
+	}
+	return
+}
+
+// dirLicenses returns the licenses found at the root of a dir
+func dirLicenses(dir string) (ls []thirdPartyLicense, err error) {
+	// Read dir
+	var fis []os.FileInfo
+	if fis, err = ioutil.ReadDir(dir); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		} else {
+			err = fmt.Errorf("reading dir %s failed: %w", dir, err)
+		}
+		return
+	}
+
+	// Loop through files
+	for _, fi := range fis {
+		// Not a license
+		if !fi.Mode().IsRegular() || !licenseFileRegexp.MatchString(fi.Name()) {
+			continue
+		}
+
+		// Read
+		p := filepath.Join(dir, fi.Name())
+		l := thirdPartyLicense{name: fi.Name()}
+		if l.data, err = ioutil.ReadFile(p); err != nil {
+			err = fmt.Errorf("reading %s failed: %w", p, err)
+			return
+		}
+		ls = append(ls, l)
+	}
+	return
+}
+
+// zipLicenses returns the files of a zip whose base name is one of the provided names. When several
+// files have the same name, the least nested one is used.
+func zipLicenses(p string, names ...string) (ls []thirdPartyLicense, err error) {
+	// Open
+	var r *zip.ReadCloser
+	if r, err = zip.OpenReader(p); err != nil {
+		err = fmt.Errorf("opening %s failed: %w", p, err)
+		return
+	}
+	defer r.Close()
+
+	// Loop through names
+	for _, n := range names {
+		// Find file
+		var f *zip.File
+		for _, zf := range r.File {
+			if path.Base(zf.Name) == n && !zf.FileInfo().IsDir() && (f == nil || strings.Count(zf.Name, "/") < strings.Count(f.Name, "/")) {
+				f = zf
+			}
+		}
+		if f == nil {
+			continue
+		}
+
+		// Read
+		l := thirdPartyLicense{name: n}
+		if l.data, err = readZipFile(f); err != nil {
+			err = fmt.Errorf("reading %s failed: %w", f.Name, err)
+			return
+		}
+		ls = append(ls, l)
+	}
+	return
+}
+
+func readZipFile(f *zip.File) (b []byte, err error) {
+	var rc io.ReadCloser
+	if rc, err = f.Open(); err != nil {
+		return
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+// writeThirdPartyNotices writes the third party notices of an environment to a path
+func (b *Bundler) writeThirdPartyNotices(e ConfigurationEnvironment, p string) (err error) {
+	// Get notices
+	var o []byte
+	if o, err = b.thirdPartyNotices(e); err != nil {
+		err = fmt.Errorf("getting third party notices failed: %w", err)
+		return
+	}
+
+	// Write
+	b.l.Debugf("Writing third party notices to %s", p)
+	if err = ioutil.WriteFile(p, o, 0644); err != nil {
+		err = fmt.Errorf("writing %s failed: %w", p, err)
+		return
+	}
+	return
+}
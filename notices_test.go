@@ -0,0 +1,97 @@
+package astibundler
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestZip(t *testing.T, p string, files map[string]string) {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for n, c := range files {
+		fw, err := w.Create(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = fw.Write([]byte(c)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestThirdPartyNotices(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	e := ConfigurationEnvironment{Arch: "amd64", OS: "linux"}
+	b, err := New(&Configuration{
+		AppName:              "App",
+		InputPath:            filepath.Join(dir, "app"),
+		VersionAstilectron:   "0.49.0",
+		VersionElectron:      "11.4.3",
+		WorkingDirectoryPath: filepath.Join(dir, "wd"),
+	}, log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, "app/go.mod", `module app
+
+go 1.13
+
+require (
+	example.com/dep v1.0.0
+	example.com/nolicense v1.0.0
+)
+
+replace example.com/dep => ../dep
+
+replace example.com/nolicense => ../nolicense
+`)
+	writeTestFile(t, dir, "app/main.go", "package main\n\nimport (\n\t_ \"example.com/dep\"\n\t_ \"example.com/nolicense\"\n)\n\nfunc main() {}\n")
+	writeTestFile(t, dir, "dep/go.mod", "module example.com/dep\n\ngo 1.13\n")
+	writeTestFile(t, dir, "dep/dep.go", "package dep\n")
+	writeTestFile(t, dir, "dep/LICENSE.md", "Dep license")
+	writeTestFile(t, dir, "dep/NOTICE", "Dep notice")
+	writeTestFile(t, dir, "dep/README.md", "Dep readme")
+	writeTestFile(t, dir, "nolicense/go.mod", "module example.com/nolicense\n\ngo 1.13\n")
+	writeTestFile(t, dir, "nolicense/nolicense.go", "package nolicense\n")
+	writeTestZip(t, b.astilectronCachePath(), map[string]string{"astilectron-0.49.0/LICENSE": "Astilectron license", "astilectron-0.49.0/src/LICENSE": "Nested license"})
+	writeTestZip(t, b.electronCachePath(e.OS, e.Arch), map[string]string{"LICENSE": "Electron license", "LICENSES.chromium.html": "<html>Chromium licenses</html>"})
+
+	sep := strings.Repeat("=", 80)
+	o, err := b.thirdPartyNotices(e)
+	assert.NoError(t, err)
+	s := string(o)
+	for _, v := range []string{
+		"THIRD PARTY NOTICES\n\nApp includes",
+		"\nGo ",
+		"\nexample.com/dep v1.0.0 => ../dep\n" + sep + "\n\nLICENSE.md:\n\nDep license\n\nNOTICE:\n\nDep notice\n",
+		"\nexample.com/nolicense v1.0.0 => ../nolicense\n" + sep + "\n\nNo license file found\n",
+		"\nastilectron 0.49.0\n" + sep + "\n\nLICENSE:\n\nAstilectron license\n",
+		"\nelectron 11.4.3\n" + sep + "\n\nLICENSE:\n\nElectron license\n\nLICENSES.chromium.html:\n\n<html>Chromium licenses</html>\n",
+	} {
+		assert.Contains(t, s, v)
+	}
+	assert.NotContains(t, s, "Dep readme")
+	assert.NotContains(t, s, "Nested license")
+}
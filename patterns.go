@@ -0,0 +1,155 @@
+package astibundler
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// pathPattern represents a gitignore-style pattern
+type pathPattern struct {
+	// Whether the pattern only matches dirs
+	dirOnly bool
+	// Whether the pattern starts with "!"
+	negated bool
+	// Slash separated segments, "**" matching any number of segments
+	segments []string
+}
+
+// newPathPattern parses a gitignore-style pattern:
+//   - a leading "!" negates the pattern
+//   - a trailing "/" only matches dirs
+//   - a pattern containing no other "/" matches at any depth, otherwise it's relative to the root
+//   - "**" matches any number of dirs, other segments are matched with path.Match
+func newPathPattern(s string) (p pathPattern, err error) {
+	// Negated
+	if strings.HasPrefix(s, "!") {
+		p.negated = true
+		s = s[1:]
+	}
+
+	// Dir only
+	if strings.HasSuffix(s, "/") {
+		p.dirOnly = true
+		s = strings.TrimRight(s, "/")
+	}
+
+	// Empty
+	if s == "" {
+		err = fmt.Errorf("pattern is empty")
+		return
+	}
+
+	// Anchored
+	anchored := strings.Contains(s, "/")
+	s = strings.TrimPrefix(s, "/")
+	p.segments = strings.Split(s, "/")
+	if !anchored {
+		p.segments = append([]string{"**"}, p.segments...)
+	}
+
+	// Validate segments
+	for _, sg := range p.segments {
+		if _, err = path.Match(sg, ""); err != nil {
+			err = fmt.Errorf("segment %s is invalid: %w", sg, err)
+			return
+		}
+	}
+	return
+}
+
+// match checks whether a slash separated path relative to the root matches the pattern
+func (p pathPattern) match(name string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	return matchPathSegments(p.segments, strings.Split(name, "/"))
+}
+
+func matchPathSegments(ps, ns []string) bool {
+	for len(ps) > 0 {
+		// Double star
+		if ps[0] == "**" {
+			for idx := 0; idx <= len(ns); idx++ {
+				if matchPathSegments(ps[1:], ns[idx:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		// Single segment
+		if len(ns) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(ps[0], ns[0]); !ok {
+			return false
+		}
+		ps, ns = ps[1:], ns[1:]
+	}
+	return len(ns) == 0
+}
+
+// pathPatterns represents an ordered list of gitignore-style patterns where the last matching pattern
+// wins
+type pathPatterns []pathPattern
+
+func newPathPatterns(ss []string) (ps pathPatterns, err error) {
+	for _, s := range ss {
+		var p pathPattern
+		if p, err = newPathPattern(s); err != nil {
+			err = fmt.Errorf("parsing pattern %s failed: %w", s, err)
+			return
+		}
+		ps = append(ps, p)
+	}
+	return
+}
+
+// match checks whether a slash separated path relative to the root, or one of its parent dirs, matches
+// the patterns. As with gitignore, a path can't be matched out once one of its parent dirs is matched.
+func (ps pathPatterns) match(name string, isDir bool) bool {
+	ss := strings.Split(name, "/")
+	for idx := 1; idx <= len(ss); idx++ {
+		if ps.matchExact(strings.Join(ss[:idx], "/"), idx < len(ss) || isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ps pathPatterns) matchExact(name string, isDir bool) (m bool) {
+	for _, p := range ps {
+		if p.match(name, isDir) {
+			m = !p.negated
+		}
+	}
+	return
+}
+
+// pathFilter keeps paths matching the include patterns, if any, and not matching the exclude patterns
+type pathFilter struct {
+	exclude pathPatterns
+	include pathPatterns
+}
+
+func newPathFilter(include, exclude []string) (f pathFilter, err error) {
+	if f.include, err = newPathPatterns(include); err != nil {
+		err = fmt.Errorf("parsing include patterns failed: %w", err)
+		return
+	}
+	if f.exclude, err = newPathPatterns(exclude); err != nil {
+		err = fmt.Errorf("parsing exclude patterns failed: %w", err)
+		return
+	}
+	return
+}
+
+// keep checks whether a slash separated path relative to the root is kept. Dirs are kept unless
+// excluded since they may contain included files.
+func (f pathFilter) keep(name string, isDir bool) bool {
+	if f.exclude.match(name, isDir) {
+		return false
+	}
+	return isDir || len(f.include) == 0 || f.include.match(name, false)
+}
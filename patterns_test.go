@@ -0,0 +1,118 @@
+package astibundler
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathFilter(t *testing.T) {
+	f, err := newPathFilter(nil, []string{"*.map", ".DS_Store", "node_modules/", "/test", "app/**/fixtures", "!app/lib/keep.map"})
+	assert.NoError(t, err)
+	for n, e := range map[string]bool{
+		"app/index.html":                 true,
+		"app/index.js.map":               false,
+		"app/lib/keep.map":               true,
+		".DS_Store":                      false,
+		"app/img/.DS_Store":              false,
+		"node_modules":                   true,
+		"node_modules/a/index.js":        false,
+		"app/node_modules/a/index.js":    false,
+		"test/a.js":                      false,
+		"app/test/a.js":                  true,
+		"app/fixtures/a.json":            false,
+		"app/lib/deep/fixtures/b/a.json": false,
+		"app/lib/deep/fixtures.json":     true,
+	} {
+		assert.Equal(t, e, f.keep(n, false), n)
+	}
+	assert.False(t, f.keep("node_modules", true))
+
+	f, err = newPathFilter([]string{"app/**/*.html", "vendor"}, []string{"app/private/"})
+	assert.NoError(t, err)
+	for n, e := range map[string]bool{
+		"app/index.html":         true,
+		"app/a/b/index.html":     true,
+		"app/index.js":           false,
+		"app/private/index.html": false,
+		"vendor/a.js":            true,
+		"lib/vendor/a.js":        true,
+	} {
+		assert.Equal(t, e, f.keep(n, false), n)
+	}
+	assert.True(t, f.keep("app/a", true))
+
+	_, err = newPathFilter(nil, []string{"a/[b"})
+	assert.Error(t, err)
+	_, err = newPathFilter([]string{"!"}, nil)
+	assert.Error(t, err)
+}
+
+func TestCopyResources(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require privileges")
+	}
+
+	dir, err := ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Resources
+	if err = os.MkdirAll(filepath.Join(dir, "resources", "app", "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, "resources/app/index.html", "<html>")
+	writeTestFile(t, dir, "resources/app/index.js.map", "map")
+	writeTestFile(t, dir, "shared/logo.svg", "<svg>")
+	writeTestFile(t, dir, "shared/lib/lib.js", "lib")
+	for n, o := range map[string]string{
+		"resources/app/logo.svg": "../../shared/logo.svg",
+		"resources/app/lib":      "../../shared/lib",
+		"resources/app/loop":     "..",
+	} {
+		if err = os.Symlink(o, filepath.Join(dir, n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := New(&Configuration{
+		InputPath:            dir,
+		ResourcesExclude:     []string{"*.map"},
+		WorkingDirectoryPath: filepath.Join(dir, "wd"),
+	}, log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := filepath.Join(dir, "copy")
+	assert.NoError(t, b.copyResources(o))
+
+	// Symlinks are copied as regular files and dirs
+	for p, e := range map[string]string{
+		"app/index.html": "<html>",
+		"app/lib/lib.js": "lib",
+		"app/logo.svg":   "<svg>",
+	} {
+		fi, err := os.Lstat(filepath.Join(o, p))
+		if assert.NoError(t, err, p) {
+			assert.True(t, fi.Mode().IsRegular(), p)
+		}
+		c, err := ioutil.ReadFile(filepath.Join(o, p))
+		assert.NoError(t, err, p)
+		assert.Equal(t, e, string(c), p)
+	}
+	fi, err := os.Stat(filepath.Join(o, "app", "empty"))
+	if assert.NoError(t, err) {
+		assert.True(t, fi.IsDir())
+	}
+	for _, p := range []string{"app/index.js.map", "app/loop"} {
+		_, err = os.Stat(filepath.Join(o, p))
+		assert.True(t, os.IsNotExist(err), p)
+	}
+}
@@ -0,0 +1,298 @@
+package astibundler
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/asticode/go-astilectron"
+)
+
+// EnvironmentPlan represents what bundling an environment would do
+type EnvironmentPlan struct {
+	// Files produced in the environment output directory and the environment archive
+	Artifacts []string
+	// Command line of the go build command
+	BuildCommand []string
+	// Environment variables added to the go build command
+	BuildEnv []string
+	// Vendor zip files provisioning
+	Downloads []PlanDownload
+	// Environment
+	Environment ConfigurationEnvironment
+	// Files generated in the project
+	GeneratedFiles []string
+	// Resolved paths indexed by name
+	Paths map[string]string
+	// Command lines of the environment specific resources adapters
+	ResourcesAdapters []string
+	// Command lines of the resources adapters run once and shared by every environment
+	SharedResourcesAdapters []string
+	// Whether the environment is up to date and would be skipped
+	UpToDate bool
+}
+
+// PlanDownload represents a vendor zip file provisioning
+type PlanDownload struct {
+	// Whether the file is already in the cache and won't be downloaded
+	Cached    bool
+	CachePath string
+	// Either a URL or, when zipping a local astilectron, a local path
+	Source string
+}
+
+// Plan returns, for every environment, what bundling would do without doing it
+func (b *Bundler) Plan() (ps []EnvironmentPlan, err error) {
+	for _, e := range b.environments {
+		var p EnvironmentPlan
+		if p, err = b.plan(e); err != nil {
+			err = fmt.Errorf("planning environment %s/%s failed: %w", e.OS, e.Arch, err)
+			return
+		}
+		ps = append(ps, p)
+	}
+	return
+}
+
+func (b *Bundler) plan(e ConfigurationEnvironment) (p EnvironmentPlan, err error) {
+	// Init
+	environmentPath := b.environmentPath(e)
+	p = EnvironmentPlan{
+		Environment:    e,
+		GeneratedFiles: []string{b.bindOutputPath(e.OS, e.Arch)},
+		Paths: map[string]string{
+			"bind input":        b.pathBindInput,
+			"bind output":       b.pathBindOutput,
+			"build":             b.pathBuild,
+			"cache":             b.pathCache,
+			"environment":       environmentPath,
+			"input":             b.pathInput,
+			"output":            b.pathOutput,
+			"resources":         filepath.Join(b.pathInput, b.pathResources),
+			"staged resources":  b.pathResourcesStaged,
+			"vendor":            b.pathVendor,
+			"working directory": b.pathWorkingDirectory,
+		},
+	}
+
+	// Up to date
+	if !b.force {
+		if p.UpToDate, err = b.isUpToDate(e); err != nil {
+			err = fmt.Errorf("checking whether environment is up to date failed: %w", err)
+			return
+		}
+	}
+
+	// Downloads
+	astilectronSrc := astilectron.AstilectronDownloadSrc(b.versionAstilectron)
+	if len(b.pathAstilectron) > 0 {
+		astilectronSrc = b.pathAstilectron
+	}
+	p.Downloads = []PlanDownload{
+		newPlanDownload(astilectronSrc, b.astilectronCachePath(), len(b.pathAstilectron) == 0),
+		newPlanDownload(astilectron.ElectronDownloadSrc(e.OS, e.Arch, b.versionElectron), b.electronCachePath(e.OS, e.Arch), true),
+	}
+
+	// Resources adapters
+	for idx, a := range b.resourcesAdapters {
+		// Adapter doesn't run for this environment
+		environmentSpecific := a.isEnvironmentSpecific()
+		if environmentSpecific && !a.matches(e.OS, e.Arch) {
+			continue
+		}
+
+		// Expand
+		d := b.resourcesAdapterData("", "", b.pathResourcesStaged)
+		if environmentSpecific {
+			d = b.resourcesAdapterData(e.OS, e.Arch, filepath.Join(b.pathBindInput, b.pathResources))
+		}
+		if a, err = a.expand(d); err != nil {
+			err = fmt.Errorf("expanding resources adapter #%d failed: %w", idx, err)
+			return
+		}
+		var args []string
+		for _, arg := range append(append([]string{a.Name}, a.Args...), a.Files...) {
+			args = append(args, shellQuote(arg))
+		}
+		var env []string
+		for _, v := range resourcesAdapterEnv(a) {
+			env = append(env, shellQuote(v))
+		}
+		cmd := strings.TrimSpace(strings.Join(env, " ") + " " + strings.Join(args, " "))
+		if environmentSpecific {
+			p.ResourcesAdapters = append(p.ResourcesAdapters, cmd)
+		} else {
+			p.SharedResourcesAdapters = append(p.SharedResourcesAdapters, cmd)
+		}
+	}
+
+	// Resources manifest
+	if len(b.resourcesHash) > 0 {
+		p.GeneratedFiles = append(p.GeneratedFiles, b.resourcesManifestPath(e.OS, e.Arch))
+	}
+
+	// ASAR reader
+	if b.resourcesASAR {
+		p.GeneratedFiles = append(p.GeneratedFiles, b.asarReaderPath())
+	}
+
+	// Windows .syso
+	if e.OS == "windows" && (len(b.pathIconWindows) > 0 || len(b.pathManifest) > 0) {
+		p.GeneratedFiles = append(p.GeneratedFiles, filepath.Join(b.pathInput, "windows.syso"))
+	}
+
+	// Build
+	p.BuildCommand = b.buildCmd(e, filepath.Join(environmentPath, "binary")).Args
+	p.BuildEnv = buildEnv(e)
+
+	// Artifacts
+	p.Artifacts = b.artifacts(e)
+	return
+}
+
+func newPlanDownload(src, cachePath string, cacheable bool) PlanDownload {
+	_, err := os.Stat(cachePath)
+	return PlanDownload{
+		Cached:    cacheable && err == nil,
+		CachePath: cachePath,
+		Source:    src,
+	}
+}
+
+// artifacts returns the paths of the files produced in the environment output directory and of the
+// environment archive
+func (b *Bundler) artifacts(e ConfigurationEnvironment) (ps []string) {
+	environmentPath := b.environmentPath(e)
+	switch e.OS {
+	case "darwin":
+		contentsPath := filepath.Join(environmentPath, b.appName+".app", "Contents")
+		ps = append(ps, filepath.Join(contentsPath, "MacOS", b.darwinBinaryName()))
+		if len(b.pathIconDarwin) > 0 {
+			ps = append(ps, filepath.Join(contentsPath, "Resources", b.darwinIconName()))
+		}
+		ps = append(ps, filepath.Join(contentsPath, "Info.plist"))
+		if b.releaseBaseURL != nil {
+			ps = append(ps, b.releaseArtifactPath(e))
+		}
+	case "linux":
+		ps = append(ps, filepath.Join(environmentPath, b.appName))
+	case "windows":
+		ps = append(ps, filepath.Join(environmentPath, b.appName+".exe"))
+	}
+	if b.noticesEnabled {
+		ps = append(ps, filepath.Join(environmentPath, thirdPartyNoticesFileName))
+	}
+	if b.sbomEnabled {
+		ps = append(ps, filepath.Join(environmentPath, sbomCycloneDXFileName))
+		if b.sbomSPDX {
+			ps = append(ps, filepath.Join(environmentPath, sbomSPDXFileName))
+		}
+	}
+	if b.archiveName != nil {
+		if p, err := b.archivePath(e); err == nil {
+			ps = append(ps, p)
+		}
+	}
+	return
+}
+
+// Write writes a human readable version of the plan
+func (p EnvironmentPlan) Write(w io.Writer) (err error) {
+	ew := &errWriter{w: w}
+
+	// Environment
+	if p.UpToDate {
+		ew.printf("Environment %s/%s is up to date and would be skipped\n", p.Environment.OS, p.Environment.Arch)
+		return ew.err
+	}
+	ew.printf("Environment %s/%s\n", p.Environment.OS, p.Environment.Arch)
+
+	// Paths
+	ew.printf("  Paths:\n")
+	for _, k := range sortedKeys(p.Paths) {
+		ew.printf("    %s: %s\n", k, p.Paths[k])
+	}
+
+	// Downloads
+	ew.printf("  Vendor:\n")
+	for _, d := range p.Downloads {
+		if d.Cached {
+			ew.printf("    %s (cached)\n", d.CachePath)
+		} else {
+			ew.printf("    %s <- %s\n", d.CachePath, d.Source)
+		}
+	}
+
+	// Resources adapters
+	if len(p.SharedResourcesAdapters) > 0 {
+		ew.printf("  Shared resources adapters (run once per bundle):\n")
+		for _, a := range p.SharedResourcesAdapters {
+			ew.printf("    %s\n", a)
+		}
+	}
+	if len(p.ResourcesAdapters) > 0 {
+		ew.printf("  Resources adapters:\n")
+		for _, a := range p.ResourcesAdapters {
+			ew.printf("    %s\n", a)
+		}
+	}
+
+	// Generated files
+	ew.printf("  Generated files:\n")
+	for _, f := range p.GeneratedFiles {
+		ew.printf("    %s\n", f)
+	}
+
+	// Build
+	var args []string
+	for _, a := range p.BuildCommand {
+		args = append(args, shellQuote(a))
+	}
+	var env []string
+	for _, e := range p.BuildEnv {
+		env = append(env, shellQuote(e))
+	}
+	ew.printf("  Build:\n    %s %s\n", strings.Join(env, " "), strings.Join(args, " "))
+
+	// Artifacts
+	ew.printf("  Artifacts:\n")
+	for _, a := range p.Artifacts {
+		ew.printf("    %s\n", a)
+	}
+	return ew.err
+}
+
+// errWriter stops writing after the first error
+type errWriter struct {
+	err error
+	w   io.Writer
+}
+
+func (w *errWriter) printf(format string, args ...interface{}) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = fmt.Fprintf(w.w, format, args...)
+}
+
+// shellQuote quotes a string if it contains characters interpreted by a shell
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !strings.ContainsRune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:,@+", r)
+	}) < 0 {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+func sortedKeys(m map[string]string) (ks []string) {
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return
+}
@@ -0,0 +1,112 @@
+package astibundler
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/asticode/go-astilectron"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeTestFile(t, dir, "app/resources/index.html", "<html>")
+	writeTestFile(t, dir, "wd/cache/astilectron-0.49.0.zip", "astilectron")
+
+	b, err := New(&Configuration{
+		AppName: "App",
+		Environments: []ConfigurationEnvironment{
+			{Arch: "amd64", OS: "darwin"},
+			{Arch: "amd64", OS: "linux"},
+		},
+		InputPath:      filepath.Join(dir, "app"),
+		LDFlags:        LDFlags{"X": []string{"main.Version=1.0.0"}},
+		LDFlagsPackage: "main",
+		OutputPath:     filepath.Join(dir, "output"),
+		Release:        ConfigurationRelease{BaseURL: "https://example.com", Version: "1.0.0"},
+		ResourcesAdapters: []ConfigurationResourcesAdapter{
+			{Args: []string{"--out", "min dir"}, Name: "minify"},
+			{Args: []string{"--target={{.OS}}"}, EnvironmentVariables: map[string]string{"MODE": "prod"}, Name: "build", OS: []string{"linux"}},
+		},
+		VersionAstilectron:   "0.49.0",
+		VersionElectron:      "11.4.3",
+		WorkingDirectoryPath: filepath.Join(dir, "wd"),
+	}, log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ps, err := b.Plan()
+	assert.NoError(t, err)
+	if !assert.Len(t, ps, 2) {
+		return
+	}
+	darwin, linux := ps[0], ps[1]
+
+	// Downloads
+	assert.Equal(t, []PlanDownload{
+		{Cached: true, CachePath: filepath.Join(dir, "wd", "cache", "astilectron-0.49.0.zip"), Source: astilectron.AstilectronDownloadSrc("0.49.0")},
+		{CachePath: filepath.Join(dir, "wd", "cache", "electron-linux-amd64-11.4.3.zip"), Source: astilectron.ElectronDownloadSrc("linux", "amd64", "11.4.3")},
+	}, linux.Downloads)
+
+	// Resources adapters
+	assert.Equal(t, []string{`minify --out "min dir"`}, linux.SharedResourcesAdapters)
+	assert.Equal(t, []string{"MODE=prod build --target=linux"}, linux.ResourcesAdapters)
+	assert.Empty(t, darwin.ResourcesAdapters)
+
+	// Build
+	if assert.Len(t, linux.BuildCommand, 7) {
+		assert.Equal(t, []string{"build", "-ldflags"}, linux.BuildCommand[1:3])
+		assert.Contains(t, linux.BuildCommand[3], `-X "main.AppName=App"`)
+		assert.Contains(t, linux.BuildCommand[3], `-X "main.Version=1.0.0"`)
+		assert.Equal(t, []string{"-o", filepath.Join(dir, "output", "linux-amd64", "binary"), "."}, linux.BuildCommand[4:])
+	}
+	assert.Contains(t, linux.BuildEnv, "GOOS=linux")
+	assert.Contains(t, linux.BuildEnv, "GOARCH=amd64")
+
+	// Artifacts
+	assert.Equal(t, []string{filepath.Join(dir, "output", "linux-amd64", "App")}, linux.Artifacts)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "output", "darwin-amd64", "App.app", "Contents", "MacOS", "App"),
+		filepath.Join(dir, "output", "darwin-amd64", "App.app", "Contents", "Info.plist"),
+		filepath.Join(dir, "output", "darwin-amd64", "App-1.0.0-darwin-amd64.zip"),
+	}, darwin.Artifacts)
+
+	// Write
+	buf := &bytes.Buffer{}
+	assert.NoError(t, linux.Write(buf))
+	assert.Contains(t, buf.String(), "Environment linux/amd64\n")
+	assert.Contains(t, buf.String(), filepath.Join(dir, "wd", "cache", "astilectron-0.49.0.zip")+" (cached)\n")
+	assert.Contains(t, buf.String(), filepath.Join(dir, "wd", "cache", "electron-linux-amd64-11.4.3.zip")+" <- "+astilectron.ElectronDownloadSrc("linux", "amd64", "11.4.3")+"\n")
+
+	// Nothing has been created or modified
+	for _, p := range []string{filepath.Join(dir, "output"), filepath.Join(dir, "wd", "vendor"), filepath.Join(dir, "wd", "bind"), filepath.Join(dir, "app", "bind_linux_amd64.go")} {
+		_, err = os.Stat(p)
+		assert.True(t, os.IsNotExist(err), p)
+	}
+	fs, err := listFiles(dir, func(p string, fi os.FileInfo) bool { return true })
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "app", "resources", "index.html"), filepath.Join(dir, "wd", "cache", "astilectron-0.49.0.zip")}, fs)
+}
+
+func TestShellQuote(t *testing.T) {
+	for s, e := range map[string]string{
+		"":                  `""`,
+		"-ldflags":          "-ldflags",
+		"GOOS=linux":        "GOOS=linux",
+		"min dir":           `"min dir"`,
+		`-X "main.A=b"`:     `"-X \"main.A=b\""`,
+		"/path/to/file.zip": "/path/to/file.zip",
+	} {
+		assert.Equal(t, e, shellQuote(s), s)
+	}
+}
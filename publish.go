@@ -0,0 +1,398 @@
+package astibundler
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Environment variables holding publish credentials
+const (
+	publishEnvAccessKeyID     = "AWS_ACCESS_KEY_ID"
+	publishEnvRegion          = "AWS_REGION"
+	publishEnvSecretAccessKey = "AWS_SECRET_ACCESS_KEY"
+	publishEnvSessionToken    = "AWS_SESSION_TOKEN"
+)
+
+// publishRegionDefault is the default region of the bucket
+const publishRegionDefault = "us-east-1"
+
+// publishContentTypes are the content types of artifacts that can't be guessed from their extension
+var publishContentTypes = map[string]string{
+	".minisig":                "text/plain; charset=utf-8",
+	".patch":                  "application/octet-stream",
+	".yml":                    "text/yaml; charset=utf-8",
+	ChecksumsFileName:         "text/plain; charset=utf-8",
+	thirdPartyNoticesFileName: "text/plain; charset=utf-8",
+}
+
+// publisher uploads artifacts to an S3-compatible storage
+type publisher struct {
+	bucket      string
+	c           *http.Client
+	endpoint    *url.URL
+	prefix      string
+	region      string
+	virtualHost bool
+}
+
+// publishPrefixData represents the data the publish prefix is executed with
+type publishPrefixData struct {
+	AppName string
+	Version string
+}
+
+// newPublisher creates a publisher based on a configuration
+func newPublisher(c *Configuration) (p *publisher, err error) {
+	// Create publisher
+	p = &publisher{
+		bucket:      c.Publish.Bucket,
+		c:           &http.Client{},
+		region:      c.Publish.Region,
+		virtualHost: c.Publish.VirtualHostedStyle,
+	}
+
+	// Region
+	if len(p.region) == 0 {
+		if p.region = os.Getenv(publishEnvRegion); len(p.region) == 0 {
+			p.region = publishRegionDefault
+		}
+	}
+
+	// Endpoint
+	e := c.Publish.Endpoint
+	if len(e) == 0 {
+		e = "https://s3." + p.region + ".amazonaws.com"
+	}
+	if p.endpoint, err = url.Parse(e); err != nil {
+		err = fmt.Errorf("parsing endpoint %s failed: %w", e, err)
+		return
+	}
+	if p.endpoint.Scheme != "http" && p.endpoint.Scheme != "https" {
+		err = fmt.Errorf("endpoint %s is not an http or https url", e)
+		return
+	}
+
+	// Prefix
+	var t *template.Template
+	if t, err = template.New("prefix").Parse(c.Publish.Prefix); err != nil {
+		err = fmt.Errorf("parsing prefix template %s failed: %w", c.Publish.Prefix, err)
+		return
+	}
+	buf := &bytes.Buffer{}
+	if err = t.Execute(buf, publishPrefixData{
+		AppName: c.AppName,
+		Version: releaseVersion(c),
+	}); err != nil {
+		err = fmt.Errorf("executing prefix template %s failed: %w", c.Publish.Prefix, err)
+		return
+	}
+	p.prefix = strings.Trim(buf.String(), "/")
+	return
+}
+
+// Publish uploads the artifacts of a bundle result to the S3-compatible storage. Credentials are read
+// from the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables.
+// Update feeds are uploaded last so that they never point to files that are not uploaded yet. Files of
+// .app bundles are not uploaded since objects can't keep their symlinks: the release zip or the archive
+// of the environment is uploaded instead.
+func (b *Bundler) Publish(r BundleResult) (err error) {
+	// No publisher
+	if b.publisher == nil {
+		err = errors.New("publish bucket is not set")
+		return
+	}
+
+	// Get credentials
+	cs := s3Credentials{
+		accessKeyID:     os.Getenv(publishEnvAccessKeyID),
+		secretAccessKey: os.Getenv(publishEnvSecretAccessKey),
+		sessionToken:    os.Getenv(publishEnvSessionToken),
+	}
+	if len(cs.accessKeyID) == 0 || len(cs.secretAccessKey) == 0 {
+		err = fmt.Errorf("%s and %s environment variables must be set", publishEnvAccessKeyID, publishEnvSecretAccessKey)
+		return
+	}
+
+	// Filter artifacts
+	var as []BundleArtifact
+	if as, err = publishArtifacts(r.Artifacts); err != nil {
+		err = fmt.Errorf("filtering artifacts failed: %w", err)
+		return
+	}
+
+	// Sort artifacts
+	sort.SliceStable(as, func(i, j int) bool { return publishRank(as[i]) < publishRank(as[j]) })
+
+	// Loop through artifacts
+	var size int64
+	for _, a := range as {
+		// Check context error
+		if b.ctx.Err() != nil {
+			return b.ctx.Err()
+		}
+
+		// Upload
+		k := path.Join(b.publisher.prefix, a.Path)
+		b.l.Debugf("Uploading %s to %s", a.Path, b.publisher.objectURL(k))
+		if err = b.publisher.upload(b.ctx, cs, k, filepath.Join(r.OutputPath, filepath.FromSlash(a.Path)), a); err != nil {
+			err = fmt.Errorf("uploading %s failed: %w", a.Path, err)
+			return
+		}
+		size += a.Size
+	}
+	b.l.Infof("Published %d artifacts totaling %d bytes to %s", len(as), size, b.publisher.objectURL(b.publisher.prefix))
+	return
+}
+
+// publishArtifacts returns the artifacts that are uploaded, which are all of them except the files of .app
+// bundles. Environments with .app bundles must therefore have either a release zip or an archive.
+func publishArtifacts(as []BundleArtifact) (o []BundleArtifact, err error) {
+	// Loop through artifacts
+	bundles := make(map[string]bool)
+	packaged := make(map[string]bool)
+	for _, a := range as {
+		// Shared artifact
+		if a.Environment == nil {
+			o = append(o, a)
+			continue
+		}
+
+		// File of an .app bundle
+		e := a.Environment.OS + "/" + a.Environment.Arch
+		if isAppBundleFile(a.Path) {
+			bundles[e] = true
+			continue
+		}
+
+		// Release zip or archive
+		if strings.HasSuffix(a.Path, ".zip") || strings.HasSuffix(a.Path, ".tar.gz") || strings.HasSuffix(a.Path, ".tar.xz") {
+			packaged[e] = true
+		}
+		o = append(o, a)
+	}
+
+	// Environments with .app bundles must be packaged
+	var es []string
+	for e := range bundles {
+		if !packaged[e] {
+			es = append(es, e)
+		}
+	}
+	if len(es) > 0 {
+		sort.Strings(es)
+		err = fmt.Errorf("environments %s have .app bundles but neither a release zip nor an archive, set release.base_url or archive", strings.Join(es, ", "))
+		return
+	}
+	return
+}
+
+// isAppBundleFile checks whether a slash separated path is inside an .app bundle
+func isAppBundleFile(p string) bool {
+	ss := strings.Split(p, "/")
+	for _, s := range ss[:len(ss)-1] {
+		if strings.HasSuffix(s, ".app") {
+			return true
+		}
+	}
+	return false
+}
+
+// publishRank returns the upload rank of an artifact: environment artifacts, archives and patches first,
+// then shared files and update feeds last
+func publishRank(a BundleArtifact) int {
+	if a.Environment != nil || strings.Contains(a.Path, "/") {
+		return 0
+	}
+	if a.Path == releaseAppcastFileName || (strings.HasPrefix(a.Path, "latest") && strings.HasSuffix(a.Path, ".yml")) {
+		return 2
+	}
+	return 1
+}
+
+// publishContentType returns the content type of an artifact
+func publishContentType(name string) string {
+	if t, ok := publishContentTypes[name]; ok {
+		return t
+	}
+	ext := path.Ext(name)
+	if t, ok := publishContentTypes[ext]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); len(t) > 0 {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// objectURL returns the URL of an object
+func (p *publisher) objectURL(key string) *url.URL {
+	u := *p.endpoint
+	key = strings.TrimPrefix(key, "/")
+	if p.virtualHost {
+		u.Host = p.bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	} else {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + p.bucket + "/" + key
+	}
+	u.RawPath = s3URIEncode(u.Path, false)
+	return &u
+}
+
+// upload uploads a file whose size and sha256 are checked by the storage
+func (p *publisher) upload(ctx context.Context, cs s3Credentials, key, src string, a BundleArtifact) (err error) {
+	// Open
+	var f *os.File
+	if f, err = os.Open(src); err != nil {
+		err = fmt.Errorf("opening %s failed: %w", src, err)
+		return
+	}
+	defer f.Close()
+
+	// Create request
+	var req *http.Request
+	if req, err = http.NewRequest(http.MethodPut, p.objectURL(key).String(), f); err != nil {
+		err = fmt.Errorf("creating request failed: %w", err)
+		return
+	}
+	req = req.WithContext(ctx)
+	req.ContentLength = a.Size
+	if a.Size == 0 {
+		req.Body = http.NoBody
+	}
+
+	// Add headers
+	var sum []byte
+	if sum, err = hex.DecodeString(a.SHA256); err != nil {
+		err = fmt.Errorf("decoding sha256 %s failed: %w", a.SHA256, err)
+		return
+	}
+	req.Header.Set("Content-Type", publishContentType(path.Base(a.Path)))
+	req.Header.Set("X-Amz-Checksum-Sha256", base64.StdEncoding.EncodeToString(sum))
+	signS3Request(req, cs, p.region, a.SHA256, time.Now())
+
+	// Send
+	var resp *http.Response
+	if resp, err = p.c.Do(req); err != nil {
+		err = fmt.Errorf("sending request failed: %w", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	// Check status
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		err = fmt.Errorf("invalid status code %d: %s", resp.StatusCode, bytes.TrimSpace(b))
+		return
+	}
+	return
+}
+
+// s3Credentials represents S3 credentials
+type s3Credentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// signS3Request signs a request using AWS signature version 4. Every header of the request is signed.
+func signS3Request(req *http.Request, cs s3Credentials, region, payloadSHA256 string, t time.Time) {
+	// Add headers
+	amzDate := t.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadSHA256)
+	if len(cs.sessionToken) > 0 {
+		req.Header.Set("X-Amz-Security-Token", cs.sessionToken)
+	}
+
+	// Canonical headers
+	host := req.Host
+	if len(host) == 0 {
+		host = req.URL.Host
+	}
+	hs := map[string]string{"host": host}
+	for k, vs := range req.Header {
+		hs[strings.ToLower(k)] = strings.Join(strings.Fields(strings.Join(vs, ",")), " ")
+	}
+	var ks []string
+	for k := range hs {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	var chs []string
+	for _, k := range ks {
+		chs = append(chs, k+":"+hs[k]+"\n")
+	}
+	signedHeaders := strings.Join(ks, ";")
+
+	// Canonical query
+	q := req.URL.Query()
+	var qs []string
+	for k, vs := range q {
+		for _, v := range vs {
+			qs = append(qs, s3URIEncode(k, true)+"="+s3URIEncode(v, true))
+		}
+	}
+	sort.Strings(qs)
+
+	// Canonical request
+	cr := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		strings.Join(qs, "&"),
+		strings.Join(chs, ""),
+		signedHeaders,
+		payloadSHA256,
+	}, "\n")
+
+	// String to sign
+	date := amzDate[:8]
+	scope := date + "/" + region + "/s3/aws4_request"
+	h := sha256.Sum256([]byte(cr))
+	sts := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(h[:])
+
+	// Sign
+	k := []byte("AWS4" + cs.secretAccessKey)
+	for _, v := range []string{date, region, "s3", "aws4_request"} {
+		k = hmacSHA256(k, v)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		cs.accessKeyID, scope, signedHeaders, hex.EncodeToString(hmacSHA256(k, sts))))
+}
+
+func hmacSHA256(k []byte, s string) []byte {
+	h := hmac.New(sha256.New, k)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
+
+// s3URIEncode encodes every byte but unreserved characters and, unless asked otherwise, slashes
+func s3URIEncode(s string, encodeSlash bool) string {
+	buf := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			buf.WriteByte(c)
+		} else {
+			fmt.Fprintf(buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,401 @@
+package astibundler
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sam-kamerer/go-plister"
+	"gopkg.in/yaml.v3"
+)
+
+// Release feed file names
+const (
+	releaseAppcastFileName     = "appcast.xml"
+	releaseFeedFileNameDarwin  = "latest-mac.yml"
+	releaseFeedFileNameLinux   = "latest-linux.yml"
+	releaseFeedFileNameWindows = "latest.yml"
+)
+
+// sparkleNamespace is the namespace of Sparkle elements
+const sparkleNamespace = "http://www.andymatuschak.org/xml-namespaces/sparkle"
+
+// releaseURLData represents the data the release base URL is executed with
+type releaseURLData struct {
+	AppName string
+	Arch    string
+	OS      string
+	Version string
+}
+
+// newReleaseURLTemplate parses the release base URL
+func newReleaseURLTemplate(s string) (t *template.Template, err error) {
+	if t, err = template.New("base_url").Option("missingkey=error").Parse(s); err != nil {
+		err = fmt.Errorf("parsing template %s failed: %w", s, err)
+		return
+	}
+	return
+}
+
+// releaseVersion returns the version of the release, which defaults to the CFBundleShortVersionString of
+// the Info.plist property list
+func releaseVersion(c *Configuration) string {
+	if len(c.Release.Version) > 0 {
+		return c.Release.Version
+	}
+	return infoPlistString(c.InfoPlist, "CFBundleShortVersionString")
+}
+
+// infoPlistString returns a string value of the Info.plist property list
+func infoPlistString(m map[string]interface{}, key string) string {
+	if m == nil {
+		return ""
+	}
+	v, _ := plister.MapToInfoPlist(m).Get(key).(string)
+	return v
+}
+
+// electronArch returns the electron name of a go arch, which update feeds use
+func electronArch(arch string) string {
+	switch arch {
+	case "386":
+		return "ia32"
+	case "amd64":
+		return "x64"
+	case "arm":
+		return "armv7l"
+	}
+	return arch
+}
+
+// releaseArtifact represents the file an environment is updated with
+type releaseArtifact struct {
+	e      ConfigurationEnvironment
+	name   string
+	path   string
+	sha512 string
+	size   int64
+	url    string
+}
+
+// Release writes the update feeds of the bundled environments in the output path: a Sparkle appcast.xml
+// for darwin environments and electron-updater style latest.yml, latest-mac.yml and latest-linux.yml
+// feeds. Darwin apps are zipped beforehand since feeds can only point to files.
+func (b *Bundler) Release() (err error) {
+	// No base URL
+	if b.releaseBaseURL == nil {
+		err = errors.New("release base url is not set")
+		return
+	}
+
+	// Read notes
+	var notes string
+	if len(b.pathReleaseNotes) > 0 {
+		var n []byte
+		if n, err = ioutil.ReadFile(b.pathReleaseNotes); err != nil {
+			err = fmt.Errorf("reading %s failed: %w", b.pathReleaseNotes, err)
+			return
+		}
+		notes = strings.TrimSpace(string(n))
+	}
+
+	// Loop through environments
+	var as []releaseArtifact
+	for _, e := range b.environments {
+		var a releaseArtifact
+		if a, err = b.releaseArtifact(e); err != nil {
+			err = fmt.Errorf("getting release artifact of environment %s/%s failed: %w", e.OS, e.Arch, err)
+			return
+		}
+		as = append(as, a)
+	}
+
+	// Group artifacts by feed
+	date := time.Now().UTC()
+	feeds := make(map[string][]releaseArtifact)
+	var names []string
+	for _, a := range as {
+		n := releaseFeedName(a.e)
+		if _, ok := feeds[n]; !ok {
+			names = append(names, n)
+		}
+		feeds[n] = append(feeds[n], a)
+	}
+
+	// Write feeds
+	for _, n := range names {
+		p := filepath.Join(b.pathOutput, n)
+		b.l.Debugf("Writing release feed to %s", p)
+		if err = writeReleaseFeed(p, b.releaseVersion, notes, date, feeds[n]); err != nil {
+			err = fmt.Errorf("writing release feed %s failed: %w", p, err)
+			return
+		}
+	}
+
+	// Write appcast
+	if darwin := feeds[releaseFeedFileNameDarwin]; len(darwin) > 0 {
+		p := filepath.Join(b.pathOutput, releaseAppcastFileName)
+		b.l.Debugf("Writing Sparkle appcast to %s", p)
+		if err = b.writeAppcast(p, notes, date, darwin); err != nil {
+			err = fmt.Errorf("writing Sparkle appcast %s failed: %w", p, err)
+			return
+		}
+	}
+	b.l.Infof("Release %s feeds written to %s", b.releaseVersion, b.pathOutput)
+	return
+}
+
+// releaseArtifactPath returns the path of the file an environment is updated with
+func (b *Bundler) releaseArtifactPath(e ConfigurationEnvironment) string {
+	environmentPath := b.environmentPath(e)
+	switch e.OS {
+	case "darwin":
+		return filepath.Join(environmentPath, fmt.Sprintf("%s-%s-%s-%s.zip", b.appName, b.releaseVersion, e.OS, e.Arch))
+	case "windows":
+		return filepath.Join(environmentPath, b.appName+".exe")
+	}
+	return filepath.Join(environmentPath, b.appName)
+}
+
+// releaseArtifact zips the darwin app if needed and returns the file an environment is updated with
+func (b *Bundler) releaseArtifact(e ConfigurationEnvironment) (a releaseArtifact, err error) {
+	// Zip darwin app
+	a = releaseArtifact{e: e, path: b.releaseArtifactPath(e)}
+	a.name = filepath.Base(a.path)
+	if e.OS == "darwin" {
+		appPath := filepath.Join(b.environmentPath(e), b.appName+".app")
+		if _, err = os.Stat(appPath); err != nil {
+			err = fmt.Errorf("stating %s failed: %w", appPath, err)
+			return
+		}
+		b.l.Debugf("Zipping %s into %s", appPath, a.path)
+		if err = writeZip(a.path, appPath, true); err != nil {
+			err = fmt.Errorf("zipping %s into %s failed: %w", appPath, a.path, err)
+			return
+		}
+	}
+
+	// Stat
+	var fi os.FileInfo
+	if fi, err = os.Stat(a.path); err != nil {
+		err = fmt.Errorf("stating %s failed: %w", a.path, err)
+		return
+	}
+	a.size = fi.Size()
+
+	// Hash
+	h := sha512.New()
+	if err = hashFile(h, a.path); err != nil {
+		err = fmt.Errorf("hashing %s failed: %w", a.path, err)
+		return
+	}
+	a.sha512 = base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+	// Build URL
+	buf := &bytes.Buffer{}
+	if err = b.releaseBaseURL.Execute(buf, releaseURLData{
+		AppName: b.appName,
+		Arch:    e.Arch,
+		OS:      e.OS,
+		Version: b.releaseVersion,
+	}); err != nil {
+		err = fmt.Errorf("executing base url template failed: %w", err)
+		return
+	}
+	a.url = strings.TrimSuffix(buf.String(), "/") + "/" + url.PathEscape(a.name)
+	return
+}
+
+// releaseFeedName returns the name of the electron-updater style feed of an environment. Darwin and
+// windows environments share a single feed whereas linux ones have a feed per arch.
+func releaseFeedName(e ConfigurationEnvironment) string {
+	switch e.OS {
+	case "darwin":
+		return releaseFeedFileNameDarwin
+	case "windows":
+		return releaseFeedFileNameWindows
+	}
+	if a := electronArch(e.Arch); a != "x64" {
+		return strings.TrimSuffix(releaseFeedFileNameLinux, ".yml") + "-" + a + ".yml"
+	}
+	return releaseFeedFileNameLinux
+}
+
+// releaseFeed represents an electron-updater style feed
+type releaseFeed struct {
+	Version      string            `yaml:"version"`
+	Files        []releaseFeedFile `yaml:"files"`
+	Path         string            `yaml:"path"`
+	SHA512       string            `yaml:"sha512"`
+	ReleaseDate  string            `yaml:"releaseDate"`
+	ReleaseNotes string            `yaml:"releaseNotes,omitempty"`
+}
+
+type releaseFeedFile struct {
+	URL    string `yaml:"url"`
+	SHA512 string `yaml:"sha512"`
+	Size   int64  `yaml:"size"`
+}
+
+// writeReleaseFeed writes an electron-updater style feed. Top level path and sha512 are those of the
+// first file, as expected by older clients.
+func writeReleaseFeed(p, version, notes string, date time.Time, as []releaseArtifact) (err error) {
+	// Create feed
+	f := releaseFeed{
+		Path:         as[0].url,
+		ReleaseDate:  date.Format("2006-01-02T15:04:05.000Z"),
+		ReleaseNotes: notes,
+		SHA512:       as[0].sha512,
+		Version:      version,
+	}
+	for _, a := range as {
+		f.Files = append(f.Files, releaseFeedFile{
+			SHA512: a.sha512,
+			Size:   a.size,
+			URL:    a.url,
+		})
+	}
+
+	// Marshal
+	buf := &bytes.Buffer{}
+	e := yaml.NewEncoder(buf)
+	e.SetIndent(2)
+	if err = e.Encode(f); err != nil {
+		err = fmt.Errorf("marshaling failed: %w", err)
+		return
+	}
+
+	// Write
+	if err = ioutil.WriteFile(p, buf.Bytes(), 0644); err != nil {
+		err = fmt.Errorf("writing failed: %w", err)
+		return
+	}
+	return
+}
+
+// releaseFeedVersion returns the version of the update feeds written in an output directory
+func releaseFeedVersion(dir string) (v string, err error) {
+	// Glob feeds
+	var ps []string
+	if ps, err = filepath.Glob(filepath.Join(dir, "latest*.yml")); err != nil {
+		err = fmt.Errorf("globbing feeds failed: %w", err)
+		return
+	}
+
+	// Loop through feeds
+	for _, p := range ps {
+		// Read
+		var b []byte
+		if b, err = ioutil.ReadFile(p); err != nil {
+			err = fmt.Errorf("reading %s failed: %w", p, err)
+			return
+		}
+
+		// Unmarshal
+		var f releaseFeed
+		if err = yaml.Unmarshal(b, &f); err != nil {
+			err = fmt.Errorf("unmarshaling %s failed: %w", p, err)
+			return
+		}
+		if len(f.Version) > 0 {
+			v = f.Version
+			return
+		}
+	}
+	err = fmt.Errorf("no update feed with a version found in %s", dir)
+	return
+}
+
+// sparkleAppcast represents a Sparkle appcast
+type sparkleAppcast struct {
+	XMLName   xml.Name       `xml:"rss"`
+	Version   string         `xml:"version,attr"`
+	Namespace string         `xml:"xmlns:sparkle,attr"`
+	Channel   sparkleChannel `xml:"channel"`
+}
+
+type sparkleChannel struct {
+	Title string        `xml:"title"`
+	Items []sparkleItem `xml:"item"`
+}
+
+type sparkleItem struct {
+	Title                string           `xml:"title"`
+	PubDate              string           `xml:"pubDate"`
+	Version              string           `xml:"sparkle:version"`
+	ShortVersionString   string           `xml:"sparkle:shortVersionString"`
+	HardwareRequirements string           `xml:"sparkle:hardwareRequirements,omitempty"`
+	Description          *sparkleCDATA    `xml:"description,omitempty"`
+	Enclosure            sparkleEnclosure `xml:"enclosure"`
+}
+
+type sparkleCDATA struct {
+	Value string `xml:",cdata"`
+}
+
+type sparkleEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// writeAppcast writes a Sparkle appcast with an item per darwin environment. The bundle version
+// defaults to the release version and arm64 items require an arm64 hardware.
+func (b *Bundler) writeAppcast(p, notes string, date time.Time, as []releaseArtifact) (err error) {
+	// Get bundle version
+	version := infoPlistString(b.infoPlist, "CFBundleVersion")
+	if len(version) == 0 {
+		version = b.releaseVersion
+	}
+
+	// Create appcast
+	c := sparkleAppcast{
+		Channel:   sparkleChannel{Title: b.appName},
+		Namespace: sparkleNamespace,
+		Version:   "2.0",
+	}
+	for _, a := range as {
+		i := sparkleItem{
+			Enclosure: sparkleEnclosure{
+				Length: a.size,
+				Type:   "application/octet-stream",
+				URL:    a.url,
+			},
+			PubDate:            date.Format(time.RFC1123Z),
+			ShortVersionString: b.releaseVersion,
+			Title:              "Version " + b.releaseVersion,
+			Version:            version,
+		}
+		if a.e.Arch == "arm64" {
+			i.HardwareRequirements = "arm64"
+		}
+		if len(notes) > 0 {
+			i.Description = &sparkleCDATA{Value: notes}
+		}
+		c.Channel.Items = append(c.Channel.Items, i)
+	}
+
+	// Marshal
+	var o []byte
+	if o, err = xml.MarshalIndent(c, "", "  "); err != nil {
+		err = fmt.Errorf("marshaling failed: %w", err)
+		return
+	}
+
+	// Write
+	if err = ioutil.WriteFile(p, append(append([]byte(xml.Header), o...), '\n'), 0644); err != nil {
+		err = fmt.Errorf("writing failed: %w", err)
+		return
+	}
+	return
+}
@@ -0,0 +1,142 @@
+package astibundler
+
+import (
+	"archive/zip"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/xml"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRelease(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Artifacts
+	writeTestFile(t, dir, "output/darwin-amd64/App.app/Contents/MacOS/App", "darwin amd64")
+	writeTestFile(t, dir, "output/darwin-arm64/App.app/Contents/MacOS/App", "darwin arm64")
+	writeTestFile(t, dir, "output/linux-amd64/App", "linux amd64")
+	writeTestFile(t, dir, "output/linux-arm64/App", "linux arm64")
+	writeTestFile(t, dir, "output/windows-amd64/App.exe", "windows amd64")
+	writeTestFile(t, dir, "notes.md", "- Fixed ]]> bug\n")
+
+	b, err := New(&Configuration{
+		AppName: "App",
+		Environments: []ConfigurationEnvironment{
+			{Arch: "amd64", OS: "darwin"},
+			{Arch: "arm64", OS: "darwin"},
+			{Arch: "amd64", OS: "linux"},
+			{Arch: "arm64", OS: "linux"},
+			{Arch: "amd64", OS: "windows"},
+		},
+		InfoPlist:  map[string]interface{}{"CFBundleShortVersionString": "1.2.0", "CFBundleVersion": "120"},
+		OutputPath: filepath.Join(dir, "output"),
+		Release: ConfigurationRelease{
+			BaseURL:   "https://example.com/{{.AppName}}/{{.Version}}/{{.OS}}-{{.Arch}}/",
+			NotesPath: filepath.Join(dir, "notes.md"),
+		},
+		WorkingDirectoryPath: filepath.Join(dir, "wd"),
+	}, log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, b.Release())
+
+	// Darwin zip
+	r, err := zip.OpenReader(filepath.Join(dir, "output/darwin-arm64/App-1.2.0-darwin-arm64.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	r.Close()
+	assert.Contains(t, names, "App.app/Contents/MacOS/App")
+
+	// Feeds
+	sha := func(p string) string {
+		h := sha512.New()
+		if err := hashFile(h, filepath.Join(dir, p)); err != nil {
+			t.Fatal(err)
+		}
+		return base64.StdEncoding.EncodeToString(h.Sum(nil))
+	}
+	size := func(p string) int64 {
+		fi, err := os.Stat(filepath.Join(dir, p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fi.Size()
+	}
+	for n, ps := range map[string][]string{
+		"latest-mac.yml":         {"output/darwin-amd64/App-1.2.0-darwin-amd64.zip", "output/darwin-arm64/App-1.2.0-darwin-arm64.zip"},
+		"latest-linux.yml":       {"output/linux-amd64/App"},
+		"latest-linux-arm64.yml": {"output/linux-arm64/App"},
+		"latest.yml":             {"output/windows-amd64/App.exe"},
+	} {
+		bs, err := ioutil.ReadFile(filepath.Join(dir, "output", n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var f releaseFeed
+		if err = yaml.Unmarshal(bs, &f); err != nil {
+			t.Fatal(err)
+		}
+		var fs []releaseFeedFile
+		for _, p := range ps {
+			fs = append(fs, releaseFeedFile{
+				SHA512: sha(p),
+				Size:   size(p),
+				URL:    "https://example.com/App/1.2.0/" + filepath.Base(filepath.Dir(p)) + "/" + filepath.Base(p),
+			})
+		}
+		assert.Equal(t, fs, f.Files, n)
+		assert.Equal(t, "1.2.0", f.Version)
+		assert.Equal(t, fs[0].URL, f.Path)
+		assert.Equal(t, fs[0].SHA512, f.SHA512)
+		assert.Equal(t, "- Fixed ]]> bug", f.ReleaseNotes)
+		assert.NotEmpty(t, f.ReleaseDate)
+	}
+
+	// Appcast
+	bs, err := ioutil.ReadFile(filepath.Join(dir, "output", "appcast.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c struct {
+		Items []struct {
+			Description          string `xml:"description"`
+			HardwareRequirements string `xml:"hardwareRequirements"`
+			ShortVersionString   string `xml:"shortVersionString"`
+			Version              string `xml:"version"`
+			Enclosure            struct {
+				Length int64  `xml:"length,attr"`
+				URL    string `xml:"url,attr"`
+			} `xml:"enclosure"`
+		} `xml:"channel>item"`
+	}
+	if err = xml.Unmarshal(bs, &c); err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(bs), `xmlns:sparkle="http://www.andymatuschak.org/xml-namespaces/sparkle"`)
+	if assert.Len(t, c.Items, 2) {
+		assert.Equal(t, "", c.Items[0].HardwareRequirements)
+		assert.Equal(t, "arm64", c.Items[1].HardwareRequirements)
+		assert.Equal(t, "- Fixed ]]> bug", c.Items[1].Description)
+		assert.Equal(t, "1.2.0", c.Items[1].ShortVersionString)
+		assert.Equal(t, "120", c.Items[1].Version)
+		assert.Equal(t, size("output/darwin-arm64/App-1.2.0-darwin-arm64.zip"), c.Items[1].Enclosure.Length)
+		assert.Equal(t, "https://example.com/App/1.2.0/darwin-arm64/App-1.2.0-darwin-arm64.zip", c.Items[1].Enclosure.URL)
+	}
+}
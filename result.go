@@ -0,0 +1,132 @@
+package astibundler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// BundleResult represents the files of the output path
+type BundleResult struct {
+	Artifacts  []BundleArtifact
+	OutputPath string
+}
+
+// BundleArtifact represents a file of the output path
+type BundleArtifact struct {
+	// The environment the file or archive belongs to, or nil for files shared by environments such as
+	// SHA256SUMS, update feeds or patches
+	Environment *ConfigurationEnvironment
+	// Path relative to the output path, using slashes
+	Path   string
+	SHA256 string
+	Size   int64
+}
+
+// Result returns the files of the output path: the files of the environment output directories, the
+// files written at the root of the output path such as environment archives, SHA256SUMS and update
+// feeds, and patches
+func (b *Bundler) Result() (r BundleResult, err error) {
+	// Loop through environments
+	r.OutputPath = b.pathOutput
+	for idx := range b.environments {
+		e := b.environments[idx]
+		if err = r.addDir(b.environmentPath(e), &e); err != nil {
+			err = fmt.Errorf("adding files of environment %s/%s failed: %w", e.OS, e.Arch, err)
+			return
+		}
+	}
+
+	// Add patches
+	if err = r.addDir(filepath.Join(b.pathOutput, "patches"), nil); err != nil {
+		err = fmt.Errorf("adding patches failed: %w", err)
+		return
+	}
+
+	// Index archives
+	archives := make(map[string]*ConfigurationEnvironment)
+	for idx := range b.environments {
+		if b.archiveName == nil {
+			break
+		}
+		var p string
+		if p, err = b.archivePath(b.environments[idx]); err != nil {
+			err = fmt.Errorf("getting archive path failed: %w", err)
+			return
+		}
+		archives[p] = &b.environments[idx]
+	}
+
+	// Add root files
+	var fis []os.FileInfo
+	if fis, err = ioutil.ReadDir(b.pathOutput); err != nil {
+		err = fmt.Errorf("reading dir %s failed: %w", b.pathOutput, err)
+		return
+	}
+	for _, fi := range fis {
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		p := filepath.Join(b.pathOutput, fi.Name())
+		if err = r.addFile(p, archives[p]); err != nil {
+			err = fmt.Errorf("adding %s failed: %w", fi.Name(), err)
+			return
+		}
+	}
+	return
+}
+
+// addDir adds the files of a dir to the result
+func (r *BundleResult) addDir(dir string, e *ConfigurationEnvironment) (err error) {
+	// Dir doesn't exist
+	if _, err = os.Stat(dir); os.IsNotExist(err) {
+		err = nil
+		return
+	}
+
+	// List files
+	var ps []string
+	if ps, err = listFiles(dir, func(p string, fi os.FileInfo) bool { return true }); err != nil {
+		err = fmt.Errorf("listing files of %s failed: %w", dir, err)
+		return
+	}
+
+	// Loop through files
+	for _, p := range ps {
+		if err = r.addFile(p, e); err != nil {
+			err = fmt.Errorf("adding %s failed: %w", p, err)
+			return
+		}
+	}
+	return
+}
+
+// addFile adds a file to the result
+func (r *BundleResult) addFile(p string, e *ConfigurationEnvironment) (err error) {
+	// Stat
+	var fi os.FileInfo
+	if fi, err = os.Stat(p); err != nil {
+		err = fmt.Errorf("stating %s failed: %w", p, err)
+		return
+	}
+
+	// Hash
+	h := sha256.New()
+	if err = hashFile(h, p); err != nil {
+		err = fmt.Errorf("hashing %s failed: %w", p, err)
+		return
+	}
+
+	// Append
+	rp, _ := filepath.Rel(r.OutputPath, p)
+	r.Artifacts = append(r.Artifacts, BundleArtifact{
+		Environment: e,
+		Path:        filepath.ToSlash(rp),
+		SHA256:      hex.EncodeToString(h.Sum(nil)),
+		Size:        fi.Size(),
+	})
+	return
+}
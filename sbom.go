@@ -0,0 +1,508 @@
+package astibundler
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/asticode/go-astilectron"
+)
+
+// SBOM file names
+const (
+	sbomCycloneDXFileName = "sbom.cdx.json"
+	sbomSPDXFileName      = "sbom.spdx.json"
+)
+
+// SBOM component types
+const (
+	sbomComponentTypeApplication = "application"
+	sbomComponentTypeFile        = "file"
+	sbomComponentTypeFramework   = "framework"
+	sbomComponentTypeLibrary     = "library"
+)
+
+// sbom represents what an environment has been bundled with, independently of the SBOM format
+type sbom struct {
+	app        sbomComponent
+	components []sbomComponent
+	createdAt  time.Time
+	goVersion  string
+	id         string
+}
+
+type sbomComponent struct {
+	downloadURL string
+	goSum       string
+	name        string
+	purl        string
+	sha1        string
+	sha256      string
+	typ         string
+	version     string
+}
+
+// goModule represents a module listed in the build info of a go binary
+type goModule struct {
+	path    string
+	replace *goModule
+	sum     string
+	version string
+}
+
+// parseGoBuildInfo parses the output of "go version -m"
+func parseGoBuildInfo(o []byte) (goVersion string, main goModule, deps []goModule) {
+	s := bufio.NewScanner(bytes.NewReader(o))
+	for s.Scan() {
+		// First line is "<path>: <go version>"
+		ss := strings.Split(strings.TrimSpace(s.Text()), "\t")
+		if !strings.HasPrefix(s.Text(), "\t") {
+			if idx := strings.LastIndex(s.Text(), ": "); idx >= 0 {
+				goVersion = strings.TrimSpace(s.Text()[idx+2:])
+			}
+			continue
+		}
+
+		// Parse module
+		var m goModule
+		if len(ss) > 1 {
+			m.path = ss[1]
+		}
+		if len(ss) > 2 {
+			m.version = ss[2]
+		}
+		if len(ss) > 3 {
+			m.sum = ss[3]
+		}
+
+		// Switch on kind
+		switch ss[0] {
+		case "mod":
+			main = m
+		case "dep":
+			deps = append(deps, m)
+		case "=>":
+			if len(deps) > 0 {
+				deps[len(deps)-1].replace = &m
+			}
+		}
+	}
+	return
+}
+
+// goModuleComponent returns the component of a module, taking replacements into account. Modules replaced
+// by a local dir have no version.
+func goModuleComponent(m goModule) sbomComponent {
+	if m.replace != nil {
+		if m.replace.version != "" && m.replace.version != "(devel)" {
+			m = *m.replace
+		} else {
+			m = goModule{path: m.path, version: "(devel)"}
+		}
+	}
+	c := sbomComponent{
+		goSum:   m.sum,
+		name:    m.path,
+		typ:     sbomComponentTypeLibrary,
+		version: m.version,
+	}
+	if m.version != "" && m.version != "(devel)" {
+		c.purl = "pkg:golang/" + m.path + "@" + m.version
+	}
+	return c
+}
+
+// newSBOM gathers what an environment has been bundled with: the go modules listed in the binary build
+// info, the astilectron and electron vendor zips and the bound files
+func (b *Bundler) newSBOM(e ConfigurationEnvironment, binaryPath string) (s sbom, err error) {
+	// Init
+	s = sbom{
+		app: sbomComponent{
+			name: b.appName,
+			typ:  sbomComponentTypeApplication,
+		},
+		createdAt: time.Now().UTC(),
+	}
+
+	// Generate id
+	id := make([]byte, 16)
+	if _, err = io.ReadFull(rand.Reader, id); err != nil {
+		err = fmt.Errorf("generating id failed: %w", err)
+		return
+	}
+	id[6] = (id[6] & 0x0f) | 0x40
+	id[8] = (id[8] & 0x3f) | 0x80
+	s.id = fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:])
+
+	// Read build info
+	var o []byte
+	if o, err = exec.Command(b.pathGoBinary, "version", "-m", binaryPath).Output(); err != nil {
+		err = fmt.Errorf("reading build info of %s failed: %w", binaryPath, err)
+		return
+	}
+	var main goModule
+	var deps []goModule
+	s.goVersion, main, deps = parseGoBuildInfo(o)
+	if main.version != "" && main.version != "(devel)" {
+		s.app.version = main.version
+	}
+	for _, d := range deps {
+		s.components = append(s.components, goModuleComponent(d))
+	}
+
+	// Vendor
+	for _, v := range []struct {
+		name        string
+		path        string
+		purl        string
+		downloadURL string
+		version     string
+	}{
+		{
+			downloadURL: astilectron.AstilectronDownloadSrc(b.versionAstilectron),
+			name:        "astilectron",
+			path:        b.astilectronCachePath(),
+			purl:        "pkg:github/asticode/astilectron@v" + b.versionAstilectron,
+			version:     b.versionAstilectron,
+		},
+		{
+			downloadURL: astilectron.ElectronDownloadSrc(e.OS, e.Arch, b.versionElectron),
+			name:        "electron",
+			path:        b.electronCachePath(e.OS, e.Arch),
+			purl:        "pkg:github/electron/electron@v" + b.versionElectron,
+			version:     b.versionElectron,
+		},
+	} {
+		c := sbomComponent{
+			downloadURL: v.downloadURL,
+			name:        v.name,
+			purl:        v.purl,
+			typ:         sbomComponentTypeFramework,
+			version:     v.version,
+		}
+		if len(b.pathAstilectron) > 0 && v.name == "astilectron" {
+			c.downloadURL = ""
+		}
+		if c.sha1, c.sha256, err = sbomFileHashes(v.path); err != nil {
+			err = fmt.Errorf("hashing %s failed: %w", v.path, err)
+			return
+		}
+		s.components = append(s.components, c)
+	}
+
+	// Bound files
+	var ps []string
+	if ps, err = listFiles(b.pathBindInput, func(p string, fi os.FileInfo) bool { return p != b.pathVendor }); err != nil {
+		err = fmt.Errorf("listing files of %s failed: %w", b.pathBindInput, err)
+		return
+	}
+	for _, p := range ps {
+		rp, _ := filepath.Rel(b.pathBindInput, p)
+		c := sbomComponent{
+			name: filepath.ToSlash(rp),
+			typ:  sbomComponentTypeFile,
+		}
+		if c.sha1, c.sha256, err = sbomFileHashes(p); err != nil {
+			err = fmt.Errorf("hashing %s failed: %w", p, err)
+			return
+		}
+		s.components = append(s.components, c)
+	}
+	return
+}
+
+func sbomFileHashes(p string) (s1, s256 string, err error) {
+	h1 := sha1.New()
+	h256 := sha256.New()
+	if err = hashFile(io.MultiWriter(h1, h256), p); err != nil {
+		return
+	}
+	s1 = hex.EncodeToString(h1.Sum(nil))
+	s256 = hex.EncodeToString(h256.Sum(nil))
+	return
+}
+
+// writeSBOM writes the SBOM documents of an environment in its output dir
+func (b *Bundler) writeSBOM(e ConfigurationEnvironment, binaryPath string) (err error) {
+	// Gather
+	var s sbom
+	if s, err = b.newSBOM(e, binaryPath); err != nil {
+		err = fmt.Errorf("gathering SBOM failed: %w", err)
+		return
+	}
+
+	// Loop through formats
+	for _, f := range []struct {
+		enabled bool
+		name    string
+		fn      func(s sbom, e ConfigurationEnvironment) interface{}
+	}{
+		{enabled: true, name: sbomCycloneDXFileName, fn: sbomCycloneDX},
+		{enabled: b.sbomSPDX, name: sbomSPDXFileName, fn: sbomSPDX},
+	} {
+		// Format is disabled
+		if !f.enabled {
+			continue
+		}
+
+		// Marshal
+		var bs []byte
+		if bs, err = json.MarshalIndent(f.fn(s, e), "", "  "); err != nil {
+			err = fmt.Errorf("marshaling %s failed: %w", f.name, err)
+			return
+		}
+
+		// Write
+		p := filepath.Join(b.environmentPath(e), f.name)
+		b.l.Debugf("Writing SBOM to %s", p)
+		if err = ioutil.WriteFile(p, append(bs, '\n'), 0644); err != nil {
+			err = fmt.Errorf("writing %s failed: %w", p, err)
+			return
+		}
+	}
+	return
+}
+
+type cycloneDXBOM struct {
+	BOMFormat    string                `json:"bomFormat"`
+	Components   []cycloneDXComponent  `json:"components"`
+	Dependencies []cycloneDXDependency `json:"dependencies"`
+	Metadata     cycloneDXMetadata     `json:"metadata"`
+	SerialNumber string                `json:"serialNumber"`
+	SpecVersion  string                `json:"specVersion"`
+	Version      int                   `json:"version"`
+}
+
+type cycloneDXMetadata struct {
+	Component  cycloneDXComponent  `json:"component"`
+	Properties []cycloneDXProperty `json:"properties"`
+	Timestamp  string              `json:"timestamp"`
+	Tools      []cycloneDXTool     `json:"tools"`
+}
+
+type cycloneDXTool struct {
+	Name   string `json:"name"`
+	Vendor string `json:"vendor"`
+}
+
+type cycloneDXComponent struct {
+	BOMRef             string                       `json:"bom-ref"`
+	ExternalReferences []cycloneDXExternalReference `json:"externalReferences,omitempty"`
+	Hashes             []cycloneDXHash              `json:"hashes,omitempty"`
+	Name               string                       `json:"name"`
+	Properties         []cycloneDXProperty          `json:"properties,omitempty"`
+	PURL               string                       `json:"purl,omitempty"`
+	Type               string                       `json:"type"`
+	Version            string                       `json:"version,omitempty"`
+}
+
+type cycloneDXExternalReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type cycloneDXHash struct {
+	Algorithm string `json:"alg"`
+	Content   string `json:"content"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cycloneDXDependency struct {
+	DependsOn []string `json:"dependsOn"`
+	Ref       string   `json:"ref"`
+}
+
+// sbomCycloneDX returns the CycloneDX 1.4 version of the SBOM
+func sbomCycloneDX(s sbom, e ConfigurationEnvironment) interface{} {
+	// Convert components
+	convert := func(c sbomComponent, idx int) (o cycloneDXComponent) {
+		o = cycloneDXComponent{
+			BOMRef:  fmt.Sprintf("%s-%d", c.typ, idx),
+			Name:    c.name,
+			PURL:    c.purl,
+			Type:    c.typ,
+			Version: c.version,
+		}
+		if c.purl != "" {
+			o.BOMRef = c.purl
+		}
+		if c.sha256 != "" {
+			o.Hashes = []cycloneDXHash{{Algorithm: "SHA-256", Content: c.sha256}}
+		}
+		if c.downloadURL != "" {
+			o.ExternalReferences = []cycloneDXExternalReference{{Type: "distribution", URL: c.downloadURL}}
+		}
+		if c.goSum != "" {
+			o.Properties = []cycloneDXProperty{{Name: "go:sum", Value: c.goSum}}
+		}
+		return
+	}
+	app := convert(s.app, 0)
+	d := cycloneDXDependency{DependsOn: []string{}, Ref: app.BOMRef}
+	cs := []cycloneDXComponent{}
+	for idx, c := range s.components {
+		o := convert(c, idx)
+		cs = append(cs, o)
+		d.DependsOn = append(d.DependsOn, o.BOMRef)
+	}
+
+	return cycloneDXBOM{
+		BOMFormat:    "CycloneDX",
+		Components:   cs,
+		Dependencies: []cycloneDXDependency{d},
+		Metadata: cycloneDXMetadata{
+			Component: app,
+			Properties: []cycloneDXProperty{
+				{Name: "arch", Value: e.Arch},
+				{Name: "go:version", Value: s.goVersion},
+				{Name: "os", Value: e.OS},
+			},
+			Timestamp: s.createdAt.Format(time.RFC3339),
+			Tools:     []cycloneDXTool{{Name: "astilectron-bundler", Vendor: "asticode"}},
+		},
+		SerialNumber: "urn:uuid:" + s.id,
+		SpecVersion:  "1.4",
+		Version:      1,
+	}
+}
+
+type spdxDocument struct {
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	DataLicense       string             `json:"dataLicense"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	Files             []spdxFile         `json:"files"`
+	Name              string             `json:"name"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+	SPDXID            string             `json:"SPDXID"`
+	SPDXVersion       string             `json:"spdxVersion"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceLocator  string `json:"referenceLocator"`
+	ReferenceType     string `json:"referenceType"`
+}
+
+type spdxFile struct {
+	Checksums        []spdxChecksum `json:"checksums"`
+	CopyrightText    string         `json:"copyrightText"`
+	FileName         string         `json:"fileName"`
+	LicenseConcluded string         `json:"licenseConcluded"`
+	SPDXID           string         `json:"SPDXID"`
+}
+
+type spdxPackage struct {
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	CopyrightText    string            `json:"copyrightText"`
+	DownloadLocation string            `json:"downloadLocation"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	Name             string            `json:"name"`
+	PrimaryPurpose   string            `json:"primaryPackagePurpose,omitempty"`
+	SPDXID           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+}
+
+type spdxRelationship struct {
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+	RelationshipType   string `json:"relationshipType"`
+	SPDXElementID      string `json:"spdxElementId"`
+}
+
+// sbomSPDX returns the SPDX 2.3 version of the SBOM
+func sbomSPDX(s sbom, e ConfigurationEnvironment) interface{} {
+	// Init
+	name := fmt.Sprintf("%s-%s-%s", s.app.name, e.OS, e.Arch)
+	d := spdxDocument{
+		CreationInfo: spdxCreationInfo{
+			Created:  s.createdAt.Format(time.RFC3339),
+			Creators: []string{"Tool: astilectron-bundler"},
+		},
+		DataLicense:       "CC0-1.0",
+		DocumentNamespace: "https://github.com/asticode/go-astilectron-bundler/spdx/" + name + "-" + s.id,
+		Files:             []spdxFile{},
+		Name:              name,
+		SPDXID:            "SPDXRef-DOCUMENT",
+		SPDXVersion:       "SPDX-2.3",
+	}
+
+	// Convert packages
+	convert := func(c sbomComponent, id string) (o spdxPackage) {
+		o = spdxPackage{
+			CopyrightText:    "NOASSERTION",
+			DownloadLocation: "NOASSERTION",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			Name:             c.name,
+			PrimaryPurpose:   strings.ToUpper(c.typ),
+			SPDXID:           id,
+			VersionInfo:      c.version,
+		}
+		if c.downloadURL != "" {
+			o.DownloadLocation = c.downloadURL
+		}
+		if c.sha256 != "" {
+			o.Checksums = []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: c.sha256}}
+		}
+		if c.purl != "" {
+			o.ExternalRefs = []spdxExternalRef{{ReferenceCategory: "PACKAGE-MANAGER", ReferenceLocator: c.purl, ReferenceType: "purl"}}
+		}
+		return
+	}
+	app := convert(s.app, "SPDXRef-Package-App")
+	d.Packages = append(d.Packages, app)
+	d.Relationships = append(d.Relationships, spdxRelationship{RelatedSPDXElement: app.SPDXID, RelationshipType: "DESCRIBES", SPDXElementID: d.SPDXID})
+	for idx, c := range s.components {
+		// File
+		if c.typ == sbomComponentTypeFile {
+			f := spdxFile{
+				Checksums: []spdxChecksum{
+					{Algorithm: "SHA1", ChecksumValue: c.sha1},
+					{Algorithm: "SHA256", ChecksumValue: c.sha256},
+				},
+				CopyrightText:    "NOASSERTION",
+				FileName:         "./" + c.name,
+				LicenseConcluded: "NOASSERTION",
+				SPDXID:           fmt.Sprintf("SPDXRef-File-%d", idx),
+			}
+			d.Files = append(d.Files, f)
+			d.Relationships = append(d.Relationships, spdxRelationship{RelatedSPDXElement: f.SPDXID, RelationshipType: "CONTAINS", SPDXElementID: app.SPDXID})
+			continue
+		}
+
+		// Package
+		p := convert(c, fmt.Sprintf("SPDXRef-Package-%d", idx))
+		d.Packages = append(d.Packages, p)
+		d.Relationships = append(d.Relationships, spdxRelationship{RelatedSPDXElement: p.SPDXID, RelationshipType: "DEPENDS_ON", SPDXElementID: app.SPDXID})
+	}
+	return d
+}
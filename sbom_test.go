@@ -0,0 +1,105 @@
+package astibundler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGoBuildInfo(t *testing.T) {
+	v, m, ds := parseGoBuildInfo([]byte(`/tmp/app/output/linux-amd64/App: go1.21.0
+	path	app
+	mod	app	(devel)	
+	dep	github.com/asticode/go-astikit	v0.15.0	h1:abc=
+	dep	github.com/asticode/go-astilectron	v0.25.0
+	=>	../go-astilectron	(devel)	
+	dep	golang.org/x/text	v0.3.0	h1:def=
+	=>	golang.org/x/text	v0.3.7	h1:ghi=
+	build	-ldflags="-X main.AppName=App"
+`))
+	assert.Equal(t, "go1.21.0", v)
+	assert.Equal(t, goModule{path: "app", version: "(devel)"}, m)
+	assert.Len(t, ds, 3)
+	assert.Equal(t, sbomComponent{goSum: "h1:abc=", name: "github.com/asticode/go-astikit", purl: "pkg:golang/github.com/asticode/go-astikit@v0.15.0", typ: "library", version: "v0.15.0"}, goModuleComponent(ds[0]))
+	assert.Equal(t, sbomComponent{name: "github.com/asticode/go-astilectron", typ: "library", version: "(devel)"}, goModuleComponent(ds[1]))
+	assert.Equal(t, sbomComponent{goSum: "h1:ghi=", name: "golang.org/x/text", purl: "pkg:golang/golang.org/x/text@v0.3.7", typ: "library", version: "v0.3.7"}, goModuleComponent(ds[2]))
+}
+
+func TestWriteSBOM(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	e := ConfigurationEnvironment{Arch: "amd64", OS: "linux"}
+	b, err := New(&Configuration{
+		AppName:              "App",
+		OutputPath:           filepath.Join(dir, "output"),
+		SBOM:                 ConfigurationSBOM{Enabled: true, SPDX: true},
+		VersionAstilectron:   "0.49.0",
+		VersionElectron:      "11.4.3",
+		WorkingDirectoryPath: filepath.Join(dir, "wd"),
+	}, log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Dir(b.astilectronCachePath()), filepath.Base(b.astilectronCachePath()), "astilectron")
+	writeTestFile(t, filepath.Dir(b.electronCachePath(e.OS, e.Arch)), filepath.Base(b.electronCachePath(e.OS, e.Arch)), "electron")
+	writeTestFile(t, dir, "wd/bind/resources/index.html", "<html>")
+	writeTestFile(t, dir, "wd/bind/vendor_astilectron_bundler/electron.zip", "electron")
+	if err = os.MkdirAll(filepath.Join(dir, "output", "linux-amd64"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// The test binary has build info
+	bp, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, b.writeSBOM(e, bp))
+
+	// CycloneDX
+	var c cycloneDXBOM
+	bs, err := ioutil.ReadFile(filepath.Join(dir, "output", "linux-amd64", sbomCycloneDXFileName))
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(bs, &c))
+	assert.Equal(t, "CycloneDX", c.BOMFormat)
+	assert.Equal(t, "App", c.Metadata.Component.Name)
+	cs := make(map[string]cycloneDXComponent)
+	for _, v := range c.Components {
+		cs[v.Name] = v
+	}
+	assert.Equal(t, "pkg:github/electron/electron@v11.4.3", cs["electron"].PURL)
+	assert.Equal(t, []cycloneDXHash{{Algorithm: "SHA-256", Content: "88b1deea6a18cfd0d1df1f0048d0946332fe61d1adc2bacb25e5f6a774b56cc5"}}, cs["electron"].Hashes)
+	assert.Equal(t, "0.49.0", cs["astilectron"].Version)
+	assert.Equal(t, "file", cs["resources/index.html"].Type)
+	assert.Equal(t, "library", cs["github.com/stretchr/testify"].Type)
+	_, ok := cs["vendor_astilectron_bundler/electron.zip"]
+	assert.False(t, ok)
+	assert.Len(t, c.Dependencies, 1)
+	assert.Len(t, c.Dependencies[0].DependsOn, len(c.Components))
+
+	// SPDX
+	var s spdxDocument
+	bs, err = ioutil.ReadFile(filepath.Join(dir, "output", "linux-amd64", sbomSPDXFileName))
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(bs, &s))
+	assert.Equal(t, "SPDX-2.3", s.SPDXVersion)
+	assert.Len(t, s.Files, 1)
+	assert.Equal(t, "./resources/index.html", s.Files[0].FileName)
+	assert.Equal(t, len(c.Components)+1, len(s.Files)+len(s.Packages))
+
+	// SBOMs are opt-in
+	assert.Contains(t, b.artifacts(e), filepath.Join(dir, "output", "linux-amd64", sbomSPDXFileName))
+	b, err = New(&Configuration{OutputPath: filepath.Join(dir, "output")}, log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotContains(t, b.artifacts(e), filepath.Join(dir, "output", "linux-amd64", sbomCycloneDXFileName))
+}
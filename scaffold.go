@@ -0,0 +1,385 @@
+package astibundler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// ScaffoldOptions represents scaffold options
+type ScaffoldOptions struct {
+	// Configuration format. Defaults to "yaml"
+	Format string
+	// The path of the project. Defaults to the current directory
+	InputPath string
+	// Whether existing configuration files should be overwritten. Configuration files with another
+	// format are removed.
+	Overwrite bool
+}
+
+// Scaffold inspects a project and writes a starter configuration in it. It returns the path of the
+// configuration file.
+func Scaffold(o ScaffoldOptions) (p string, err error) {
+	// Format
+	if o.Format == "" {
+		o.Format = ConfigurationFormatYAML
+	}
+	var ext string
+	switch o.Format {
+	case ConfigurationFormatJSON, ConfigurationFormatTOML, ConfigurationFormatYAML:
+		ext = o.Format
+	default:
+		err = fmt.Errorf("format %s is invalid", o.Format)
+		return
+	}
+
+	// Input path
+	var dir string
+	if dir, err = absPath(o.InputPath, os.Getwd); err != nil {
+		return
+	}
+
+	// Make sure no configuration file exists since it would shadow or be shadowed by the new one
+	p = filepath.Join(dir, "bundler."+ext)
+	var existing []string
+	for _, n := range DefaultConfigurationNames {
+		if _, errStat := os.Stat(filepath.Join(dir, n)); errStat == nil {
+			existing = append(existing, filepath.Join(dir, n))
+		}
+	}
+	if len(existing) > 0 && !o.Overwrite {
+		err = fmt.Errorf("%s already exists, use overwrite to overwrite it", strings.Join(existing, ", "))
+		return
+	}
+
+	// Inspect project
+	var s *scaffold
+	if s, err = inspectProject(dir); err != nil {
+		err = fmt.Errorf("inspecting project %s failed: %w", dir, err)
+		return
+	}
+
+	// Render
+	var b []byte
+	switch o.Format {
+	case ConfigurationFormatJSON:
+		b, err = s.renderJSON()
+	case ConfigurationFormatTOML:
+		b = s.renderTOML()
+	case ConfigurationFormatYAML:
+		b = s.renderYAML()
+	}
+	if err != nil {
+		err = fmt.Errorf("rendering %s configuration failed: %w", o.Format, err)
+		return
+	}
+
+	// Remove other configuration files
+	for _, e := range existing {
+		if e == p {
+			continue
+		}
+		if err = os.Remove(e); err != nil {
+			err = fmt.Errorf("removing %s failed: %w", e, err)
+			return
+		}
+	}
+
+	// Write
+	if err = ioutil.WriteFile(p, b, 0644); err != nil {
+		err = fmt.Errorf("writing %s failed: %w", p, err)
+		return
+	}
+	return
+}
+
+// scaffold represents the starter configuration of a project
+type scaffold struct {
+	bind         []scaffoldEntry
+	environments []ConfigurationEnvironment
+	root         []scaffoldEntry
+}
+
+// scaffoldEntry represents a key of the starter configuration. Disabled entries are only written as
+// comments.
+type scaffoldEntry struct {
+	comment  string
+	disabled bool
+	key      string
+	value    string
+}
+
+var (
+	scaffoldBindFileRegexp  = regexp.MustCompile(`^bind_[a-z0-9]+_[a-z0-9]+\.go$`)
+	scaffoldModuleRegexp    = regexp.MustCompile(`^module\s+"?([^"\s]+)"?`)
+	scaffoldSkippedDirNames = map[string]bool{"node_modules": true, "output": true, "vendor": true}
+)
+
+// inspectProject inspects a project to guess its starter configuration
+func inspectProject(dir string) (s *scaffold, err error) {
+	// Walk
+	var bindDirs, mainDirs, icnsPaths, icoPaths, pngPaths []string
+	if err = filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		// Check error
+		if err != nil {
+			return err
+		}
+
+		// Skip hidden and irrelevant dirs
+		if fi.IsDir() {
+			if p != dir && (strings.HasPrefix(fi.Name(), ".") || scaffoldSkippedDirNames[fi.Name()]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Get relative path
+		rp, err := filepath.Rel(dir, p)
+		if err != nil {
+			return fmt.Errorf("getting relative path of %s failed: %w", p, err)
+		}
+		rp = filepath.ToSlash(rp)
+
+		// Switch on extension
+		switch strings.ToLower(filepath.Ext(p)) {
+		case ".go":
+			if scaffoldBindFileRegexp.MatchString(fi.Name()) {
+				bindDirs = append(bindDirs, path.Dir(rp))
+			} else if !strings.HasSuffix(fi.Name(), "_test.go") && isMainPackageFile(p) {
+				mainDirs = append(mainDirs, path.Dir(rp))
+			}
+		case ".icns":
+			icnsPaths = append(icnsPaths, rp)
+		case ".ico":
+			icoPaths = append(icoPaths, rp)
+		case ".png":
+			if strings.Contains(strings.ToLower(fi.Name()), "icon") {
+				pngPaths = append(pngPaths, rp)
+			}
+		}
+		return nil
+	}); err != nil {
+		err = fmt.Errorf("walking through %s failed: %w", dir, err)
+		return
+	}
+
+	// Get module path
+	var modulePath string
+	if modulePath, err = readModulePath(filepath.Join(dir, "go.mod")); err != nil {
+		err = fmt.Errorf("reading module path failed: %w", err)
+		return
+	}
+
+	// App name
+	s = &scaffold{environments: []ConfigurationEnvironment{{Arch: runtime.GOARCH, OS: runtime.GOOS}}}
+	appName := filepath.Base(dir)
+	if modulePath != "" {
+		appName = path.Base(modulePath)
+	}
+	s.root = append(s.root, scaffoldEntry{comment: "The app name as it should be displayed everywhere", key: "app_name", value: appName})
+
+	// Main package
+	mainDir := firstScaffoldPath(mainDirs)
+	s.root = append(s.root, scaffoldEntry{
+		comment:  "The path of the project, it must contain the main package",
+		disabled: mainDir == "" || mainDir == ".",
+		key:      "input_path",
+		value:    defaultScaffoldValue(mainDir, "path/to/main/package"),
+	})
+
+	// Icons
+	s.root = append(s.root,
+		scaffoldEntry{comment: "Darwin icon (.icns)", disabled: len(icnsPaths) == 0, key: "icon_path_darwin", value: defaultScaffoldValue(firstScaffoldPath(icnsPaths), "path/to/icon.icns")},
+		scaffoldEntry{comment: "Linux icon (.png)", disabled: len(pngPaths) == 0, key: "icon_path_linux", value: defaultScaffoldValue(firstScaffoldPath(pngPaths), "path/to/icon.png")},
+		scaffoldEntry{comment: "Windows icon (.ico)", disabled: len(icoPaths) == 0, key: "icon_path_windows", value: defaultScaffoldValue(firstScaffoldPath(icoPaths), "path/to/icon.ico")},
+	)
+
+	// Resources
+	resourcesPath := "resources"
+	if mainDir != "" && mainDir != "." {
+		resourcesPath = path.Join(mainDir, resourcesPath)
+	}
+	_, errStat := os.Stat(filepath.Join(dir, filepath.FromSlash(resourcesPath)))
+	s.root = append(s.root, scaffoldEntry{
+		comment:  "The path where the resources are, relative to the input path",
+		disabled: errStat != nil,
+		key:      "resources_path",
+		value:    "resources",
+	})
+
+	// Bind
+	if bindDir := firstScaffoldPath(bindDirs); bindDir != "" && bindDir != mainDir {
+		var pkg string
+		if pkg, err = readPackageName(filepath.Join(dir, filepath.FromSlash(bindDir))); err != nil {
+			err = fmt.Errorf("reading package name failed: %w", err)
+			return
+		}
+		s.bind = append(s.bind,
+			scaffoldEntry{comment: "The path where the bind files are written", key: "output_path", value: bindDir},
+			scaffoldEntry{comment: "The package of the bind files", key: "package", value: pkg},
+		)
+	}
+	return
+}
+
+// firstScaffoldPath returns the shallowest path, ties are broken alphabetically
+func firstScaffoldPath(ps []string) string {
+	if len(ps) == 0 {
+		return ""
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		if di, dj := strings.Count(ps[i], "/"), strings.Count(ps[j], "/"); di != dj {
+			return di < dj
+		}
+		return ps[i] < ps[j]
+	})
+	return ps[0]
+}
+
+func defaultScaffoldValue(v, d string) string {
+	if v == "" {
+		return d
+	}
+	return v
+}
+
+func isMainPackageFile(p string) bool {
+	f, err := parser.ParseFile(token.NewFileSet(), p, nil, parser.PackageClauseOnly)
+	return err == nil && f.Name.Name == "main"
+}
+
+func readModulePath(p string) (m string, err error) {
+	// Open
+	var f *os.File
+	if f, err = os.Open(p); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		} else {
+			err = fmt.Errorf("opening %s failed: %w", p, err)
+		}
+		return
+	}
+	defer f.Close()
+
+	// Loop through lines
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if ms := scaffoldModuleRegexp.FindStringSubmatch(strings.TrimSpace(s.Text())); len(ms) > 1 {
+			m = ms[1]
+			return
+		}
+	}
+	if err = s.Err(); err != nil {
+		err = fmt.Errorf("scanning %s failed: %w", p, err)
+		return
+	}
+	return
+}
+
+func readPackageName(dir string) (n string, err error) {
+	var fs []os.FileInfo
+	if fs, err = ioutil.ReadDir(dir); err != nil {
+		err = fmt.Errorf("reading dir %s failed: %w", dir, err)
+		return
+	}
+	for _, fi := range fs {
+		if !scaffoldBindFileRegexp.MatchString(fi.Name()) {
+			continue
+		}
+		p := filepath.Join(dir, fi.Name())
+		var f *ast.File
+		if f, err = parser.ParseFile(token.NewFileSet(), p, nil, parser.PackageClauseOnly); err != nil {
+			err = fmt.Errorf("parsing %s failed: %w", p, err)
+			return
+		}
+		n = f.Name.Name
+		return
+	}
+	return
+}
+
+// scaffoldValue returns a value that is valid in json, toml and yaml
+func scaffoldValue(v string) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func (s *scaffold) renderJSON() ([]byte, error) {
+	m := make(map[string]interface{})
+	for _, e := range s.root {
+		if !e.disabled {
+			m[e.key] = e.value
+		}
+	}
+	if len(s.bind) > 0 {
+		b := make(map[string]string)
+		for _, e := range s.bind {
+			b[e.key] = e.value
+		}
+		m["bind"] = b
+	}
+	var es []map[string]string
+	for _, e := range s.environments {
+		es = append(es, map[string]string{"arch": e.Arch, "os": e.OS})
+	}
+	m["environments"] = es
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func (s *scaffold) renderTOML() []byte {
+	buf := &bytes.Buffer{}
+	writeScaffoldEntries(buf, s.root, "", " = ")
+	if len(s.bind) > 0 {
+		buf.WriteString("\n[bind]\n")
+		writeScaffoldEntries(buf, s.bind, "", " = ")
+	}
+	buf.WriteString("\n# List of environments the bundling should be done upon\n")
+	for _, e := range s.environments {
+		fmt.Fprintf(buf, "[[environments]]\narch = %s\nos = %s\n", scaffoldValue(e.Arch), scaffoldValue(e.OS))
+	}
+	return buf.Bytes()
+}
+
+func (s *scaffold) renderYAML() []byte {
+	buf := &bytes.Buffer{}
+	writeScaffoldEntries(buf, s.root, "", ": ")
+	if len(s.bind) > 0 {
+		buf.WriteString("\n# The bind configuration\nbind:\n")
+		writeScaffoldEntries(buf, s.bind, "  ", ": ")
+	}
+	buf.WriteString("\n# List of environments the bundling should be done upon\nenvironments:\n")
+	for _, e := range s.environments {
+		fmt.Fprintf(buf, "  - arch: %s\n    os: %s\n", scaffoldValue(e.Arch), scaffoldValue(e.OS))
+	}
+	return buf.Bytes()
+}
+
+func writeScaffoldEntries(buf *bytes.Buffer, es []scaffoldEntry, indent, separator string) {
+	for idx, e := range es {
+		if idx > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(buf, "%s# %s\n", indent, e.comment)
+		if e.disabled {
+			fmt.Fprintf(buf, "%s# %s%s%s\n", indent, e.key, separator, scaffoldValue(e.value))
+		} else {
+			fmt.Fprintf(buf, "%s%s%s%s\n", indent, e.key, separator, scaffoldValue(e.value))
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package astibundler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScaffold(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err = os.MkdirAll(filepath.Join(dir, "cmd", "app", "resources"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, "go.mod", "module github.com/acme/myapp\n")
+	writeTestFile(t, dir, "cmd/app/main.go", "package main\n")
+	writeTestFile(t, dir, "bind/bind_linux_amd64.go", "package bind\n")
+	writeTestFile(t, dir, "assets/icon.icns", "")
+
+	e := &Configuration{
+		AppName:        "myapp",
+		Bind:           ConfigurationBind{OutputPath: "bind", Package: "bind"},
+		Environments:   []ConfigurationEnvironment{{Arch: runtime.GOARCH, OS: runtime.GOOS}},
+		IconPathDarwin: "assets/icon.icns",
+		InputPath:      "cmd/app",
+		ResourcesPath:  "resources",
+	}
+	for _, f := range []string{ConfigurationFormatJSON, ConfigurationFormatTOML, ConfigurationFormatYAML} {
+		p, err := Scaffold(ScaffoldOptions{Format: f, InputPath: dir, Overwrite: true})
+		assert.NoError(t, err)
+		c, err := LoadConfiguration(p, "")
+		assert.NoError(t, err)
+		assert.Equal(t, e, c)
+		fp, err := FindConfigurationPath(dir)
+		assert.NoError(t, err)
+		assert.Equal(t, p, fp)
+	}
+
+	// Existing configuration files with another format are not shadowed
+	_, err = Scaffold(ScaffoldOptions{Format: ConfigurationFormatJSON, InputPath: dir})
+	assert.EqualError(t, err, filepath.Join(dir, "bundler.yaml")+" already exists, use overwrite to overwrite it")
+	_, err = Scaffold(ScaffoldOptions{InputPath: dir})
+	assert.Error(t, err)
+}
@@ -0,0 +1,142 @@
+package astibundler
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"reflect"
+	"strings"
+)
+
+//go:generate go test -run TestSchema -update-schema
+
+// Schema returns the JSON schema of the configuration
+func Schema() []byte {
+	return []byte(schema)
+}
+
+// Schema enums indexed by "Type.Field"
+var schemaEnums = map[string][]string{
+	"ConfigurationBindCompression.Algorithm": {CompressionAlgorithmGzip, CompressionAlgorithmNone, CompressionAlgorithmZstd},
+	"ConfigurationEnvironment.OS":            {"darwin", "linux", "windows"},
+}
+
+// schemaGenerator generates the JSON schema of the configuration based on its type and on the
+// comments of the source files
+type schemaGenerator struct {
+	// Field comments indexed by "Type.Field"
+	comments map[string]string
+}
+
+// generateSchema generates the JSON schema of the configuration based on the source files located in dir
+func generateSchema(dir string) (b []byte, err error) {
+	// Parse source files
+	var pkgs map[string]*ast.Package
+	if pkgs, err = parser.ParseDir(token.NewFileSet(), dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments); err != nil {
+		err = fmt.Errorf("parsing dir %s failed: %w", dir, err)
+		return
+	}
+
+	// Index comments
+	g := &schemaGenerator{comments: make(map[string]string)}
+	for _, pkg := range pkgs {
+		ast.Inspect(pkg, g.indexComments)
+	}
+
+	// Build schema
+	s := g.schema(reflect.TypeOf(Configuration{}), "")
+	s["$schema"] = "http://json-schema.org/draft-07/schema#"
+	s["title"] = "astilectron-bundler configuration"
+
+	// Marshal
+	if b, err = json.MarshalIndent(s, "", "  "); err != nil {
+		err = fmt.Errorf("marshaling failed: %w", err)
+		return
+	}
+	return
+}
+
+func (g *schemaGenerator) indexComments(n ast.Node) bool {
+	// Only struct types are relevant
+	ts, ok := n.(*ast.TypeSpec)
+	if !ok {
+		return true
+	}
+	st, ok := ts.Type.(*ast.StructType)
+	if !ok {
+		return true
+	}
+
+	// Loop through fields
+	for _, f := range st.Fields.List {
+		var ss []string
+		for _, cg := range []*ast.CommentGroup{f.Doc, f.Comment} {
+			if t := strings.TrimSpace(cg.Text()); t != "" {
+				ss = append(ss, t)
+			}
+		}
+		for _, n := range f.Names {
+			g.comments[ts.Name.Name+"."+n.Name] = strings.Join(ss, "\n")
+		}
+	}
+	return false
+}
+
+func (g *schemaGenerator) schema(t reflect.Type, key string) (s map[string]interface{}) {
+	s = make(map[string]interface{})
+	switch t.Kind() {
+	case reflect.Bool:
+		s["type"] = "boolean"
+	case reflect.Float32, reflect.Float64:
+		s["type"] = "number"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s["type"] = "integer"
+	case reflect.String:
+		s["type"] = "string"
+	case reflect.Map:
+		s["type"] = "object"
+		if key == "Configuration.Profiles" {
+			// Profiles are partial configurations
+			s["additionalProperties"] = map[string]interface{}{"$ref": "#"}
+		} else if t.Elem().Kind() != reflect.Interface {
+			s["additionalProperties"] = g.schema(t.Elem(), "")
+		}
+	case reflect.Ptr:
+		s = g.schema(t.Elem(), key)
+	case reflect.Slice, reflect.Array:
+		s["type"] = "array"
+		s["items"] = g.schema(t.Elem(), "")
+	case reflect.Struct:
+		ps := make(map[string]interface{})
+		for idx := 0; idx < t.NumField(); idx++ {
+			// Get name
+			f := t.Field(idx)
+			n := strings.Split(f.Tag.Get("json"), ",")[0]
+			if n == "-" || f.PkgPath != "" {
+				continue
+			} else if n == "" {
+				n = f.Name
+			}
+
+			// Get schema
+			k := t.Name() + "." + f.Name
+			p := g.schema(f.Type, k)
+			if c := g.comments[k]; c != "" {
+				p["description"] = c
+			}
+			if e, ok := schemaEnums[k]; ok {
+				p["enum"] = e
+			}
+			ps[n] = p
+		}
+		s["type"] = "object"
+		s["properties"] = ps
+		s["additionalProperties"] = false
+	}
+	return
+}
@@ -0,0 +1,383 @@
+// Code generated by go generate; DO NOT EDIT.
+
+package astibundler
+
+const schema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "additionalProperties": false,
+  "properties": {
+    "app_name": {
+      "description": "The app name as it should be displayed everywhere\nIt's also set as an ldflag and therefore accessible in a global var package_name.AppName",
+      "type": "string"
+    },
+    "archive": {
+      "additionalProperties": false,
+      "description": "The archive configuration",
+      "properties": {
+        "enabled": {
+          "description": "Whether each environment output directory is archived in the output path",
+          "type": "boolean"
+        },
+        "formats": {
+          "additionalProperties": {
+            "type": "string"
+          },
+          "description": "The archive formats indexed by OS or by \"\u003cos\u003e/\u003carch\u003e\": \"zip\", \"tar.gz\" or \"tar.xz\"\nDefaults to \"zip\" for darwin and windows and to \"tar.gz\" for linux",
+          "type": "object"
+        },
+        "name": {
+          "description": "The name of the archives without extension\nIt can use {{.AppName}}, {{.Arch}}, {{.OS}} and {{.Version}} templates, where the version is the\nrelease version\nDefaults to \"{{.AppName}}-{{.Version}}-{{.OS}}-{{.Arch}}\", or to \"{{.AppName}}-{{.OS}}-{{.Arch}}\"\nwhen there's no version",
+          "type": "string"
+        }
+      },
+      "type": "object"
+    },
+    "astilectron_path": {
+      "description": "!\\\\ DEBUG ONLY\nThe path of a local astilectron directory when making changes to astilectron",
+      "type": "string"
+    },
+    "bind": {
+      "additionalProperties": false,
+      "description": "The bind configuration",
+      "properties": {
+        "compression": {
+          "description": "Compression rules of bound files, the first rule matching a file wins\nFiles matching no rule are gzipped unless they're already compressed, such as zip files or images",
+          "items": {
+            "additionalProperties": false,
+            "properties": {
+              "algorithm": {
+                "description": "The compression algorithm: \"none\", \"gzip\" or \"zstd\"\nFiles using \"zstd\" require the app to depend on github.com/klauspost/compress",
+                "enum": [
+                  "gzip",
+                  "none",
+                  "zstd"
+                ],
+                "type": "string"
+              },
+              "level": {
+                "description": "The compression level: from 1 to 9 for \"gzip\" and from 1 to 22 for \"zstd\"\nDefaults to the algorithm default level",
+                "type": "integer"
+              },
+              "pattern": {
+                "description": "Gitignore-style pattern of the files the rule applies to, relative to the bind dir\neg \"resources/**/*.js\" or \"vendor_astilectron_bundler/*.zip\"",
+                "type": "string"
+              }
+            },
+            "type": "object"
+          },
+          "type": "array"
+        },
+        "output_path": {
+          "description": "The path where the file will be written\nDefaults to the input path",
+          "type": "string"
+        },
+        "package": {
+          "description": "The package of the generated file\nDefaults to \"main\"",
+          "type": "string"
+        }
+      },
+      "type": "object"
+    },
+    "build_flags": {
+      "additionalProperties": {
+        "type": "string"
+      },
+      "description": "Build flags to pass into go build",
+      "type": "object"
+    },
+    "checksums_signing_key_path": {
+      "description": "The path of the key signing the SHA256SUMS file written in the output path\nIt's either an unencrypted minisign secret key or a PEM encoded ed25519 private key, and the\nsignature is written in the minisign format in the SHA256SUMS.minisig file",
+      "type": "string"
+    },
+    "darwin_agent_app": {
+      "description": "Whether the app is a darwin agent app",
+      "type": "boolean"
+    },
+    "environments": {
+      "description": "List of environments the bundling should be done upon.\nAn environment is a combination of OS and ARCH",
+      "items": {
+        "additionalProperties": false,
+        "properties": {
+          "arch": {
+            "description": "The target architecture\nThe OS/arch pair must be supported by go, see \"go tool dist list\"",
+            "type": "string"
+          },
+          "env": {
+            "additionalProperties": {
+              "type": "string"
+            },
+            "description": "Environment variables added to the go build command",
+            "type": "object"
+          },
+          "os": {
+            "description": "The target OS",
+            "enum": [
+              "darwin",
+              "linux",
+              "windows"
+            ],
+            "type": "string"
+          }
+        },
+        "type": "object"
+      },
+      "type": "array"
+    },
+    "extends": {
+      "description": "The path of a base configuration this configuration overrides. Objects are deep merged whereas\nother values, including lists, are replaced.\nThis path is relative to the configuration file",
+      "type": "string"
+    },
+    "go_binary_path": {
+      "description": "The path of the go binary\nDefaults to \"go\"",
+      "type": "string"
+    },
+    "icon_path_darwin": {
+      "description": "The path of the darwin icon (.icns)",
+      "type": "string"
+    },
+    "icon_path_linux": {
+      "description": "The path of the linux icon (.png)",
+      "type": "string"
+    },
+    "icon_path_windows": {
+      "description": "The path of the windows icon (.ico)",
+      "type": "string"
+    },
+    "info_plist": {
+      "description": "Info.plist property list",
+      "type": "object"
+    },
+    "input_path": {
+      "description": "The path of the project.\nDefaults to the current directory",
+      "type": "string"
+    },
+    "ldflags": {
+      "additionalProperties": {
+        "items": {
+          "type": "string"
+        },
+        "type": "array"
+      },
+      "description": "LDFlags to pass through to go build",
+      "type": "object"
+    },
+    "ldflags_package": {
+      "description": "The path used for the LD Flags\nDefaults to the ` + "`" + `Bind.Package` + "`" + ` value",
+      "type": "string"
+    },
+    "manifest_path": {
+      "description": "The path to application manifest file (WINDOWS ONLY)",
+      "type": "string"
+    },
+    "output_path": {
+      "description": "The path where the files will be written\nDefaults to \"output\"",
+      "type": "string"
+    },
+    "profiles": {
+      "additionalProperties": {
+        "$ref": "#"
+      },
+      "description": "Named partial configurations that can be deep merged on top of the configuration",
+      "type": "object"
+    },
+    "publish": {
+      "additionalProperties": false,
+      "description": "The publish configuration\nWhen a bucket is set, artifacts are uploaded to S3-compatible storage once bundling is done",
+      "properties": {
+        "bucket": {
+          "description": "The name of the bucket",
+          "type": "string"
+        },
+        "endpoint": {
+          "description": "The URL of the S3-compatible endpoint, eg \"http://localhost:9000\"\nDefaults to \"https://s3.\u003cregion\u003e.amazonaws.com\"",
+          "type": "string"
+        },
+        "prefix": {
+          "description": "The prefix of the object keys, which are the paths of the artifacts relative to the output path\nIt can use {{.AppName}} and {{.Version}} templates, eg \"{{.AppName}}/{{.Version}}\"",
+          "type": "string"
+        },
+        "region": {
+          "description": "The region of the bucket\nDefaults to the AWS_REGION environment variable or \"us-east-1\"",
+          "type": "string"
+        },
+        "virtual_hosted_style": {
+          "description": "Whether the bucket is part of the host, eg \"https://\u003cbucket\u003e.s3.amazonaws.com\", rather than of the\npath, eg \"https://s3.amazonaws.com/\u003cbucket\u003e\"",
+          "type": "boolean"
+        }
+      },
+      "type": "object"
+    },
+    "release": {
+      "additionalProperties": false,
+      "description": "The release configuration\nWhen a base URL is set, update feeds are written in the output path once bundling is done",
+      "properties": {
+        "base_url": {
+          "description": "The URL the artifacts are served from, which the file URLs of the feeds are built upon\nIt can use {{.AppName}}, {{.Arch}}, {{.OS}} and {{.Version}} templates, eg\n\"https://example.com/{{.Version}}/{{.OS}}-{{.Arch}}\"",
+          "type": "string"
+        },
+        "notes_path": {
+          "description": "The path of the file containing the release notes",
+          "type": "string"
+        },
+        "version": {
+          "description": "The version of the release\nDefaults to the CFBundleShortVersionString of the Info.plist property list",
+          "type": "string"
+        }
+      },
+      "type": "object"
+    },
+    "resources_adapters": {
+      "description": "List of commands executed on resources\nPaths inside commands must be relative to the resources folder",
+      "items": {
+        "additionalProperties": false,
+        "properties": {
+          "arch": {
+            "description": "The archs the command is executed for\nDefaults to every arch",
+            "items": {
+              "type": "string"
+            },
+            "type": "array"
+          },
+          "args": {
+            "description": "Arguments of the command\nThey can use {{.AppName}}, {{.Arch}}, {{.OS}}, {{.ResourcesPath}}, {{.VersionAstilectron}} and\n{{.VersionElectron}} templates",
+            "items": {
+              "type": "string"
+            },
+            "type": "array"
+          },
+          "dir": {
+            "description": "The directory the command is executed in\nThis path must be relative to the resources folder\nDefaults to the resources folder",
+            "type": "string"
+          },
+          "env": {
+            "additionalProperties": {
+              "type": "string"
+            },
+            "description": "Environment variables added to the command\nThey can use the same templates as arguments",
+            "type": "object"
+          },
+          "environment_specific": {
+            "description": "Whether the command output depends on the environment\nAdapters that are not environment specific run once per bundle and their output is shared by\nevery environment, whereas environment specific adapters run for each environment afterwards\nAdapters with \"os\" or \"arch\" filters and adapters whose args or environment variables use the\n{{.OS}} or {{.Arch}} templates are always environment specific",
+            "type": "boolean"
+          },
+          "files": {
+            "description": "Gitignore-style patterns of the files builtin adapters apply to, relative to the adapter directory\nDefaults to the files the builtin adapter is meant for, eg \"*.js\" for \"builtin:minify-js\"",
+            "items": {
+              "type": "string"
+            },
+            "type": "array"
+          },
+          "name": {
+            "description": "The name of the command or of a builtin adapter: \"builtin:minify-css\", \"builtin:minify-html\",\n\"builtin:minify-js\", \"builtin:optimize-png\" or \"builtin:template\"",
+            "type": "string"
+          },
+          "os": {
+            "description": "The OSes the command is executed for\nDefaults to every OS",
+            "items": {
+              "type": "string"
+            },
+            "type": "array"
+          },
+          "timeout": {
+            "description": "The duration after which the command is killed, eg \"30s\" or \"5m\"\nDefaults to no timeout",
+            "type": "string"
+          }
+        },
+        "type": "object"
+      },
+      "type": "array"
+    },
+    "resources_asar": {
+      "description": "Whether adapted resources are packed into an ASAR archive named \"\u003cresources path\u003e.asar\" which is\nbound instead of the resources folder",
+      "type": "boolean"
+    },
+    "resources_asar_unpacked": {
+      "description": "Gitignore-style patterns of resources that are left out of the ASAR archive, eg \"*.node\"\nThey're bound in the \"\u003cresources path\u003e.asar.unpacked\" folder",
+      "items": {
+        "type": "string"
+      },
+      "type": "array"
+    },
+    "resources_exclude": {
+      "description": "Gitignore-style patterns of resources that are not bound, eg \"*.map\" or \"node_modules/\"\nPatterns are relative to the resources folder, support \"**\" and can be negated with \"!\"",
+      "items": {
+        "type": "string"
+      },
+      "type": "array"
+    },
+    "resources_hash": {
+      "description": "Gitignore-style patterns of resources renamed to include a hash of their content, eg \"app.js\"\nbecomes \"app.3f2a9c.js\"\nReferences to them in html and css resources are rewritten and a manifest mapping logical names to\nhashed names is generated in the bind package",
+      "items": {
+        "type": "string"
+      },
+      "type": "array"
+    },
+    "resources_hash_length": {
+      "description": "The number of hex characters of resources hashes\nDefaults to 6",
+      "type": "integer"
+    },
+    "resources_include": {
+      "description": "Gitignore-style patterns of resources that are bound\nDefaults to every resource",
+      "items": {
+        "type": "string"
+      },
+      "type": "array"
+    },
+    "resources_path": {
+      "description": "The path where the resources are/will be created\nThis path must be relative to the input path\nDefaults to \"resources\"",
+      "type": "string"
+    },
+    "sbom": {
+      "additionalProperties": false,
+      "description": "The SBOM configuration",
+      "properties": {
+        "enabled": {
+          "description": "Whether an SBOM is written",
+          "type": "boolean"
+        },
+        "spdx": {
+          "description": "Whether an SPDX document is written in addition to the CycloneDX one",
+          "type": "boolean"
+        }
+      },
+      "type": "object"
+    },
+    "show_windows_console": {
+      "description": "Show Windows console",
+      "type": "boolean"
+    },
+    "third_party_notices": {
+      "additionalProperties": false,
+      "description": "The third party notices configuration",
+      "properties": {
+        "bind": {
+          "description": "Whether the file is also bound at the root of the resources folder",
+          "type": "boolean"
+        },
+        "enabled": {
+          "description": "Whether the file is written",
+          "type": "boolean"
+        }
+      },
+      "type": "object"
+    },
+    "vendor_dir_path": {
+      "description": "The path where the vendor directory will be created\nThis path must be relative to the output path\nDefaults to a temp directory",
+      "type": "string"
+    },
+    "version_astilectron": {
+      "description": "Version of Astilectron install",
+      "type": "string"
+    },
+    "version_electron": {
+      "description": "Version of Electron install",
+      "type": "string"
+    },
+    "working_directory_path": {
+      "description": "The path to the working directory.\nDefaults to a temp directory",
+      "type": "string"
+    }
+  },
+  "title": "astilectron-bundler configuration",
+  "type": "object"
+}`
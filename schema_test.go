@@ -0,0 +1,32 @@
+package astibundler
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var updateSchema = flag.Bool("update-schema", false, "if set, schema_data.go will be regenerated")
+
+func TestSchema(t *testing.T) {
+	b, err := generateSchema(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *updateSchema {
+		if err = ioutil.WriteFile("schema_data.go", []byte(fmt.Sprintf("// Code generated by go generate; DO NOT EDIT.\n\npackage astibundler\n\nconst schema = `%s`\n", strings.Replace(string(b), "`", "` + \"`\" + `", -1))), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	if string(b) != schema {
+		t.Fatal("schema is out of sync with the configuration, run go generate")
+	}
+	assert.Contains(t, string(Schema()), `"app_name"`)
+}
@@ -0,0 +1,253 @@
+package astibundler
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/asticode/go-astikit"
+	"github.com/asticode/go-astilectron"
+)
+
+// Icon magic numbers
+var (
+	iconMagicICNS = []byte("icns")
+	iconMagicICO  = []byte{0, 0, 1, 0}
+	iconMagicPNG  = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// Validate validates the configuration and returns every problem found. Unknown keys are rejected when
+// loading the configuration.
+func (c *Configuration) Validate() error {
+	errs := astikit.NewErrors()
+
+	// Environments
+	c.validateEnvironments(errs)
+
+	// Icons
+	c.validateFile("icon_path_darwin", c.IconPathDarwin, func(f *os.File) error { return validateMagic(f, iconMagicICNS, "icns") }, errs)
+	c.validateFile("icon_path_linux", c.IconPathLinux, func(f *os.File) error { return validateMagic(f, iconMagicPNG, "png") }, errs)
+	c.validateFile("icon_path_windows", c.IconPathWindows, func(f *os.File) error { return validateMagic(f, iconMagicICO, "ico") }, errs)
+
+	// Manifest
+	c.validateFile("manifest_path", c.ManifestPath, validateManifest, errs)
+
+	// Archive
+	c.validateArchive(errs)
+
+	// Checksums signing key
+	if len(c.ChecksumsSigningKeyPath) > 0 {
+		if _, _, err := readSigningKey(c.ChecksumsSigningKeyPath); err != nil {
+			errs.Add(fmt.Errorf("checksums_signing_key_path: %w", err))
+		}
+	}
+
+	// Publish
+	c.validatePublish(errs)
+
+	// Release
+	c.validateRelease(errs)
+
+	// Resources
+	c.validateResourcesPath(errs)
+	c.validatePatterns("resources_exclude", c.ResourcesExclude, errs)
+	c.validatePatterns("resources_asar_unpacked", c.ResourcesASARUnpacked, errs)
+	c.validatePatterns("resources_hash", c.ResourcesHash, errs)
+	if err := validateResourcesHashLength(c.ResourcesHashLength); err != nil {
+		errs.Add(fmt.Errorf("resources_hash_length: %w", err))
+	}
+	c.validatePatterns("resources_include", c.ResourcesInclude, errs)
+
+	// Resources adapters
+	for idx, a := range c.ResourcesAdapters {
+		if err := a.validate(); err != nil {
+			errs.Add(fmt.Errorf("resources_adapters.%d: %w", idx, err))
+		}
+	}
+
+	// Bind compression
+	for idx, bc := range c.Bind.Compression {
+		if _, err := newCompressionRule(bc); err != nil {
+			errs.Add(fmt.Errorf("bind.compression.%d: %w", idx, err))
+		}
+	}
+
+	// No errors
+	if errs.IsNil() {
+		return nil
+	}
+	return errs
+}
+
+func (c *Configuration) validateEnvironments(errs *astikit.Errors) {
+	// No environments
+	if len(c.Environments) == 0 {
+		return
+	}
+
+	// Get go binary path
+	p := "go"
+	if len(c.GoBinaryPath) > 0 {
+		p = c.GoBinaryPath
+	}
+
+	// List go environments
+	b, err := exec.Command(p, "tool", "dist", "list").Output()
+	if err != nil {
+		errs.Add(fmt.Errorf("environments: listing go environments with %s failed: %w", p, err))
+		return
+	}
+	goEnvs := make(map[string]bool)
+	for _, l := range strings.Split(string(b), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			goEnvs[l] = true
+		}
+	}
+
+	// Loop through environments
+	for idx, e := range c.Environments {
+		if !astilectron.IsValidOS(e.OS) {
+			errs.Add(fmt.Errorf("environments.%d: OS %s is not supported by astilectron", idx, e.OS))
+		} else if !goEnvs[e.OS+"/"+e.Arch] {
+			errs.Add(fmt.Errorf("environments.%d: %s/%s is not supported by go", idx, e.OS, e.Arch))
+		}
+	}
+}
+
+func (c *Configuration) validateFile(key, path string, fn func(f *os.File) error, errs *astikit.Errors) {
+	// No path
+	if len(path) == 0 {
+		return
+	}
+
+	// Open file
+	f, err := os.Open(path)
+	if err != nil {
+		errs.Add(fmt.Errorf("%s: opening %s failed: %w", key, path, err))
+		return
+	}
+	defer f.Close()
+
+	// Validate
+	if err = fn(f); err != nil {
+		errs.Add(fmt.Errorf("%s: %s is invalid: %w", key, path, err))
+		return
+	}
+}
+
+func validateMagic(f *os.File, magic []byte, format string) (err error) {
+	b := make([]byte, len(magic))
+	if _, err = io.ReadFull(f, b); err != nil || !bytes.Equal(b, magic) {
+		err = fmt.Errorf("not a valid %s file", format)
+		return
+	}
+	return
+}
+
+func validateManifest(f *os.File) (err error) {
+	var m struct {
+		XMLName xml.Name
+	}
+	if err = xml.NewDecoder(f).Decode(&m); err != nil {
+		err = fmt.Errorf("not a valid xml file: %w", err)
+		return
+	}
+	if m.XMLName.Local != "assembly" {
+		err = fmt.Errorf("root element is %s instead of assembly", m.XMLName.Local)
+		return
+	}
+	return
+}
+
+func (c *Configuration) validateArchive(errs *astikit.Errors) {
+	// Not enabled
+	if !c.Archive.Enabled {
+		return
+	}
+
+	// Formats
+	if err := validateArchiveFormats(c.Archive.Formats); err != nil {
+		errs.Add(fmt.Errorf("archive.formats: %w", err))
+	}
+
+	// Name
+	t, err := newArchiveNameTemplate(c)
+	if err != nil {
+		errs.Add(fmt.Errorf("archive.name: %w", err))
+		return
+	}
+	if err = t.Execute(ioutil.Discard, archiveNameData{}); err != nil {
+		errs.Add(fmt.Errorf("archive.name: executing template %s failed: %w", c.Archive.Name, err))
+	}
+}
+
+func (c *Configuration) validatePublish(errs *astikit.Errors) {
+	// No bucket
+	if len(c.Publish.Bucket) == 0 {
+		return
+	}
+
+	// Publisher
+	if _, err := newPublisher(c); err != nil {
+		errs.Add(fmt.Errorf("publish: %w", err))
+	}
+
+	// Credentials
+	if len(os.Getenv(publishEnvAccessKeyID)) == 0 || len(os.Getenv(publishEnvSecretAccessKey)) == 0 {
+		errs.Add(fmt.Errorf("publish: %s and %s environment variables must be set", publishEnvAccessKeyID, publishEnvSecretAccessKey))
+	}
+}
+
+func (c *Configuration) validateRelease(errs *astikit.Errors) {
+	// Notes
+	c.validateFile("release.notes_path", c.Release.NotesPath, func(f *os.File) error { return nil }, errs)
+
+	// No base URL
+	if len(c.Release.BaseURL) == 0 {
+		return
+	}
+
+	// Base URL
+	if _, err := newReleaseURLTemplate(c.Release.BaseURL); err != nil {
+		errs.Add(fmt.Errorf("release.base_url: %w", err))
+	}
+
+	// Version
+	if len(releaseVersion(c)) == 0 {
+		errs.Add(errors.New("release.version: version is required since it can't be read from info_plist"))
+	}
+}
+
+func (c *Configuration) validateResourcesPath(errs *astikit.Errors) {
+	// Get path
+	p := c.ResourcesPath
+	if len(p) == 0 {
+		p = "resources"
+	}
+	p = filepath.Join(c.InputPath, p)
+
+	// Stat
+	fi, err := os.Stat(p)
+	if err != nil {
+		errs.Add(fmt.Errorf("resources_path: stating %s failed: %w", p, err))
+		return
+	}
+	if !fi.IsDir() {
+		errs.Add(fmt.Errorf("resources_path: %s is not a directory", p))
+	}
+}
+
+func (c *Configuration) validatePatterns(key string, ps []string, errs *astikit.Errors) {
+	for idx, p := range ps {
+		if _, err := newPathPattern(p); err != nil {
+			errs.Add(fmt.Errorf("%s.%d: pattern %s is invalid: %w", key, idx, p, err))
+		}
+	}
+}
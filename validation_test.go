@@ -0,0 +1,72 @@
+package astibundler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigurationValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astibundler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err = os.Mkdir(filepath.Join(dir, "resources"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	icns := writeTestFile(t, dir, "icon.icns", "icns....")
+	ico := writeTestFile(t, dir, "icon.ico", "\x00\x00\x01\x00....")
+	png := writeTestFile(t, dir, "icon.png", "\x89PNG\r\n\x1a\n....")
+	manifest := writeTestFile(t, dir, "app.manifest", `<?xml version="1.0"?><assembly xmlns="urn:schemas-microsoft-com:asm.v1"></assembly>`)
+
+	c := &Configuration{
+		Environments:    []ConfigurationEnvironment{{Arch: "amd64", OS: "linux"}, {Arch: "arm64", OS: "darwin"}},
+		IconPathDarwin:  icns,
+		IconPathLinux:   png,
+		IconPathWindows: ico,
+		InputPath:       dir,
+		ManifestPath:    manifest,
+		InfoPlist:       map[string]interface{}{"CFBundleShortVersionString": "1.0.0"},
+		Release:         ConfigurationRelease{BaseURL: "https://example.com/{{.Version}}"},
+	}
+	assert.NoError(t, c.Validate())
+
+	c = &Configuration{
+		Archive:             ConfigurationArchive{Enabled: true, Formats: map[string]string{"linux": "rar"}, Name: "{{.Invalid}}"},
+		Environments:        []ConfigurationEnvironment{{Arch: "invalid", OS: "linux"}, {Arch: "amd64", OS: "freebsd"}},
+		IconPathDarwin:      png,
+		IconPathWindows:     filepath.Join(dir, "invalid.ico"),
+		InputPath:           dir,
+		ManifestPath:        icns,
+		Publish:             ConfigurationPublish{Bucket: "bucket", Endpoint: "ftp://example.com"},
+		Release:             ConfigurationRelease{BaseURL: "https://example.com/{{.Version", NotesPath: filepath.Join(dir, "invalid.md")},
+		ResourcesExclude:    []string{"*.map", "[invalid"},
+		ResourcesHashLength: 100,
+		ResourcesPath:       "invalid",
+	}
+	err = c.Validate()
+	assert.Error(t, err)
+	for _, s := range []string{
+		"archive.formats: format rar of linux is not zip, tar.gz or tar.xz",
+		"archive.name: executing template {{.Invalid}} failed",
+		"environments.0: linux/invalid is not supported by go",
+		"environments.1: OS freebsd is not supported by astilectron",
+		"icon_path_darwin: " + png + " is invalid: not a valid icns file",
+		"icon_path_windows: opening",
+		"manifest_path: " + icns + " is invalid",
+		"publish: endpoint ftp://example.com is not an http or https url",
+		"release.base_url: parsing template",
+		"release.notes_path: opening",
+		"release.version: version is required",
+		"resources_exclude.1: pattern [invalid is invalid",
+		"resources_hash_length: length 100 is not between 4 and 64",
+		"resources_path: stating",
+	} {
+		assert.Contains(t, err.Error(), s)
+	}
+}
@@ -0,0 +1,286 @@
+package astibundler
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// WatchOptions represents watch options
+type WatchOptions struct {
+	// Arguments passed to the app
+	Args []string
+	// Duration without changes after which the app is reloaded
+	// Defaults to 500ms
+	Debounce time.Duration
+	// Whether data is bound in dev mode, in which case resources are read from disk by the app and
+	// resources changes only restart it
+	Dev bool
+	// Interval between two scans of the watched files
+	// Defaults to 250ms
+	Interval time.Duration
+	// Whether resources adapters are executed when binding data
+	ResourcesAdapters bool
+	// Where the app stderr is forwarded to
+	// Defaults to os.Stderr
+	Stderr io.Writer
+	// Where the app stdout is forwarded to
+	// Defaults to os.Stdout
+	Stdout io.Writer
+}
+
+// Watch binds data, builds the app for the host environment and runs it. It does it again every time
+// resources or go sources change, until the bundler is stopped. Data is only bound again if resources
+// have changed. In dev mode, data is bound once and resources changes only restart the app.
+func (b *Bundler) Watch(o WatchOptions) (err error) {
+	// Default options
+	if o.Debounce == 0 {
+		o.Debounce = 500 * time.Millisecond
+	}
+	if o.Interval == 0 {
+		o.Interval = 250 * time.Millisecond
+	}
+	if o.Stderr == nil {
+		o.Stderr = os.Stderr
+	}
+	if o.Stdout == nil {
+		o.Stdout = os.Stdout
+	}
+
+	// Create watcher
+	w := &watcher{
+		b: b,
+		e: b.hostEnvironment(),
+		o: o,
+	}
+
+	// Make sure the app is stopped
+	defer w.stopApp()
+
+	// Snapshot
+	var rs, gs map[string]watchedFile
+	if rs, gs, err = w.snapshot(); err != nil {
+		err = fmt.Errorf("snapshotting failed: %w", err)
+		return
+	}
+
+	// Reload
+	w.reload(true, true)
+
+	// Loop
+	var bindPending, buildPending, restartPending bool
+	var lastChange time.Time
+	t := time.NewTicker(o.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-t.C:
+			// Snapshot
+			var nrs, ngs map[string]watchedFile
+			if nrs, ngs, err = w.snapshot(); err != nil {
+				b.l.Errorf("snapshotting failed: %s", err)
+				continue
+			}
+
+			// Check changes
+			if !equalWatchedFiles(rs, nrs) {
+				if o.Dev {
+					restartPending = true
+				} else {
+					bindPending, buildPending = true, true
+				}
+				lastChange = time.Now()
+			}
+			if !equalWatchedFiles(gs, ngs) {
+				buildPending, lastChange = true, time.Now()
+			}
+			rs, gs = nrs, ngs
+
+			// Debounce
+			if (!buildPending && !restartPending) || time.Since(lastChange) < o.Debounce {
+				continue
+			}
+
+			// Reload
+			b.l.Infof("Changes detected, reloading")
+			w.reload(bindPending, buildPending)
+			bindPending, buildPending, restartPending = false, false, false
+		}
+	}
+}
+
+// hostEnvironment returns the configured environment matching the host or a default environment
+func (b *Bundler) hostEnvironment() ConfigurationEnvironment {
+	for _, e := range b.environments {
+		if e.OS == runtime.GOOS && e.Arch == runtime.GOARCH {
+			return e
+		}
+	}
+	return ConfigurationEnvironment{Arch: runtime.GOARCH, OS: runtime.GOOS}
+}
+
+type watchedFile struct {
+	modTime time.Time
+	size    int64
+}
+
+func equalWatchedFiles(a, b map[string]watchedFile) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || !bv.modTime.Equal(v.modTime) || bv.size != v.size {
+			return false
+		}
+	}
+	return true
+}
+
+type watcher struct {
+	b   *Bundler
+	cmd *exec.Cmd
+	e   ConfigurationEnvironment
+	o   WatchOptions
+}
+
+// snapshot returns the state of resources and go sources
+func (w *watcher) snapshot() (rs, gs map[string]watchedFile, err error) {
+	// Resources
+	if rs, err = snapshotFiles(filepath.Join(w.b.pathInput, w.b.pathResources), w.b.resourcesFileFilter()); err != nil {
+		err = fmt.Errorf("snapshotting resources failed: %w", err)
+		return
+	}
+
+	// Go sources
+	modulePath := goModulePath(w.b.pathInput)
+	if gs, err = snapshotFiles(modulePath, w.b.goSourcesFilter(modulePath)); err != nil {
+		err = fmt.Errorf("snapshotting go sources failed: %w", err)
+		return
+	}
+	return
+}
+
+func snapshotFiles(dir string, filter func(p string, fi os.FileInfo) bool) (m map[string]watchedFile, err error) {
+	// List files
+	var ps []string
+	if ps, err = listFiles(dir, filter); err != nil {
+		err = fmt.Errorf("listing files of %s failed: %w", dir, err)
+		return
+	}
+
+	// Stat files
+	m = make(map[string]watchedFile)
+	for _, p := range ps {
+		fi, errStat := os.Stat(p)
+		if errStat != nil {
+			// File may have been removed in the meantime
+			continue
+		}
+		m[p] = watchedFile{modTime: fi.ModTime(), size: fi.Size()}
+	}
+	return
+}
+
+// reload binds data and builds the app if needed, and restarts it. Errors are logged since watching
+// must go on until the user fixes them.
+func (w *watcher) reload(bind, build bool) {
+	// Only restart
+	name := w.b.appName
+	if w.e.OS == "windows" {
+		name += ".exe"
+	}
+	binaryPath := filepath.Join(w.b.pathWorkingDirectory, "watch", name)
+	if !build {
+		w.stopApp()
+		w.startApp(binaryPath)
+		return
+	}
+
+	// Bind data
+	if bind {
+		w.b.l.Debug("Binding data")
+		var err error
+		if w.o.Dev {
+			err = w.b.BindDevData(w.e.OS, w.e.Arch)
+		} else {
+			err = w.b.bindData(w.e.OS, w.e.Arch, w.o.ResourcesAdapters)
+		}
+		if err != nil {
+			w.b.l.Errorf("binding data failed: %s", err)
+			return
+		}
+	}
+
+	// Add windows .syso
+	if w.e.OS == "windows" {
+		if err := w.b.addWindowsSyso(w.e.Arch); err != nil {
+			w.b.l.Errorf("adding windows .syso failed: %s", err)
+			return
+		}
+	}
+
+	// Build in a temporary path since the running binary may not be overwritten
+	buildPath := filepath.Join(w.b.pathWorkingDirectory, "watch", "build", name)
+	if err := os.MkdirAll(filepath.Dir(buildPath), 0755); err != nil {
+		w.b.l.Errorf("mkdirall %s failed: %s", filepath.Dir(buildPath), err)
+		return
+	}
+	cmd := w.b.buildCmd(w.e, buildPath)
+	w.b.l.Debugf("Executing %s", cmd.Args)
+	if o, err := cmd.CombinedOutput(); err != nil {
+		w.b.l.Errorf("building failed: %s", o)
+		return
+	}
+
+	// Check context error
+	if w.b.ctx.Err() != nil {
+		return
+	}
+
+	// Stop app
+	w.stopApp()
+
+	// Move binary
+	if err := os.Rename(buildPath, binaryPath); err != nil {
+		w.b.l.Errorf("renaming %s to %s failed: %s", buildPath, binaryPath, err)
+		return
+	}
+
+	// Start app
+	w.startApp(binaryPath)
+}
+
+// startApp starts the app
+func (w *watcher) startApp(binaryPath string) {
+	w.cmd = exec.Command(binaryPath, w.o.Args...)
+	w.cmd.Dir = w.b.pathInput
+	w.cmd.Stderr = w.o.Stderr
+	w.cmd.Stdout = w.o.Stdout
+	startProcessGroup(w.cmd)
+	w.b.l.Infof("Starting %s", binaryPath)
+	if err := w.cmd.Start(); err != nil {
+		w.b.l.Errorf("starting %s failed: %s", binaryPath, err)
+		w.cmd = nil
+		return
+	}
+}
+
+// stopApp kills the app if it's running, as well as the processes it has started, such as Electron,
+// which would otherwise keep running and keep its output open
+func (w *watcher) stopApp() {
+	if w.cmd == nil {
+		return
+	}
+	w.b.l.Debugf("Stopping %s", w.cmd.Path)
+	killProcessGroup(w.cmd)
+	if err := w.cmd.Wait(); err != nil {
+		w.b.l.Debugf("waiting for %s failed: %s", w.cmd.Path, err)
+	}
+	w.cmd = nil
+}